@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Mallekoppie/goslow/platform"
+)
+
+// Generic database tool. Service specific migrations are only available when
+// platform.RunDatabaseCommand is called from the service binary itself
+func main() {
+	err := platform.RunDatabaseCommand(os.Args[1:], os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
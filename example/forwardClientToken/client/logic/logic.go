@@ -1,6 +1,7 @@
 package logic
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
@@ -22,13 +23,14 @@ func init() {
 	client = c
 }
 
-func CallServer(clientToken string) error {
+// CallServer ... ctx is the context of the incoming request so that the call is part of its trace
+func CallServer(ctx context.Context, clientToken string) error {
 	token, err := p.OAuth.GetToken("default")
 	if err != nil {
 		return err
 	}
 
-	request, err := http.NewRequest("GET", "http://localhost:9111/", nil)
+	request, err := http.NewRequestWithContext(ctx, "GET", "http://localhost:9111/", nil)
 	if err != nil {
 		return err
 	}
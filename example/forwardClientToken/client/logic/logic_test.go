@@ -1,9 +1,12 @@
 package logic
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestCallServer(t *testing.T) {
-	err := CallServer("test-client-token")
+	err := CallServer(context.Background(), "test-client-token")
 	if err != nil {
 		t.FailNow()
 	}
@@ -18,7 +18,7 @@ func HelloWorld(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := logic.CallServer(clientToken)
+	err := logic.CallServer(r.Context(), clientToken)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -17,6 +17,8 @@ var Routes = platform.Routes{
 		Method:      http.MethodPost,
 		HandlerFunc: HandleLogin,
 		SlaMs:       0,
+		// 5 attempts at once, then one every 5 seconds per client IP
+		RateLimit: &platform.RateLimit{Rate: 0.2, Burst: 5},
 	},
 	platform.Route{
 		Path:         "/renew",
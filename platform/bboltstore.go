@@ -0,0 +1,467 @@
+package platform
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrBboltFeatureUnsupported = errors.New("encryption, codecs, the change feed and indexes need the boltdb backend")
+)
+
+// bbolt is the maintained fork of BoltDB and reads the same file format. Bucket paths, expiry and revisions
+// are kept the same way as by BoltDb so that a file can be switched between the backends. Values are written
+// as JSON and values written with a codec can be read. Encryption, configured codecs, the change feed and
+// indexes are only kept up to date by BoltDb, so the store does not open when they are used
+type bboltDatabase struct {
+	db *bbolt.DB
+}
+
+// OpenBboltStore ... Opens a KeyValueStore backed by bbolt with the default BoltDB settings
+func OpenBboltStore(fileName string) (KeyValueStore, error) {
+	return openBboltStore(&boltDBConfig{FileName: fileName})
+}
+
+// Opens the file with the same options as BoltDb
+func openBboltStore(settings *boltDBConfig) (KeyValueStore, error) {
+	Log.Debug("Opening bbolt database",
+		zap.String("filename", settings.FileName),
+		zap.Bool("read_only", settings.ReadOnly))
+
+	if settings.Encryption.Enabled || len(settings.Codecs) > 0 || settings.ChangeFeed.Enabled {
+		Log.Error("The bbolt backend does not support the configured BoltDB features", zap.String("filename", settings.FileName))
+		return nil, ErrBboltFeatureUnsupported
+	}
+
+	mode, timeout, err := boltOpenOptions(settings.FileMode, settings.OpenTimeoutSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := bbolt.Open(settings.FileName, mode, &bbolt.Options{Timeout: timeout, ReadOnly: settings.ReadOnly})
+	if err == bbolt.ErrTimeout {
+		err = &BoltLockedError{FileName: settings.FileName, Pid: boltLockHolder(settings.FileName)}
+	}
+	if err != nil {
+		Log.Error("Error opening bbolt database", zap.String("filename", settings.FileName), zap.Error(err))
+		return nil, err
+	}
+
+	db.NoSync = settings.NoSync
+	db.NoGrowSync = settings.NoGrowSync
+
+	// Encrypted values written by BoltDb can not be read and its indexes would not be updated
+	err = db.View(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(boltEncryptionBucket)) != nil || bboltIndexesInUse(tx) {
+			return ErrBboltFeatureUnsupported
+		}
+		return nil
+	})
+	if err != nil {
+		Log.Error("The bbolt backend does not support the BoltDB features used by the file", zap.String("filename", settings.FileName))
+		db.Close()
+		return nil, err
+	}
+
+	return &bboltDatabase{db: db}, nil
+}
+
+func bboltIndexesInUse(tx *bbolt.Tx) bool {
+	root := tx.Bucket([]byte(boltIndexBucket))
+	if root == nil {
+		return false
+	}
+
+	inUse := false
+	root.ForEach(func(bucket, value []byte) error {
+		if value != nil {
+			return nil
+		}
+		return root.Bucket(bucket).ForEach(func(path, value []byte) error {
+			if value == nil && string(path) != boltIndexKeysBucket {
+				inUse = true
+			}
+			return nil
+		})
+	})
+
+	return inUse
+}
+
+func (d *bboltDatabase) SaveObject(bucket string, id string, object interface{}) error {
+	return d.Update(func(tx KeyValueTx) error {
+		return tx.SaveObject(bucket, id, object)
+	})
+}
+
+func (d *bboltDatabase) SaveObjectWithTTL(bucket string, id string, object interface{}, ttl time.Duration) error {
+	if ttl <= 0 {
+		Log.Error("Invalid TTL for object", zap.String("bucket", bucket), zap.String("id", id), zap.Duration("ttl", ttl))
+		return ErrBoltInvalidTTL
+	}
+
+	return d.Update(func(tx KeyValueTx) error {
+		return tx.(*bboltKeyValueTx).saveObject(bucket, id, object, time.Now().Add(ttl))
+	})
+}
+
+func (d *bboltDatabase) ReadObject(bucket string, id string, object interface{}) error {
+	return d.View(func(tx KeyValueTx) error {
+		return tx.ReadObject(bucket, id, object)
+	})
+}
+
+func (d *bboltDatabase) ReadAllObjects(bucket string) (map[string]string, error) {
+	bucket = cleanBoltBucketPath(bucket)
+	results := make(map[string]string)
+
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		if lookupBboltBucket(tx, bucket) == nil {
+			return ErrNoEntryFoundInDB
+		}
+
+		return (&bboltKeyValueTx{tx: tx}).ForEach(bucket, func(id string, value []byte) error {
+			results[id] = string(value)
+			return nil
+		})
+	})
+	if err != nil {
+		Log.Error("Error reading from database", zap.Error(err))
+		return results, err
+	}
+
+	return results, nil
+}
+
+func (d *bboltDatabase) RemoveObject(bucket string, id string) error {
+	return d.Update(func(tx KeyValueTx) error {
+		return tx.RemoveObject(bucket, id)
+	})
+}
+
+func (d *bboltDatabase) RemoveBucket(bucket string) error {
+	bucket = cleanBoltBucketPath(bucket)
+
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		b := lookupBboltBucket(tx, bucket)
+		if b == nil {
+			return nil
+		}
+
+		// Nested buckets are deleted with their parent. Their metadata is removed per path
+		paths := nestedBboltBucketPaths(b, bucket)
+
+		err := deleteBboltBucket(tx, bucket)
+		if err != nil {
+			Log.Error("Error removing bucket", zap.Error(err))
+			return err
+		}
+
+		for _, path := range paths {
+			// Revisions are kept like BoltDb does
+			parent := tx.Bucket([]byte(boltExpiryBucket))
+			if parent == nil {
+				continue
+			}
+
+			err = parent.DeleteBucket([]byte(path))
+			if err != nil && err != bbolt.ErrBucketNotFound {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (d *bboltDatabase) ForEach(bucket string, fn func(id string, value []byte) error) error {
+	return d.View(func(tx KeyValueTx) error {
+		return tx.ForEach(bucket, fn)
+	})
+}
+
+func (d *bboltDatabase) View(fn func(tx KeyValueTx) error) error {
+	return d.db.View(func(tx *bbolt.Tx) error {
+		return fn(&bboltKeyValueTx{tx: tx})
+	})
+}
+
+func (d *bboltDatabase) Update(fn func(tx KeyValueTx) error) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		return fn(&bboltKeyValueTx{tx: tx})
+	})
+}
+
+func (d *bboltDatabase) Close() error {
+	return d.db.Close()
+}
+
+// Same resolution as lookupBoltBucket, including top level buckets with the separator in their name
+func lookupBboltBucket(tx *bbolt.Tx, bucket string) *bbolt.Bucket {
+	names := splitBoltBucketPath(bucket)
+	if len(names) < 1 {
+		return nil
+	}
+
+	if b := nestedBboltBucket(tx.Bucket(names[0]), names[1:]); b != nil {
+		return b
+	}
+
+	for i := len(names); i > 1; i-- {
+		if b := nestedBboltBucket(tx.Bucket([]byte(joinBoltBucketPath(names[:i]))), names[i:]); b != nil {
+			return b
+		}
+	}
+
+	return nil
+}
+
+func nestedBboltBucket(b *bbolt.Bucket, names [][]byte) *bbolt.Bucket {
+	for _, name := range names {
+		if b == nil {
+			return nil
+		}
+		b = b.Bucket(name)
+	}
+
+	return b
+}
+
+func createBboltBucket(tx *bbolt.Tx, bucket string) (*bbolt.Bucket, error) {
+	names := splitBoltBucketPath(bucket)
+	if len(names) < 1 {
+		return nil, ErrBoltInvalidBucketPath
+	}
+
+	if b := lookupBboltBucket(tx, bucket); b != nil {
+		return b, nil
+	}
+
+	b, err := tx.CreateBucketIfNotExists(names[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names[1:] {
+		b, err = b.CreateBucketIfNotExists(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+func deleteBboltBucket(tx *bbolt.Tx, bucket string) error {
+	names := splitBoltBucketPath(bucket)
+	if len(names) == 1 {
+		return tx.DeleteBucket(names[0])
+	}
+
+	parent := lookupBboltBucket(tx, joinBoltBucketPath(names[:len(names)-1]))
+	if parent != nil && parent.Bucket(names[len(names)-1]) != nil {
+		return parent.DeleteBucket(names[len(names)-1])
+	}
+
+	return tx.DeleteBucket([]byte(joinBoltBucketPath(names)))
+}
+
+func nestedBboltBucketPaths(b *bbolt.Bucket, bucket string) []string {
+	paths := []string{bucket}
+	b.ForEach(func(name, value []byte) error {
+		if value == nil {
+			paths = append(paths, nestedBboltBucketPaths(b.Bucket(name), bucket+BoltBucketSeparator+string(name))...)
+		}
+		return nil
+	})
+
+	return paths
+}
+
+type bboltKeyValueTx struct {
+	tx *bbolt.Tx
+}
+
+// Nested bucket of the data bucket in a metadata bucket such as _expiry
+func (t *bboltKeyValueTx) metadata(name string, bucket string) *bbolt.Bucket {
+	parent := t.tx.Bucket([]byte(name))
+	if parent == nil {
+		return nil
+	}
+
+	return parent.Bucket([]byte(bucket))
+}
+
+func (t *bboltKeyValueTx) createMetadata(name string, bucket string) (*bbolt.Bucket, error) {
+	parent, err := t.tx.CreateBucketIfNotExists([]byte(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return parent.CreateBucketIfNotExists([]byte(bucket))
+}
+
+func (t *bboltKeyValueTx) expired(bucket string, id []byte) bool {
+	b := t.metadata(boltExpiryBucket, bucket)
+	if b == nil {
+		return false
+	}
+
+	value := b.Get(id)
+	if value == nil {
+		return false
+	}
+
+	return !decodeBoltExpiry(value).After(time.Now())
+}
+
+func (t *bboltKeyValueTx) setExpiry(bucket string, id string, expiresAt time.Time) error {
+	if expiresAt.IsZero() {
+		b := t.metadata(boltExpiryBucket, bucket)
+		if b == nil {
+			return nil
+		}
+
+		return b.Delete([]byte(id))
+	}
+
+	b, err := t.createMetadata(boltExpiryBucket, bucket)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(id), encodeBoltExpiry(expiresAt))
+}
+
+// Same as nextBoltRevision, so that If-Match and CompareAndSave keep working after switching back to BoltDb
+func (t *bboltKeyValueTx) nextRevision(data *bbolt.Bucket, bucket string, id string) error {
+	var revision uint64
+	if data.Get([]byte(id)) != nil && !t.expired(bucket, []byte(id)) {
+		revision = 1
+	}
+	// Removed entries keep their last revision
+	if b := t.metadata(boltRevisionBucket, bucket); b != nil {
+		if value := b.Get([]byte(id)); len(value) == 8 && binary.BigEndian.Uint64(value) > revision {
+			revision = binary.BigEndian.Uint64(value)
+		}
+	}
+
+	b, err := t.createMetadata(boltRevisionBucket, bucket)
+	if err != nil {
+		return err
+	}
+
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, revision+1)
+
+	return b.Put([]byte(id), value)
+}
+
+func (t *bboltKeyValueTx) SaveObject(bucket string, id string, object interface{}) error {
+	return t.saveObject(bucket, id, object, time.Time{})
+}
+
+func (t *bboltKeyValueTx) saveObject(bucket string, id string, object interface{}, expiresAt time.Time) error {
+	if !t.tx.Writable() {
+		return ErrDatabaseReadOnlyTx
+	}
+
+	bucket = cleanBoltBucketPath(bucket)
+	b, err := createBboltBucket(t.tx, bucket)
+	if err != nil {
+		Log.Error("Error creating bucket", zap.Error(err))
+		return err
+	}
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		Log.Error("Error marshalling object", zap.Error(err))
+		return err
+	}
+
+	err = t.nextRevision(b, bucket, id)
+	if err != nil {
+		Log.Error("Error updating revision", zap.Error(err))
+		return err
+	}
+
+	err = b.Put([]byte(id), data)
+	if err != nil {
+		Log.Error("Error adding data", zap.Error(err))
+		return err
+	}
+
+	return t.setExpiry(bucket, id, expiresAt)
+}
+
+func (t *bboltKeyValueTx) ReadObject(bucket string, id string, object interface{}) error {
+	bucket = cleanBoltBucketPath(bucket)
+	b := lookupBboltBucket(t.tx, bucket)
+	if b == nil || t.expired(bucket, []byte(id)) {
+		return ErrNoEntryFoundInDB
+	}
+
+	result := b.Get([]byte(id))
+	if len(result) < 1 {
+		return ErrNoEntryFoundInDB
+	}
+
+	// Values written by BoltDb with another codec carry its header
+	payload, codec, err := boltValuePayload(result)
+	if err != nil {
+		return err
+	}
+
+	return codec.Unmarshal(payload, object)
+}
+
+func (t *bboltKeyValueTx) RemoveObject(bucket string, id string) error {
+	if !t.tx.Writable() {
+		return ErrDatabaseReadOnlyTx
+	}
+
+	bucket = cleanBoltBucketPath(bucket)
+	b := lookupBboltBucket(t.tx, bucket)
+	if b == nil {
+		return nil
+	}
+
+	err := b.Delete([]byte(id))
+	if err != nil {
+		return err
+	}
+
+	return t.setExpiry(bucket, id, time.Time{})
+}
+
+func (t *bboltKeyValueTx) ForEach(bucket string, fn func(id string, value []byte) error) error {
+	bucket = cleanBoltBucketPath(bucket)
+	b := lookupBboltBucket(t.tx, bucket)
+	if b == nil {
+		return nil
+	}
+
+	cursor := b.Cursor()
+	for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+		if value == nil || t.expired(bucket, key) {
+			continue
+		}
+
+		payload, _, err := boltValuePayload(value)
+		if err != nil {
+			return err
+		}
+
+		err = fn(string(key), payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package platform
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/boltdb/bolt"
+	"go.uber.org/zap"
+)
+
+const (
+	// Separates the levels of a bucket path, e.g. tenant/a/orders
+	BoltBucketSeparator = "/"
+)
+
+var (
+	ErrBoltInvalidBucketPath = errors.New("bucket path is empty or names an internal bucket")
+)
+
+func splitBoltBucketPath(bucket string) [][]byte {
+	names := make([][]byte, 0)
+	for _, v := range strings.Split(bucket, BoltBucketSeparator) {
+		if len(v) > 0 {
+			names = append(names, []byte(v))
+		}
+	}
+
+	return names
+}
+
+func nestedBoltBucket(b *bolt.Bucket, names [][]byte) *bolt.Bucket {
+	for _, name := range names {
+		if b == nil {
+			return nil
+		}
+		b = b.Bucket(name)
+	}
+
+	return b
+}
+
+// Data bucket of the path or nil if a level does not exist. Top level buckets created before paths were
+// supported may have the separator in their name, e.g. a bucket named "tenant/a". The nested levels are
+// tried first and then such a bucket for the longest leading part of the path
+func lookupBoltBucket(tx *bolt.Tx, bucket string) *bolt.Bucket {
+	names := splitBoltBucketPath(bucket)
+	if len(names) < 1 {
+		return nil
+	}
+
+	if b := nestedBoltBucket(tx.Bucket(names[0]), names[1:]); b != nil {
+		return b
+	}
+
+	for i := len(names); i > 1; i-- {
+		if b := nestedBoltBucket(tx.Bucket([]byte(joinBoltBucketPath(names[:i]))), names[i:]); b != nil {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// Creates the missing levels of the path
+func createBoltBucket(tx *bolt.Tx, bucket string) (*bolt.Bucket, error) {
+	names := splitBoltBucketPath(bucket)
+	if len(names) < 1 {
+		return nil, ErrBoltInvalidBucketPath
+	}
+
+	if b := lookupBoltBucket(tx, bucket); b != nil {
+		return b, nil
+	}
+
+	b, err := tx.CreateBucketIfNotExists(names[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names[1:] {
+		b, err = b.CreateBucketIfNotExists(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// Deletes the last level of the path with everything nested in it. Returns bolt.ErrBucketNotFound if it does not exist
+func deleteBoltBucket(tx *bolt.Tx, bucket string) error {
+	names := splitBoltBucketPath(bucket)
+	if len(names) < 1 {
+		return bolt.ErrBucketNotFound
+	}
+
+	if len(names) == 1 {
+		return tx.DeleteBucket(names[0])
+	}
+
+	parent := lookupBoltBucket(tx, joinBoltBucketPath(names[:len(names)-1]))
+	if parent != nil && parent.Bucket(names[len(names)-1]) != nil {
+		return parent.DeleteBucket(names[len(names)-1])
+	}
+
+	// A top level bucket with the separator in its name
+	return tx.DeleteBucket([]byte(joinBoltBucketPath(names)))
+}
+
+func joinBoltBucketPath(names [][]byte) string {
+	parts := make([]string, 0, len(names))
+	for _, v := range names {
+		parts = append(parts, string(v))
+	}
+
+	return strings.Join(parts, BoltBucketSeparator)
+}
+
+// Normalised form of a path, without empty levels. Public functions clean the path they get so that
+// "a/b/", "/a/b" and "a/b" share the same data, expiry, revision, index, cache and encryption entries
+func cleanBoltBucketPath(bucket string) string {
+	return joinBoltBucketPath(splitBoltBucketPath(bucket))
+}
+
+// The path and the paths of all buckets nested in it
+func nestedBoltBucketPaths(b *bolt.Bucket, bucket string) []string {
+	paths := []string{bucket}
+	b.ForEach(func(name, value []byte) error {
+		if value == nil {
+			paths = append(paths, nestedBoltBucketPaths(b.Bucket(name), bucket+BoltBucketSeparator+string(name))...)
+		}
+		return nil
+	})
+
+	return paths
+}
+
+func validBoltBucketPath(bucket string) bool {
+	names := splitBoltBucketPath(bucket)
+	return len(names) > 0 && !isInternalBoltBucket(string(names[0]))
+}
+
+// CreateBucket ... Creates the bucket and any missing parent buckets. Existing buckets are left as they are
+func (d *boltDbDatabase) CreateBucket(bucket string) error {
+	if !validBoltBucketPath(bucket) {
+		return ErrBoltInvalidBucketPath
+	}
+
+	err := d.update(func(tx *bolt.Tx) error {
+		_, err := createBoltBucket(tx, bucket)
+		return err
+	})
+	if err != nil {
+		Log.Error("Error creating bucket", zap.String("bucket", bucket), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// BucketExists ... True if every level of the path exists
+func (d *boltDbDatabase) BucketExists(bucket string) (bool, error) {
+	exists := false
+	err := d.view(func(tx *bolt.Tx) error {
+		exists = lookupBoltBucket(tx, bucket) != nil
+		return nil
+	})
+
+	return exists, err
+}
+
+// ListBuckets ... Paths of the buckets directly under parent, sorted. Use "" for the top level.
+// Internal buckets are not listed
+func (d *boltDbDatabase) ListBuckets(parent string) ([]string, error) {
+	parent = cleanBoltBucketPath(parent)
+	buckets := make([]string, 0)
+
+	err := d.view(func(tx *bolt.Tx) error {
+		if len(parent) < 1 {
+			return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+				if !isInternalBoltBucket(string(name)) {
+					buckets = append(buckets, string(name))
+				}
+				return nil
+			})
+		}
+
+		b := lookupBoltBucket(tx, parent)
+		if b == nil {
+			return ErrNoEntryFoundInDB
+		}
+
+		return b.ForEach(func(name, value []byte) error {
+			if value == nil {
+				buckets = append(buckets, parent+BoltBucketSeparator+string(name))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(buckets)
+
+	return buckets, nil
+}
@@ -0,0 +1,160 @@
+package platform
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestNestedBuckets(t *testing.T) {
+	useTempBoltDB(t)
+
+	err := Database.BoltDb.CreateBucket("tenant/a/orders")
+	if err != nil {
+		t.Fatalf("Error creating bucket: %v", err)
+	}
+
+	exists, _ := Database.BoltDb.BucketExists("tenant/a/orders")
+	if !exists {
+		t.Error("Expected tenant/a/orders to exist")
+	}
+
+	Database.BoltDb.SaveObject("tenant/a/orders", "1", testObject{Id: "1"})
+	Database.BoltDb.SaveObjectWithTTL("tenant/a/invoices", "1", testObject{Id: "1"}, time.Hour)
+	Database.BoltDb.SaveObject("tenant/b/orders", "1", testObject{Id: "1"})
+	Database.BoltDb.SaveObject("tenant", "settings", testObject{Id: "settings"})
+
+	result := testObject{}
+	err = Database.BoltDb.ReadObject("tenant/a/orders", "1", &result)
+	if err != nil || result.Id != "1" {
+		t.Errorf("Unexpected result %+v, %v", result, err)
+	}
+
+	all, err := Database.BoltDb.ReadAllObjects("tenant")
+	if err != nil || len(all) != 1 {
+		t.Errorf("Expected only the values of tenant but got %v, %v", all, err)
+	}
+
+	buckets, _ := Database.BoltDb.ListBuckets("")
+	if len(buckets) != 1 || buckets[0] != "tenant" {
+		t.Errorf("Unexpected top level buckets %v", buckets)
+	}
+
+	buckets, _ = Database.BoltDb.ListBuckets("tenant/a")
+	if strings.Join(buckets, ",") != "tenant/a/invoices,tenant/a/orders" {
+		t.Errorf("Unexpected nested buckets %v", buckets)
+	}
+
+	buffer := bytes.Buffer{}
+	count, err := Database.BoltDb.Export(&buffer, "tenant/a")
+	if err != nil || count != 2 || !strings.Contains(buffer.String(), `"bucket":"tenant/a/invoices"`) {
+		t.Errorf("Unexpected export %d, %v: %s", count, err, buffer.String())
+	}
+
+	err = Database.BoltDb.RemoveBucket("tenant/a")
+	if err != nil {
+		t.Fatalf("Error removing bucket: %v", err)
+	}
+
+	exists, _ = Database.BoltDb.BucketExists("tenant/a/orders")
+	if exists {
+		t.Error("Expected nested buckets to be removed")
+	}
+	exists, _ = Database.BoltDb.BucketExists("tenant/b/orders")
+	if !exists {
+		t.Error("Expected tenant/b/orders to be kept")
+	}
+
+	// Revisions of the removed paths continue so that old ETags do not match the new entry
+	Database.BoltDb.SaveObject("tenant/a/invoices", "1", testObject{Id: "1"})
+	revision, err := Database.BoltDb.ReadObjectWithRevision("tenant/a/invoices", "1", &result)
+	if err != nil || revision != 2 {
+		t.Errorf("Expected the new entry at revision 2 but got %d, %v", revision, err)
+	}
+
+	err = Database.BoltDb.CreateBucket("_revisions/x")
+	if err != ErrBoltInvalidBucketPath {
+		t.Errorf("Expected ErrBoltInvalidBucketPath but got %v", err)
+	}
+}
+
+func TestBucketPathForms(t *testing.T) {
+	useTempBoltDB(t)
+
+	err := Database.BoltDb.SaveObjectWithTTL("a/b/", "1", testObject{Id: "1"}, time.Millisecond*20)
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+
+	result := testObject{}
+	err = Database.BoltDb.ReadObject("/a/b", "1", &result)
+	if err != nil || result.Id != "1" {
+		t.Fatalf("Expected the entry through another form of the path but got %+v, %v", result, err)
+	}
+
+	time.Sleep(time.Millisecond * 40)
+
+	err = Database.BoltDb.ReadObject("a/b", "1", &result)
+	if err != ErrNoEntryFoundInDB {
+		t.Errorf("Expected the expiry to apply to every form of the path but got %v", err)
+	}
+
+	revision, err := Database.BoltDb.CompareAndSave("a//b", "2", testObject{Id: "2"}, 0)
+	if err != nil || revision != 1 {
+		t.Fatalf("Unexpected revision %d, %v", revision, err)
+	}
+	_, err = Database.BoltDb.CompareAndSave("/a/b/", "2", testObject{Id: "2"}, 0)
+	if err == nil {
+		t.Error("Expected a conflict for the same entry through another form of the path")
+	}
+}
+
+func TestLegacyBucketWithSeparator(t *testing.T) {
+	useTempBoltDB(t)
+
+	// A top level bucket created before paths were supported
+	err := Database.BoltDb.update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("legacy/orders"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("1"), []byte(`{"Id":"1"}`))
+	})
+	if err != nil {
+		t.Fatalf("Error creating legacy bucket: %v", err)
+	}
+
+	result := testObject{}
+	err = Database.BoltDb.ReadObject("legacy/orders", "1", &result)
+	if err != nil || result.Id != "1" {
+		t.Fatalf("Expected the legacy bucket to be resolved but got %+v, %v", result, err)
+	}
+
+	err = Database.BoltDb.SaveObject("legacy/orders", "2", testObject{Id: "2"})
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+
+	all, err := Database.BoltDb.ReadAllObjects("legacy/orders")
+	if err != nil || len(all) != 2 {
+		t.Errorf("Expected both entries in the legacy bucket but got %v, %v", all, err)
+	}
+
+	exists, _ := Database.BoltDb.BucketExists("legacy")
+	if exists {
+		t.Error("Expected no nested bucket to be created for the legacy path")
+	}
+
+	err = Database.BoltDb.RemoveBucket("legacy/orders")
+	if err != nil {
+		t.Fatalf("Error removing legacy bucket: %v", err)
+	}
+
+	exists, _ = Database.BoltDb.BucketExists("legacy/orders")
+	if exists {
+		t.Error("Expected the legacy bucket to be removed")
+	}
+}
@@ -0,0 +1,202 @@
+package platform
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"google.golang.org/protobuf/proto"
+)
+
+// Decoded objects of a bucket. The generation changes with every invalidation so that
+// a read that started before a write cannot cache the old value after the write committed
+type boltObjectCache struct {
+	lock       sync.Mutex
+	generation uint64
+	cache      *Cache
+}
+
+func (d *boltDbDatabase) cacheEnabled() bool {
+	return d.settings().Cache.Enabled
+}
+
+// Cache of the bucket or nil when the bucket is not cached
+func (d *boltDbDatabase) objectCache(bucket string) *boltObjectCache {
+	if !d.cacheEnabled() || isInternalBoltBucket(bucket) {
+		return nil
+	}
+
+	d.cacheLock.Lock()
+	defer d.cacheLock.Unlock()
+
+	if c, ok := d.caches[bucket]; ok {
+		return c
+	}
+
+	config := d.settings().Cache
+	maxEntries := config.MaxEntries
+	ttlSeconds := config.TTLSeconds
+	if len(config.Buckets) > 0 {
+		found := false
+		for _, v := range config.Buckets {
+			if cleanBoltBucketPath(v.Bucket) != bucket {
+				continue
+			}
+
+			found = true
+			if v.MaxEntries > 0 {
+				maxEntries = v.MaxEntries
+			}
+			if v.TTLSeconds > 0 {
+				ttlSeconds = v.TTLSeconds
+			}
+		}
+
+		if !found {
+			return nil
+		}
+	}
+
+	if d.caches == nil {
+		d.caches = make(map[string]*boltObjectCache)
+	}
+
+	c := &boltObjectCache{cache: NewCache(maxEntries, time.Duration(ttlSeconds)*time.Second)}
+	d.caches[bucket] = c
+
+	return c
+}
+
+// CacheStats ... Hit and miss counts of the object cache per bucket
+func (d *boltDbDatabase) CacheStats() map[string]CacheStats {
+	d.cacheLock.Lock()
+	defer d.cacheLock.Unlock()
+
+	result := make(map[string]CacheStats, len(d.caches))
+	for bucket, c := range d.caches {
+		result[bucket] = c.cache.Stats()
+	}
+
+	return result
+}
+
+// ClearCache ... Drops all cached objects, e.g. after the file was changed by another process
+func (d *boltDbDatabase) ClearCache() {
+	d.cacheLock.Lock()
+	defer d.cacheLock.Unlock()
+
+	for _, c := range d.caches {
+		c.invalidate("")
+	}
+}
+
+func (d *boltDbDatabase) dropCaches() {
+	d.cacheLock.Lock()
+	defer d.cacheLock.Unlock()
+
+	d.caches = nil
+}
+
+func (c *boltObjectCache) currentGeneration() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.generation
+}
+
+// Copies the cached value into object. Returns false on a miss or when object is of another type
+func (c *boltObjectCache) load(id string, object interface{}) bool {
+	cached, ok := c.cache.Get(id)
+	if !ok {
+		return false
+	}
+
+	if message, ok := object.(proto.Message); ok {
+		cachedMessage, ok := cached.(proto.Message)
+		if !ok || cachedMessage.ProtoReflect().Descriptor() != message.ProtoReflect().Descriptor() {
+			return false
+		}
+
+		proto.Reset(message)
+		proto.Merge(message, cachedMessage)
+		return true
+	}
+
+	target := reflect.ValueOf(object)
+	if target.Kind() != reflect.Ptr || target.IsNil() || target.Elem().Type() != reflect.TypeOf(cached) {
+		return false
+	}
+
+	target.Elem().Set(reflect.ValueOf(cached))
+
+	return true
+}
+
+// Caches a copy of the decoded object unless the entry was invalidated since generation was read.
+// Maps, slices and pointers in the object are shared with the cache and must not be modified
+func (c *boltObjectCache) store(generation uint64, id string, object interface{}, expiresAt time.Time) {
+	var value interface{}
+	if message, ok := object.(proto.Message); ok {
+		value = proto.Clone(message)
+	} else {
+		source := reflect.ValueOf(object)
+		if source.Kind() != reflect.Ptr || source.IsNil() {
+			return
+		}
+		value = source.Elem().Interface()
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+
+	// Entries with a TTL leave the cache when they expire in the database
+	ttl := c.cache.ttl
+	if !expiresAt.IsZero() {
+		remaining := time.Until(expiresAt)
+		if remaining <= 0 {
+			return
+		}
+		if ttl <= 0 || remaining < ttl {
+			ttl = remaining
+		}
+	}
+
+	c.cache.SetWithTTL(id, value, ttl)
+}
+
+// Removes the id from the cache, or everything when id is empty
+func (c *boltObjectCache) invalidate(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.generation++
+	if len(id) < 1 {
+		c.cache.Clear()
+		return
+	}
+
+	c.cache.Delete(id)
+}
+
+// Invalidates the cached object when the transaction commits. An empty id invalidates the whole bucket
+func invalidateBoltCache(tx *bolt.Tx, bucket string, id string) {
+	d := boltDatabaseFor(tx.DB())
+	if !d.cacheEnabled() {
+		return
+	}
+
+	tx.OnCommit(func() {
+		d.cacheLock.Lock()
+		c := d.caches[bucket]
+		d.cacheLock.Unlock()
+
+		if c != nil {
+			c.invalidate(id)
+		}
+	})
+}
@@ -0,0 +1,341 @@
+package platform
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/boltdb/bolt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// Codec format: marker, 'c', codec id, compression, payload
+	boltValueCodec = 'c'
+
+	boltCompressionNone = 0
+	boltCompressionZstd = 'z'
+)
+
+var (
+	ErrCodecUnknown          = errors.New("unknown codec")
+	ErrCodecDuplicate        = errors.New("codec id or name already registered")
+	ErrCodecNotProtoMessage  = errors.New("protobuf codec requires a proto.Message")
+	ErrCodecUnknownCompress  = errors.New("unknown compression in stored value")
+	ErrCodecInvalidNewObject = errors.New("newObject returned nil")
+
+	// JSONCodec ... The default codec. Values without compression are stored as plain JSON
+	JSONCodec Codec = jsonCodec{}
+	// GobCodec ... encoding/gob. Keeps Go types such as int64 and time.Time exact
+	GobCodec Codec = gobCodec{}
+	// MessagePackCodec ... Compact binary encoding that uses the msgpack struct tags, falling back to the field names
+	MessagePackCodec Codec = messagePackCodec{}
+	// ProtobufCodec ... For generated protobuf messages, e.g. the gRPC request and response types
+	ProtobufCodec Codec = protobufCodec{}
+
+	boltCodecs = map[byte]Codec{
+		JSONCodec.Id():        JSONCodec,
+		GobCodec.Id():         GobCodec,
+		MessagePackCodec.Id(): MessagePackCodec,
+		ProtobufCodec.Id():    ProtobufCodec,
+	}
+	boltBucketCodecs = make(map[string]boltBucketCodec)
+	boltCodecsLock   sync.RWMutex
+
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// Codec ... Encodes objects for storage. The id is stored with each value so it must never change
+type Codec interface {
+	Id() byte
+	Name() string
+	Marshal(object interface{}) ([]byte, error)
+	Unmarshal(data []byte, object interface{}) error
+}
+
+type boltBucketCodec struct {
+	codec    Codec
+	compress bool
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Id() byte     { return 'j' }
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(object interface{}) ([]byte, error) {
+	return json.Marshal(object)
+}
+
+func (jsonCodec) Unmarshal(data []byte, object interface{}) error {
+	return json.Unmarshal(data, object)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Id() byte     { return 'g' }
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(object interface{}) ([]byte, error) {
+	buffer := bytes.Buffer{}
+	err := gob.NewEncoder(&buffer).Encode(object)
+	return buffer.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, object interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(object)
+}
+
+type messagePackCodec struct{}
+
+func (messagePackCodec) Id() byte     { return 'm' }
+func (messagePackCodec) Name() string { return "msgpack" }
+
+func (messagePackCodec) Marshal(object interface{}) ([]byte, error) {
+	return msgpack.Marshal(object)
+}
+
+func (messagePackCodec) Unmarshal(data []byte, object interface{}) error {
+	return msgpack.Unmarshal(data, object)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Id() byte     { return 'p' }
+func (protobufCodec) Name() string { return "protobuf" }
+
+func (protobufCodec) Marshal(object interface{}) ([]byte, error) {
+	message, ok := object.(proto.Message)
+	if !ok {
+		return nil, ErrCodecNotProtoMessage
+	}
+
+	return proto.Marshal(message)
+}
+
+func (protobufCodec) Unmarshal(data []byte, object interface{}) error {
+	message, ok := object.(proto.Message)
+	if !ok {
+		return ErrCodecNotProtoMessage
+	}
+
+	return proto.Unmarshal(data, message)
+}
+
+// RegisterCodec ... Makes a custom codec available to SetBucketCodec and for reading stored values
+func RegisterCodec(codec Codec) error {
+	boltCodecsLock.Lock()
+	defer boltCodecsLock.Unlock()
+
+	for id, v := range boltCodecs {
+		if id == codec.Id() || v.Name() == codec.Name() {
+			Log.Error("Codec already registered", zap.String("codec", codec.Name()))
+			return ErrCodecDuplicate
+		}
+	}
+
+	boltCodecs[codec.Id()] = codec
+
+	return nil
+}
+
+// SetBucketCodec ... Codec used for new values in the bucket, optionally compressed with zstd.
+// Overrides platform.database.boltdb.codecs. Values written with another codec can still be read
+func SetBucketCodec(bucket string, codecName string, compress bool) error {
+	bucket = cleanBoltBucketPath(bucket)
+	codec, err := codecByName(codecName)
+	if err != nil {
+		return err
+	}
+
+	boltCodecsLock.Lock()
+	defer boltCodecsLock.Unlock()
+
+	boltBucketCodecs[bucket] = boltBucketCodec{codec: codec, compress: compress}
+
+	return nil
+}
+
+func codecByName(name string) (Codec, error) {
+	boltCodecsLock.RLock()
+	defer boltCodecsLock.RUnlock()
+
+	for _, v := range boltCodecs {
+		if v.Name() == name {
+			return v, nil
+		}
+	}
+
+	Log.Error("Unknown codec", zap.String("codec", name))
+	return nil, ErrCodecUnknown
+}
+
+func codecForBucket(tx *bolt.Tx, bucket string) (boltBucketCodec, error) {
+	boltCodecsLock.RLock()
+	selected, ok := boltBucketCodecs[bucket]
+	boltCodecsLock.RUnlock()
+	if ok {
+		return selected, nil
+	}
+
+	for _, v := range boltDatabaseFor(tx.DB()).settings().Codecs {
+		if cleanBoltBucketPath(v.Bucket) != bucket {
+			continue
+		}
+
+		codec, err := codecByName(v.Codec)
+		if err != nil {
+			return boltBucketCodec{}, err
+		}
+
+		return boltBucketCodec{codec: codec, compress: v.Compress}, nil
+	}
+
+	return boltBucketCodec{codec: JSONCodec}, nil
+}
+
+// Marshals the object with the bucket codec. Returns the stored form and,
+// for uncompressed JSON, the same value for the change feed
+func marshalBoltObject(tx *bolt.Tx, bucket string, object interface{}) ([]byte, []byte, error) {
+	selected, err := codecForBucket(tx, bucket)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload, err := selected.codec.Marshal(object)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Plain JSON stays readable by older versions
+	if selected.codec.Id() == JSONCodec.Id() && !selected.compress {
+		return payload, payload, nil
+	}
+
+	var changeValue []byte
+	if selected.codec.Id() == JSONCodec.Id() {
+		changeValue = payload
+	}
+
+	compression := byte(boltCompressionNone)
+	body := payload
+	if selected.compress {
+		compression = boltCompressionZstd
+		body = zstdEncoder.EncodeAll(payload, nil)
+	}
+
+	stored := make([]byte, 0, 4+len(body))
+	stored = append(stored, boltValueMarker, boltValueCodec, selected.codec.Id(), compression)
+
+	return append(stored, body...), changeValue, nil
+}
+
+// Removes the codec header and decompresses. Values without a header are JSON
+func boltValuePayload(value []byte) ([]byte, Codec, error) {
+	if len(value) < 1 || value[0] != boltValueMarker {
+		return value, JSONCodec, nil
+	}
+
+	if len(value) < 4 || value[1] != boltValueCodec {
+		return nil, nil, ErrBoltValueInvalid
+	}
+
+	boltCodecsLock.RLock()
+	codec, ok := boltCodecs[value[2]]
+	boltCodecsLock.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: id %q", ErrCodecUnknown, value[2])
+	}
+
+	switch value[3] {
+	case boltCompressionNone:
+		return value[4:], codec, nil
+	case boltCompressionZstd:
+		payload, err := zstdDecoder.DecodeAll(value[4:], nil)
+		return payload, codec, err
+	default:
+		return nil, nil, ErrCodecUnknownCompress
+	}
+}
+
+// Decrypts the value if needed and unmarshals it with the codec it was written with
+func unmarshalBoltObject(tx *bolt.Tx, bucket string, id string, value []byte, object interface{}) error {
+	value, err := decodeBoltValue(tx, bucket, id, value)
+	if err != nil {
+		return err
+	}
+
+	payload, codec, err := boltValuePayload(value)
+	if err != nil {
+		return err
+	}
+
+	return codec.Unmarshal(payload, object)
+}
+
+// Payload of the stored value in the format of the codec it was written with
+func readBoltPayload(tx *bolt.Tx, bucket string, id string, value []byte) ([]byte, error) {
+	value, err := decodeBoltValue(tx, bucket, id, value)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, _, err := boltValuePayload(value)
+	return payload, err
+}
+
+// MigrateBucketCodec ... Helper for migrations that rewrites the values of a bucket with its current codec.
+// newObject returns a pointer to the type stored in the bucket. Revisions are not changed
+func MigrateBucketCodec(tx *bolt.Tx, bucket string, newObject func() interface{}) error {
+	bucket = cleanBoltBucketPath(bucket)
+	b := lookupBoltBucket(tx, bucket)
+	if b == nil {
+		return nil
+	}
+
+	updates := make(map[string][]byte)
+	err := b.ForEach(func(key, value []byte) error {
+		// Nested buckets have no value
+		if value == nil {
+			return nil
+		}
+
+		object := newObject()
+		if object == nil {
+			return ErrCodecInvalidNewObject
+		}
+
+		err := unmarshalBoltObject(tx, bucket, string(key), value, object)
+		if err != nil {
+			return err
+		}
+
+		stored, _, err := marshalBoltObject(tx, bucket, object)
+		if err != nil {
+			return err
+		}
+
+		updates[string(key)], err = encodeBoltValue(tx, bucket, string(key), stored)
+		return err
+	})
+	if err != nil {
+		Log.Error("Error converting bucket codec", zap.String("bucket", bucket), zap.Error(err))
+		return err
+	}
+
+	for key, value := range updates {
+		err = b.Put([]byte(key), value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
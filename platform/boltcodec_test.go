@@ -0,0 +1,139 @@
+package platform
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func useBucketCodec(t *testing.T, bucket string, codec string, compress bool) {
+	err := SetBucketCodec(bucket, codec, compress)
+	if err != nil {
+		t.Fatalf("Unable to set codec: %v", err)
+	}
+
+	t.Cleanup(func() {
+		boltCodecsLock.Lock()
+		delete(boltBucketCodecs, bucket)
+		boltCodecsLock.Unlock()
+	})
+}
+
+func TestBucketCodecs(t *testing.T) {
+	db := useTempBoltDB(t)
+
+	for _, codec := range []string{"json", "gob", "msgpack"} {
+		for _, compress := range []bool{false, true} {
+			bucket := codec
+			if compress {
+				bucket += "-zstd"
+			}
+			useBucketCodec(t, bucket, codec, compress)
+
+			item := testObject{Id: "item", Name: bucket, Surname: string(bytes.Repeat([]byte("a"), 500))}
+			err := Database.BoltDb.SaveObject(bucket, "item", item)
+			if err != nil {
+				t.Fatalf("Error saving with %s: %v", bucket, err)
+			}
+
+			raw := readRawBoltValue(t, db, bucket, "item")
+			if codec == "json" && !compress {
+				if raw[0] != '{' {
+					t.Errorf("Expected plain JSON but got %q", raw)
+				}
+			} else if raw[0] != boltValueMarker || raw[1] != boltValueCodec {
+				t.Errorf("Expected a codec header for %s but got %q", bucket, raw[:2])
+			}
+			if compress && len(raw) > 200 {
+				t.Errorf("Expected %s to be compressed but it is %d bytes", bucket, len(raw))
+			}
+
+			result := testObject{}
+			err = Database.BoltDb.ReadObject(bucket, "item", &result)
+			if err != nil || result != item {
+				t.Errorf("Unexpected result for %s: %+v, %v", bucket, result, err)
+			}
+		}
+	}
+}
+
+func TestProtobufCodec(t *testing.T) {
+	useTempBoltDB(t)
+	useBucketCodec(t, "messages", "protobuf", false)
+
+	err := Database.BoltDb.SaveObject("messages", "greeting", wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatalf("Error saving message: %v", err)
+	}
+
+	result := &wrapperspb.StringValue{}
+	err = Database.BoltDb.ReadObject("messages", "greeting", result)
+	if err != nil || result.Value != "hello" {
+		t.Errorf("Unexpected result %v, %v", result, err)
+	}
+
+	err = Database.BoltDb.SaveObject("messages", "other", testObject{})
+	if err != ErrCodecNotProtoMessage {
+		t.Errorf("Expected ErrCodecNotProtoMessage but got %v", err)
+	}
+}
+
+func TestMixedCodecBucket(t *testing.T) {
+	db := useTempBoltDB(t)
+
+	Database.BoltDb.SaveObject("people", "json", testObject{Id: "json"})
+	useBucketCodec(t, "people", "gob", true)
+	Database.BoltDb.SaveObject("people", "gob", testObject{Id: "gob"})
+
+	for _, id := range []string{"json", "gob"} {
+		result := testObject{}
+		err := Database.BoltDb.ReadObject("people", id, &result)
+		if err != nil || result.Id != id {
+			t.Errorf("Unexpected result for %s: %+v, %v", id, result, err)
+		}
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		return MigrateBucketCodec(tx, "people", func() interface{} { return &testObject{} })
+	})
+	if err != nil {
+		t.Fatalf("Error migrating codec: %v", err)
+	}
+
+	raw := readRawBoltValue(t, db, "people", "json")
+	if raw[0] != boltValueMarker || raw[2] != GobCodec.Id() {
+		t.Errorf("Expected the JSON value to be converted to gob but got %q", raw[:3])
+	}
+}
+
+func TestCodecWithEncryption(t *testing.T) {
+	db := useTempBoltDB(t)
+	useBoltEncryption(t, "key1", "key1")
+	useBucketCodec(t, "secrets", "msgpack", true)
+
+	Database.BoltDb.SaveObject("secrets", "item", testObject{Id: "item", Name: "very secret"})
+
+	if boltValueKeyId(readRawBoltValue(t, db, "secrets", "item")) != "key1" {
+		t.Error("Expected the encoded value to be encrypted")
+	}
+
+	result := testObject{}
+	err := Database.BoltDb.ReadObject("secrets", "item", &result)
+	if err != nil || result.Name != "very secret" {
+		t.Errorf("Unexpected result %+v, %v", result, err)
+	}
+}
+
+func TestRegisterCodecDuplicate(t *testing.T) {
+	err := RegisterCodec(gobCodec{})
+	if err != ErrCodecDuplicate {
+		t.Errorf("Expected ErrCodecDuplicate but got %v", err)
+	}
+
+	err = SetBucketCodec("bucket", "unknown", false)
+	if err != ErrCodecUnknown {
+		t.Errorf("Expected ErrCodecUnknown but got %v", err)
+	}
+}
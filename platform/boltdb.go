@@ -1,26 +1,74 @@
 package platform
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"go.uber.org/zap"
 )
 
+const (
+	boltDefaultFileMode           = os.FileMode(0600)
+	boltDefaultOpenTimeoutSeconds = 5
+)
+
 var (
-	dbBolt              *bolt.DB
-	ErrBoltDBNoDBObject = errors.New("no db object")
+	ErrBoltDBNoDBObject    = errors.New("no db object")
+	ErrBoltDbLocked        = errors.New("BoltDB file is locked by another process")
+	ErrBoltDbNotConfigured = errors.New("BoltDB database is not configured")
+
+	// Named databases from platform.database.boltdatabases
+	boltDatabases     = make(map[string]*boltDbDatabase)
+	boltDatabasesLock sync.RWMutex
 )
 
 type boltDbDatabase struct {
+	name string
+	// nil for the default database, which uses platform.database.boltdb
+	config *boltDBConfig
+
+	db *bolt.DB
+	// Set when opening the database failed. Returned by every call until Open succeeds
+	openErr error
+
+	jobsLock     sync.Mutex
+	reaper       *boltExpiryReaper
+	reencryption *boltReencryptionJob
+	keys         *boltKeyring
+
+	cacheLock sync.Mutex
+	caches    map[string]*boltObjectCache
+
+	// Held for writing while Compact replaces the file
+	swapLock sync.RWMutex
 }
 
-func databaseHackToRestartServiceIKnowThisIsBad(conf *Config) {
-	os.Chmod(conf.Database.BoltDB.FileName, 0755)
-	os.Remove(conf.Database.BoltDB.FileName + ".lock")
+// BoltLockedError ... Returned when the file lock could not be taken within the open timeout.
+// errors.Is(err, ErrBoltDbLocked) matches it
+type BoltLockedError struct {
+	FileName string
+	// Process holding the lock. 0 if it could not be determined
+	Pid int
+}
+
+func (e *BoltLockedError) Error() string {
+	if e.Pid > 0 {
+		return fmt.Sprintf("BoltDB file %s is locked by process %d", e.FileName, e.Pid)
+	}
 
+	return fmt.Sprintf("BoltDB file %s is locked by another process", e.FileName)
+}
+
+func (e *BoltLockedError) Is(target error) bool {
+	return target == ErrBoltDbLocked
 }
 
 func init() {
@@ -36,84 +84,450 @@ func init() {
 		panic(errors.New("unable to get configuration"))
 	}
 	Log.Debug("Config read completed")
+
+	// Open errors are kept and returned by the database calls and when starting the server
+	for name, boltConfig := range config.Database.BoltDatabases {
+		if !boltConfig.Enabled {
+			continue
+		}
+
+		boltConfig := boltConfig
+		d := &boltDbDatabase{name: strings.ToLower(name), config: &boltConfig}
+		d.Open()
+
+		boltDatabasesLock.Lock()
+		boltDatabases[d.name] = d
+		boltDatabasesLock.Unlock()
+	}
+
 	if config.Database.BoltDB.Enabled == false {
 		Log.Info("Database BoltDb not enabled")
 		return
 	}
 
-	Log.Debug("Calling open database",
-		zap.String("filename", config.Database.BoltDB.FileName))
+	if !boltDbInUse(config) {
+		Log.Info("Database BoltDb not used by the configured backend", zap.String("backend", config.Database.Backend))
+		return
+	}
+
+	Database.BoltDb.Open()
+}
+
+// Bolt ... A named database from platform.database.boltdatabases. An empty name or "default" is Database.BoltDb.
+// Calls on a name that is not configured return ErrBoltDbNotConfigured
+func (p *PlatformDatabases) Bolt(name string) *boltDbDatabase {
+	name = strings.ToLower(name)
+	if len(name) < 1 || name == "default" {
+		return &p.BoltDb
+	}
+
+	boltDatabasesLock.RLock()
+	defer boltDatabasesLock.RUnlock()
+
+	if d, ok := boltDatabases[name]; ok {
+		return d
+	}
 
-	// Hack to make the DB file writeable
-	databaseHackToRestartServiceIKnowThisIsBad(config)
+	return &boltDbDatabase{name: name, config: &boltDBConfig{}, openErr: ErrBoltDbNotConfigured}
+}
+
+// BoltOpenError ... The first error from opening the default or a named database
+func (p *PlatformDatabases) BoltOpenError() error {
+	if err := p.BoltDb.OpenError(); err != nil {
+		return err
+	}
+
+	boltDatabasesLock.RLock()
+	defer boltDatabasesLock.RUnlock()
 
-	dbBolt, err = bolt.Open(config.Database.BoltDB.FileName, os.ModeExclusive, nil)
+	for name, d := range boltDatabases {
+		if err := d.OpenError(); err != nil {
+			return fmt.Errorf("BoltDB %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// CloseBolt ... Closes the named databases and the default database
+func (p *PlatformDatabases) CloseBolt() error {
+	boltDatabasesLock.RLock()
+	named := make([]*boltDbDatabase, 0, len(boltDatabases))
+	for _, d := range boltDatabases {
+		named = append(named, d)
+	}
+	boltDatabasesLock.RUnlock()
+
+	var result error
+	for _, d := range named {
+		err := d.Close()
+		if err != nil {
+			Log.Error("Error closing BoltDB", zap.String("name", d.name), zap.Error(err))
+			result = err
+		}
+	}
+
+	err := p.BoltDb.Close()
+	if err != nil {
+		Log.Error("Error closing BoltDB", zap.Error(err))
+		result = err
+	}
+
+	return result
+}
+
+// The database a transaction belongs to. Files opened outside of the platform lifecycle,
+// e.g. by the database command, use the default settings
+func boltDatabaseFor(db *bolt.DB) *boltDbDatabase {
+	if db != nil && Database.BoltDb.db != db {
+		boltDatabasesLock.RLock()
+		defer boltDatabasesLock.RUnlock()
+
+		for _, d := range boltDatabases {
+			if d.db == db {
+				return d
+			}
+		}
+	}
+
+	return &Database.BoltDb
+}
+
+// Settings of the database. The default database reads them from the platform configuration
+func (d *boltDbDatabase) settings() *boltDBConfig {
+	if d.config != nil {
+		return d.config
+	}
+
+	if internalConfig == nil {
+		return &boltDBConfig{}
+	}
+
+	return &internalConfig.Database.BoltDB
+}
+
+// BoltDB is opened by this package and not by another backend using the same settings
+func boltDbInUse(config *Config) bool {
+	return config.Database.BoltDB.Enabled &&
+		(len(config.Database.Backend) < 1 || config.Database.Backend == DatabaseBackendBoltDB)
+}
+
+// Open ... Opens the configured BoltDB file. Called at startup and can be called again
+// to retry after a failure, e.g. when another instance held the lock
+func (d *boltDbDatabase) Open() error {
+	if d.db != nil {
+		return nil
+	}
+
+	boltConfig := d.settings()
+
+	Log.Debug("Calling open database",
+		zap.String("name", d.name),
+		zap.String("filename", boltConfig.FileName),
+		zap.Bool("read_only", boltConfig.ReadOnly))
+
+	db, err := openBoltDatabase(boltConfig.FileName, boltConfig.FileMode, boltConfig.OpenTimeoutSeconds, boltConfig.ReadOnly)
 	if err != nil {
-		Log.Error("Error opening database", zap.Error(err))
-		panic(errors.New("Unable to open db file"))
+		Log.Error("Error opening database", zap.String("filename", boltConfig.FileName), zap.Error(err))
+		d.openErr = err
+		return err
 	}
 
+	db.NoSync = boltConfig.NoSync
+	db.NoGrowSync = boltConfig.NoGrowSync
+
+	d.db = db
+	d.openErr = nil
+
 	Log.Debug("Boltdb created without error")
+
+	if boltConfig.Expiry.ReaperEnabled && !boltConfig.ReadOnly {
+		d.StartExpiryReaper(time.Duration(boltConfig.Expiry.ReaperIntervalSeconds)*time.Second,
+			boltConfig.Expiry.ReaperBatchSize)
+	}
+
+	if boltConfig.Encryption.Enabled && boltConfig.Encryption.ReencryptEnabled && !boltConfig.ReadOnly {
+		d.StartReencryption(time.Duration(boltConfig.Encryption.ReencryptIntervalSeconds)*time.Second,
+			boltConfig.Encryption.ReencryptBatchSize)
+	}
+
+	return nil
+}
+
+// OpenError ... The error from opening the database at startup. nil if it is open or not enabled
+func (d *boltDbDatabase) OpenError() error {
+	return d.openErr
+}
+
+// File mode and lock timeout of the settings with their defaults. Shared by the BoltDB and bbolt backends
+func boltOpenOptions(fileMode string, timeoutSeconds int) (os.FileMode, time.Duration, error) {
+	mode := boltDefaultFileMode
+	if len(fileMode) > 0 {
+		parsed, err := strconv.ParseUint(fileMode, 8, 32)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid BoltDB file mode %q: %w", fileMode, err)
+		}
+		mode = os.FileMode(parsed)
+	}
+
+	if timeoutSeconds < 1 {
+		timeoutSeconds = boltDefaultOpenTimeoutSeconds
+	}
+
+	return mode, time.Duration(timeoutSeconds) * time.Second, nil
+}
+
+func openBoltDatabase(fileName string, fileMode string, timeoutSeconds int, readOnly bool) (*bolt.DB, error) {
+	mode, timeout, err := boltOpenOptions(fileMode, timeoutSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(fileName, mode, &bolt.Options{
+		Timeout:  timeout,
+		ReadOnly: readOnly,
+	})
+	if err == bolt.ErrTimeout {
+		return nil, &BoltLockedError{FileName: fileName, Pid: boltLockHolder(fileName)}
+	}
+
+	return db, err
+}
+
+// Runs fn with the open database. Compact waits until fn returns, so fn must not keep the handle
+// or call another method of the database
+func (d *boltDbDatabase) use(fn func(db *bolt.DB) error) error {
+	d.swapLock.RLock()
+	defer d.swapLock.RUnlock()
+
+	if d.db == nil {
+		if d.openErr != nil {
+			return d.openErr
+		}
+
+		Log.Error("BoltDB instance is nil", zap.String("name", d.name))
+		return ErrBoltDBNoDBObject
+	}
+
+	return fn(d.db)
+}
+
+// Runs fn in a read only transaction of the open database
+func (d *boltDbDatabase) view(fn func(tx *bolt.Tx) error) error {
+	return d.use(func(db *bolt.DB) error {
+		return db.View(fn)
+	})
+}
+
+// Runs fn in a read write transaction of the open database
+func (d *boltDbDatabase) update(fn func(tx *bolt.Tx) error) error {
+	return d.use(func(db *bolt.DB) error {
+		return db.Update(fn)
+	})
 }
 
 func (d *boltDbDatabase) Close() error {
-	return dbBolt.Close()
+	d.StopExpiryReaper()
+	d.StopReencryption()
+	d.dropCaches()
+
+	d.swapLock.Lock()
+	defer d.swapLock.Unlock()
+
+	if d.db == nil {
+		return nil
+	}
+
+	err := d.db.Close()
+	d.db = nil
+
+	return err
 }
 
 func (d *boltDbDatabase) SaveObject(bucket string, id string, object interface{}) error {
+	return d.saveObject(context.Background(), bucket, id, object, time.Time{})
+}
+
+// SaveObjectContext ... Same as SaveObject but traced as part of the request in ctx
+func (d *boltDbDatabase) SaveObjectContext(ctx context.Context, bucket string, id string, object interface{}) error {
+	return d.saveObject(ctx, bucket, id, object, time.Time{})
+}
+
+func (d *boltDbDatabase) saveObject(ctx context.Context, bucket string, id string, object interface{}, expiresAt time.Time) (err error) {
+	bucket = cleanBoltBucketPath(bucket)
+	defer observeBoltOperation(d, "save", time.Now(), &err)
+	_, span := startBoltSpan(ctx, d, "save", bucket)
+	defer endSpan(span, &err)
 
 	Log.Debug("Saving object to DB",
 		zap.String("bucket", bucket),
 		zap.String("id", id),
-		zap.Any("object", object))
+		zap.Any("object", object),
+		zap.Time("expires_at", expiresAt))
 
-	if dbBolt == nil {
-		Log.Error("BoltDB instance is nil")
-		return ErrBoltDBNoDBObject
+	err = d.update(func(tx *bolt.Tx) error {
+		_, err := putBoltObject(tx, bucket, id, object, expiresAt)
+		return err
+	})
+
+	if err != nil {
+		Log.Error("Error updating DB", zap.Error(err))
+		return err
 	}
 
-	err := dbBolt.Update(func(tx *bolt.Tx) error {
-		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
-		if err != nil {
-			Log.Error("Error creating bucket", zap.Error(err))
-			return err
-		}
+	return nil
+}
+
+// Deletes the entry of b together with its expiry, revision and index records and records the delete in the change feed
+func removeBoltObject(tx *bolt.Tx, b *bolt.Bucket, bucket string, id string) error {
+	err := b.Delete([]byte(id))
+	if err != nil {
+		Log.Error("Error removing key from bucket", zap.String("id", id), zap.String("bucket", bucket))
+		return err
+	}
+
+	err = setBoltExpiry(tx, bucket, id, time.Time{})
+	if err != nil {
+		Log.Error("Error removing expiry", zap.String("id", id), zap.String("bucket", bucket))
+		return err
+	}
+
+	invalidateBoltCache(tx, bucket, id)
 
-		data, err := json.Marshal(object)
+	err = updateBoltIndexes(tx, bucket, id, nil)
+	if err != nil {
+		Log.Error("Error removing index entries", zap.String("id", id), zap.String("bucket", bucket))
+		return err
+	}
+
+	err = recordBoltChange(tx, BoltChangeDelete, bucket, id, nil)
+	if err != nil {
+		Log.Error("Error recording change", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// Writes the object and its metadata. Returns the new revision of the entry
+func putBoltObject(tx *bolt.Tx, bucket string, id string, object interface{}, expiresAt time.Time) (uint64, error) {
+	b, err := createBoltBucket(tx, bucket)
+	if err != nil {
+		Log.Error("Error creating bucket", zap.Error(err))
+		return 0, err
+	}
+
+	marshalled, data, err := marshalBoltObject(tx, bucket, object)
+	if err != nil {
+		Log.Error("Error marshalling object", zap.Error(err))
+		return 0, err
+	}
+
+	document := data
+	if document == nil && boltIndexes(tx, bucket) != nil {
+		document, err = json.Marshal(object)
 		if err != nil {
-			Log.Error("Error marshalling object", zap.Error(err))
-			return err
+			return 0, err
 		}
+	}
+
+	return putBoltValue(tx, b, bucket, id, marshalled, data, document, expiresAt)
+}
+
+// Writes a value marshalled with a codec and its metadata: revision, expiry, cache, indexes and the change feed.
+// data is the value for the change feed and document the JSON for the indexes, both nil when there is none
+func putBoltValue(tx *bolt.Tx, b *bolt.Bucket, bucket string, id string, marshalled []byte, data []byte, document []byte, expiresAt time.Time) (uint64, error) {
+	revision, err := nextBoltRevision(tx, bucket, id)
+	if err != nil {
+		Log.Error("Error updating revision", zap.Error(err))
+		return 0, err
+	}
+
+	stored, err := encodeBoltValue(tx, bucket, id, marshalled)
+	if err != nil {
+		return 0, err
+	}
 
-		err = b.Put([]byte(id), data)
+	err = b.Put([]byte(id), stored)
+	if err != nil {
+		Log.Error("Error adding data", zap.Error(err))
+		return 0, err
+	}
+
+	err = setBoltExpiry(tx, bucket, id, expiresAt)
+	if err != nil {
+		Log.Error("Error setting expiry", zap.Error(err))
+		return 0, err
+	}
+
+	invalidateBoltCache(tx, bucket, id)
+
+	if boltIndexes(tx, bucket) != nil {
+		err = updateBoltIndexes(tx, bucket, id, document)
 		if err != nil {
-			Log.Error("Error adding data", zap.Error(err))
-			return err
+			Log.Error("Error updating indexes", zap.Error(err))
+			return 0, err
 		}
+	}
 
-		return nil
-	})
+	// Values of encrypted buckets are not kept in the change log
+	if boltDatabaseFor(tx.DB()).bucketEncrypted(bucket) {
+		data = nil
+	}
 
+	err = recordBoltChange(tx, BoltChangePut, bucket, id, data)
 	if err != nil {
-		Log.Error("Error updating DB", zap.Error(err))
-		return err
+		Log.Error("Error recording change", zap.Error(err))
+		return 0, err
 	}
 
-	return nil
+	return revision, nil
 }
 
 func (d *boltDbDatabase) ReadObject(bucket string, id string, object interface{}) error {
+	return d.ReadObjectContext(context.Background(), bucket, id, object)
+}
+
+// ReadObjectContext ... Same as ReadObject but traced as part of the request in ctx
+func (d *boltDbDatabase) ReadObjectContext(ctx context.Context, bucket string, id string, object interface{}) (err error) {
+	bucket = cleanBoltBucketPath(bucket)
+	defer observeBoltOperation(d, "read", time.Now(), &err)
+	_, span := startBoltSpan(ctx, d, "read", bucket)
+	defer endSpan(span, &err)
+
+	cache := d.objectCache(bucket)
+	var generation uint64
+	if cache != nil {
+		if cache.load(id, object) {
+			return nil
+		}
+		generation = cache.currentGeneration()
+	}
+
+	err = d.view(func(tx *bolt.Tx) error {
+		b := lookupBoltBucket(tx, bucket)
+		if b == nil {
+			Log.Warn("No entry found in the database", zap.String("id", id))
+			return ErrNoEntryFoundInDB
+		}
+
+		if boltExpiryChecker(tx, bucket)([]byte(id)) {
+			Log.Debug("Entry in the database has expired", zap.String("id", id))
+			return ErrNoEntryFoundInDB
+		}
 
-	err := dbBolt.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(bucket))
 		result := b.Get([]byte(id))
 		if len(result) > 0 {
-			err := json.Unmarshal(result, &object)
+			err := unmarshalBoltObject(tx, bucket, id, result, object)
 			if err != nil {
 				Log.Error("Error marshalling DB response", zap.Error(err))
 				return err
 			}
 
+			if cache != nil {
+				cache.store(generation, id, object, boltExpiryOf(tx, bucket, id))
+			}
+
 		} else {
 			Log.Warn("No entry found in the database", zap.String("id", id))
 			return ErrNoEntryFoundInDB
@@ -129,20 +543,33 @@ func (d *boltDbDatabase) ReadObject(bucket string, id string, object interface{}
 	return nil
 }
 
-// Returns all entries in the bucket. Values are still json strings
-func (d *boltDbDatabase) ReadAllObjects(bucket string) (map[string]string, error) {
+// Returns all entries in the bucket. Values are still json strings, or in the format of the bucket codec
+func (d *boltDbDatabase) ReadAllObjects(bucket string) (_ map[string]string, err error) {
+	bucket = cleanBoltBucketPath(bucket)
+	defer observeBoltOperation(d, "read_all", time.Now(), &err)
 
 	results := make(map[string]string)
 
-	err := dbBolt.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(bucket))
+	err = d.view(func(tx *bolt.Tx) error {
+		b := lookupBoltBucket(tx, bucket)
 		if b == nil {
 			return ErrNoEntryFoundInDB
 		}
 
+		expired := boltExpiryChecker(tx, bucket)
 		cursor := b.Cursor()
 
 		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+			// Nested buckets have no value
+			if value == nil || expired(key) {
+				continue
+			}
+
+			value, err := readBoltPayload(tx, bucket, string(key), value)
+			if err != nil {
+				return err
+			}
+
 			results[string(key)] = string(value)
 		}
 		return nil
@@ -156,29 +583,28 @@ func (d *boltDbDatabase) ReadAllObjects(bucket string) (map[string]string, error
 }
 
 func (d *boltDbDatabase) RemoveObject(bucket string, id string) error {
+	return d.RemoveObjectContext(context.Background(), bucket, id)
+}
+
+// RemoveObjectContext ... Same as RemoveObject but traced as part of the request in ctx
+func (d *boltDbDatabase) RemoveObjectContext(ctx context.Context, bucket string, id string) (err error) {
+	bucket = cleanBoltBucketPath(bucket)
+	defer observeBoltOperation(d, "remove", time.Now(), &err)
+	_, span := startBoltSpan(ctx, d, "remove", bucket)
+	defer endSpan(span, &err)
 
 	Log.Debug("Removing object from bucket",
 		zap.String("bucket", bucket),
 		zap.String("id", id))
 
-	if dbBolt == nil {
-		Log.Error("BoltDB instance is nil")
-		return ErrBoltDBNoDBObject
-	}
-
-	err := dbBolt.Update(func(tx *bolt.Tx) error {
-		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+	err = d.update(func(tx *bolt.Tx) error {
+		b, err := createBoltBucket(tx, bucket)
 		if err != nil {
 			Log.Error("Error creating bucket", zap.Error(err))
 			return err
 		}
 
-		err = b.Delete([]byte(id))
-		if err != nil {
-			Log.Error("Error removing key from bucket", zap.String("id", id), zap.String("bucket", bucket))
-		}
-
-		return nil
+		return removeBoltObject(tx, b, bucket, id)
 	})
 
 	if err != nil {
@@ -189,27 +615,50 @@ func (d *boltDbDatabase) RemoveObject(bucket string, id string) error {
 	return nil
 }
 
-func (d *boltDbDatabase) RemoveBucket(bucket string) error {
+func (d *boltDbDatabase) RemoveBucket(bucket string) (err error) {
+	bucket = cleanBoltBucketPath(bucket)
+	defer observeBoltOperation(d, "remove_bucket", time.Now(), &err)
 
 	Log.Debug("Deleting bucket",
 		zap.String("bucket", bucket))
 
-	if dbBolt == nil {
-		Log.Error("BoltDB instance is nil")
-		return ErrBoltDBNoDBObject
-	}
-
-	err := dbBolt.Update(func(tx *bolt.Tx) error {
-		err := tx.DeleteBucket([]byte(bucket))
-		if err != nil && err == bolt.ErrBucketNotFound {
+	err = d.update(func(tx *bolt.Tx) error {
+		b := lookupBoltBucket(tx, bucket)
+		if b == nil {
 			return nil
 		}
 
+		// Nested buckets are deleted with their parent. Their metadata is removed per path
+		paths := nestedBoltBucketPaths(b, bucket)
+
+		err := deleteBoltBucket(tx, bucket)
 		if err != nil {
 			Log.Error("Error removing bucket", zap.Error(err))
 			return err
 		}
 
+		for _, path := range paths {
+			err = removeBoltExpiryBucket(tx, path)
+			if err != nil {
+				Log.Error("Error removing expiry bucket", zap.Error(err))
+				return err
+			}
+
+			invalidateBoltCache(tx, path, "")
+
+			err = clearBoltIndexes(tx, path)
+			if err != nil {
+				Log.Error("Error clearing indexes", zap.Error(err))
+				return err
+			}
+
+			err = recordBoltChange(tx, BoltChangeDelete, path, "", nil)
+			if err != nil {
+				Log.Error("Error recording change", zap.Error(err))
+				return err
+			}
+		}
+
 		return nil
 	})
 
@@ -225,17 +674,13 @@ func (d *boltDbDatabase) RemoveDBFile() error {
 
 	Log.Debug("Removing BoltDB file")
 
-	if dbBolt == nil {
-		Log.Error("BoltDB instance is nil")
-	}
-
-	err := dbBolt.Close()
+	err := d.Close()
 	if err != nil {
 		Log.Error("Error closing BoltDB", zap.Error(err))
 		return err
 	}
 
-	err = os.Remove(internalConfig.Database.BoltDB.FileName)
+	err = os.Remove(d.settings().FileName)
 	if err != nil {
 		Log.Error("Error removing BoltDB file", zap.Error(err))
 		return err
@@ -243,3 +688,112 @@ func (d *boltDbDatabase) RemoveDBFile() error {
 
 	return nil
 }
+
+// ForEach ... Calls fn for each unexpired entry in the bucket in key order
+func (d *boltDbDatabase) ForEach(bucket string, fn func(id string, value []byte) error) error {
+	return d.View(func(tx KeyValueTx) error {
+		return tx.ForEach(bucket, fn)
+	})
+}
+
+// View ... Runs fn in a read only BoltDB transaction
+func (d *boltDbDatabase) View(fn func(tx KeyValueTx) error) error {
+	return d.ViewContext(context.Background(), fn)
+}
+
+// ViewContext ... Same as View but the transaction is traced as part of the request in ctx
+func (d *boltDbDatabase) ViewContext(ctx context.Context, fn func(tx KeyValueTx) error) (err error) {
+	_, span := startBoltSpan(ctx, d, "view", "")
+	defer endSpan(span, &err)
+
+	return d.view(func(tx *bolt.Tx) error {
+		return fn(&boltKeyValueTx{tx: tx})
+	})
+}
+
+// Update ... Runs fn in a read write BoltDB transaction. The transaction is rolled back if fn returns an error
+func (d *boltDbDatabase) Update(fn func(tx KeyValueTx) error) error {
+	return d.UpdateContext(context.Background(), fn)
+}
+
+// UpdateContext ... Same as Update but the transaction is traced as part of the request in ctx
+func (d *boltDbDatabase) UpdateContext(ctx context.Context, fn func(tx KeyValueTx) error) (err error) {
+	_, span := startBoltSpan(ctx, d, "update", "")
+	defer endSpan(span, &err)
+
+	return d.update(func(tx *bolt.Tx) error {
+		return fn(&boltKeyValueTx{tx: tx})
+	})
+}
+
+type boltKeyValueTx struct {
+	tx *bolt.Tx
+}
+
+func (t *boltKeyValueTx) SaveObject(bucket string, id string, object interface{}) error {
+	bucket = cleanBoltBucketPath(bucket)
+	if !t.tx.Writable() {
+		return ErrDatabaseReadOnlyTx
+	}
+
+	_, err := putBoltObject(t.tx, bucket, id, object, time.Time{})
+	return err
+}
+
+func (t *boltKeyValueTx) ReadObject(bucket string, id string, object interface{}) error {
+	bucket = cleanBoltBucketPath(bucket)
+	b := lookupBoltBucket(t.tx, bucket)
+	if b == nil || boltExpiryChecker(t.tx, bucket)([]byte(id)) {
+		return ErrNoEntryFoundInDB
+	}
+
+	result := b.Get([]byte(id))
+	if len(result) < 1 {
+		return ErrNoEntryFoundInDB
+	}
+
+	return unmarshalBoltObject(t.tx, bucket, id, result, object)
+}
+
+func (t *boltKeyValueTx) RemoveObject(bucket string, id string) error {
+	bucket = cleanBoltBucketPath(bucket)
+	if !t.tx.Writable() {
+		return ErrDatabaseReadOnlyTx
+	}
+
+	b := lookupBoltBucket(t.tx, bucket)
+	if b == nil {
+		return nil
+	}
+
+	return removeBoltObject(t.tx, b, bucket, id)
+}
+
+func (t *boltKeyValueTx) ForEach(bucket string, fn func(id string, value []byte) error) error {
+	bucket = cleanBoltBucketPath(bucket)
+	b := lookupBoltBucket(t.tx, bucket)
+	if b == nil {
+		return nil
+	}
+
+	expired := boltExpiryChecker(t.tx, bucket)
+	cursor := b.Cursor()
+	for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+		// Nested buckets have no value
+		if value == nil || expired(key) {
+			continue
+		}
+
+		value, err := readBoltPayload(t.tx, bucket, string(key), value)
+		if err != nil {
+			return err
+		}
+
+		err = fn(string(key), value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
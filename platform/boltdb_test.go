@@ -1,11 +1,35 @@
 package platform
 
 import (
+	"errors"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
 )
 
+// Swaps the package BoltDB for a new file in a temp directory for the duration of the test
+func useTempBoltDB(t *testing.T) *bolt.DB {
+	previous := Database.BoltDb.db
+
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Unable to open temp BoltDB: %v", err)
+	}
+
+	Database.BoltDb.db = db
+	t.Cleanup(func() {
+		db.Close()
+		Database.BoltDb.db = previous
+	})
+
+	return db
+}
+
 func TestStoreAndReadObjectInBoltDB(t *testing.T) {
 	os.Remove("./database.db")
 	os.Remove("./database.db.lock")
@@ -87,3 +111,101 @@ func TestDBCleanup(t *testing.T) {
 	}
 
 }
+
+func TestOpenBoltDatabaseLocked(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "locked.db")
+
+	db, err := openBoltDatabase(fileName, "0640", 1, false)
+	if err != nil {
+		t.Fatalf("Unable to open BoltDB: %v", err)
+	}
+	defer db.Close()
+
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatalf("Unable to stat BoltDB file: %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected file mode 0640 but got %v", info.Mode().Perm())
+	}
+
+	_, err = openBoltDatabase(fileName, "", 1, false)
+	if !errors.Is(err, ErrBoltDbLocked) {
+		t.Fatalf("Expected the file to be locked but got %v", err)
+	}
+
+	locked := &BoltLockedError{}
+	if !errors.As(err, &locked) || locked.FileName != fileName {
+		t.Errorf("Expected a BoltLockedError for %s but got %v", fileName, err)
+	}
+	if runtime.GOOS == "linux" && locked.Pid != os.Getpid() {
+		t.Errorf("Expected the lock holder to be %d but got %d", os.Getpid(), locked.Pid)
+	}
+}
+
+func TestOpenBoltDatabaseInvalidFileMode(t *testing.T) {
+	_, err := openBoltDatabase(filepath.Join(t.TempDir(), "mode.db"), "rw", 1, false)
+	if err == nil {
+		t.Error("Expected an error for an invalid file mode")
+	}
+}
+
+func TestNamedBoltDatabases(t *testing.T) {
+	useTempBoltDB(t)
+
+	config := &boltDBConfig{Enabled: true, FileName: filepath.Join(t.TempDir(), "cache.db")}
+	config.ChangeFeed.Enabled = true
+	cache := &boltDbDatabase{name: "cache", config: config}
+	err := cache.Open()
+	if err != nil {
+		t.Fatalf("Unable to open named database: %v", err)
+	}
+
+	boltDatabasesLock.Lock()
+	boltDatabases["cache"] = cache
+	boltDatabasesLock.Unlock()
+	t.Cleanup(func() {
+		boltDatabasesLock.Lock()
+		delete(boltDatabases, "cache")
+		boltDatabasesLock.Unlock()
+		cache.Close()
+	})
+
+	if Database.Bolt("Cache") != cache || Database.Bolt("default") != &Database.BoltDb {
+		t.Fatal("Expected Bolt to return the named and the default database")
+	}
+
+	watcher, err := Database.Bolt("cache").Watch("items", "")
+	if err != nil {
+		t.Fatalf("Error watching named database: %v", err)
+	}
+	defer watcher.Close()
+
+	_, err = Database.BoltDb.Watch("items", "")
+	if err != ErrBoltChangeFeedNotEnabled {
+		t.Errorf("Expected the default database to use its own settings but got %v", err)
+	}
+
+	Database.BoltDb.SaveObject("items", "default", testObject{Id: "default"})
+	Database.Bolt("cache").SaveObject("items", "cached", testObject{Id: "cached"})
+
+	event := receiveChangeEvent(t, watcher)
+	if event.Id != "cached" {
+		t.Errorf("Expected only changes of the named database but got %+v", event)
+	}
+
+	err = Database.Bolt("cache").ReadObject("items", "default", &testObject{})
+	if err != ErrNoEntryFoundInDB {
+		t.Errorf("Expected the databases to be separate but got %v", err)
+	}
+
+	err = Database.Bolt("missing").SaveObject("items", "a", testObject{})
+	if err != ErrBoltDbNotConfigured {
+		t.Errorf("Expected ErrBoltDbNotConfigured but got %v", err)
+	}
+
+	err = Database.CloseBolt()
+	if err != nil || cache.db != nil {
+		t.Errorf("Expected the named database to be closed: %v", err)
+	}
+}
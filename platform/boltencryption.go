@@ -0,0 +1,585 @@
+package platform
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"go.uber.org/zap"
+)
+
+const (
+	// Holds the Transit wrapped data keys and the active data key id
+	boltEncryptionBucket = "_encryption"
+	boltEncryptionKeys   = "keys"
+
+	// Encoded values start with a byte that can never start a JSON document, followed by the format
+	boltValueMarker    = 0x00
+	boltValueEncrypted = 'e'
+
+	boltEncryptionKeySize            = 32
+	boltTransitDefaultMount          = "transit"
+	boltReencryptDefaultIntervalSecs = 300
+	boltReencryptDefaultBatchSize    = 500
+)
+
+var (
+	ErrBoltEncryptionNotEnabled  = errors.New("BoltDB encryption is not enabled")
+	ErrBoltEncryptionNoActiveKey = errors.New("no active encryption key configured")
+	ErrBoltEncryptionKeyNotFound = errors.New("encryption key not found")
+	ErrBoltEncryptionInvalidKey  = errors.New("encryption key must be 32 bytes base64 encoded")
+	ErrBoltValueInvalid          = errors.New("stored value has an unknown format")
+
+	boltEncryptionActiveKey = []byte("active")
+)
+
+type boltKeyring struct {
+	lock   sync.Mutex
+	loaded bool
+	keys   map[string]cipher.AEAD
+}
+
+type boltReencryptionJob struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (d *boltDbDatabase) encryptionEnabled() bool {
+	return d.settings().Encryption.Enabled
+}
+
+func (d *boltDbDatabase) bucketEncrypted(bucket string) bool {
+	if !d.encryptionEnabled() {
+		return false
+	}
+
+	for _, v := range d.settings().Encryption.Buckets {
+		if cleanBoltBucketPath(v) == bucket {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *boltDbDatabase) transitEnabled() bool {
+	return len(d.settings().Encryption.TransitKey) > 0
+}
+
+func (d *boltDbDatabase) transitPath(operation string) string {
+	mount := d.settings().Encryption.TransitMount
+	if len(mount) < 1 {
+		mount = boltTransitDefaultMount
+	}
+
+	return mount + "/" + operation + "/" + d.settings().Encryption.TransitKey
+}
+
+func (d *boltDbDatabase) keyring() *boltKeyring {
+	d.jobsLock.Lock()
+	defer d.jobsLock.Unlock()
+
+	if d.keys == nil {
+		d.keys = &boltKeyring{}
+	}
+
+	return d.keys
+}
+
+func newBoltCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != boltEncryptionKeySize {
+		return nil, ErrBoltEncryptionInvalidKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func parseBoltKey(encoded string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrBoltEncryptionInvalidKey
+	}
+
+	return newBoltCipher(key)
+}
+
+// Loads the keys from config and Vault on first use. Vault is initialized after this package's database
+func (k *boltKeyring) load(config *boltDBConfig) error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if k.loaded {
+		return nil
+	}
+
+	keys := make(map[string]cipher.AEAD)
+	for _, v := range config.Encryption.Keys {
+		aead, err := parseBoltKey(v.Key)
+		if err != nil {
+			Log.Error("Invalid encryption key in config", zap.String("key_id", v.Id), zap.Error(err))
+			return err
+		}
+		keys[v.Id] = aead
+	}
+
+	if path := config.Encryption.VaultPath; len(path) > 0 {
+		secrets, err := Vault.GetSecrets(path)
+		if err != nil {
+			Log.Error("Unable to read encryption keys from Vault", zap.String("path", path), zap.Error(err))
+			return err
+		}
+
+		for id, encoded := range secrets {
+			aead, err := parseBoltKey(encoded)
+			if err != nil {
+				Log.Error("Invalid encryption key in Vault", zap.String("key_id", id), zap.Error(err))
+				return err
+			}
+			keys[id] = aead
+		}
+	}
+
+	k.keys = keys
+	k.loaded = true
+
+	return nil
+}
+
+func (k *boltKeyring) get(id string) (cipher.AEAD, bool) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	aead, ok := k.keys[id]
+	return aead, ok
+}
+
+func (k *boltKeyring) add(id string, aead cipher.AEAD) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	k.keys[id] = aead
+}
+
+// Finds the key by id. Transit data keys are unwrapped with Vault the first time they are used
+func boltEncryptionKey(tx *bolt.Tx, id string) (cipher.AEAD, error) {
+	d := boltDatabaseFor(tx.DB())
+	keys := d.keyring()
+	err := keys.load(d.settings())
+	if err != nil {
+		return nil, err
+	}
+
+	if aead, ok := keys.get(id); ok {
+		return aead, nil
+	}
+
+	if !d.transitEnabled() {
+		return nil, ErrBoltEncryptionKeyNotFound
+	}
+
+	encryption := tx.Bucket([]byte(boltEncryptionBucket))
+	if encryption == nil || encryption.Bucket([]byte(boltEncryptionKeys)) == nil {
+		return nil, ErrBoltEncryptionKeyNotFound
+	}
+
+	wrapped := encryption.Bucket([]byte(boltEncryptionKeys)).Get([]byte(id))
+	if wrapped == nil {
+		return nil, ErrBoltEncryptionKeyNotFound
+	}
+
+	result, err := Vault.Write(d.transitPath("decrypt"), map[string]interface{}{
+		"ciphertext": string(wrapped),
+	})
+	if err != nil {
+		Log.Error("Unable to unwrap data key with Vault Transit", zap.String("key_id", id), zap.Error(err))
+		return nil, err
+	}
+
+	plaintext, _ := result["plaintext"].(string)
+	aead, err := parseBoltKey(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	keys.add(id, aead)
+
+	return aead, nil
+}
+
+// Id of the key used for new values. With Transit a data key is created the first time
+func activeBoltEncryptionKey(tx *bolt.Tx) (string, cipher.AEAD, error) {
+	d := boltDatabaseFor(tx.DB())
+	if !d.transitEnabled() {
+		id := d.settings().Encryption.ActiveKeyId
+		if len(id) < 1 {
+			return "", nil, ErrBoltEncryptionNoActiveKey
+		}
+
+		aead, err := boltEncryptionKey(tx, id)
+		return id, aead, err
+	}
+
+	if encryption := tx.Bucket([]byte(boltEncryptionBucket)); encryption != nil {
+		if id := encryption.Get(boltEncryptionActiveKey); id != nil {
+			aead, err := boltEncryptionKey(tx, string(id))
+			return string(id), aead, err
+		}
+	}
+
+	if !tx.Writable() {
+		return "", nil, ErrBoltEncryptionNoActiveKey
+	}
+
+	return newBoltTransitKey(tx)
+}
+
+// Generates a data key wrapped by the Transit key and makes it the active key
+func newBoltTransitKey(tx *bolt.Tx) (string, cipher.AEAD, error) {
+	d := boltDatabaseFor(tx.DB())
+	keys := d.keyring()
+	err := keys.load(d.settings())
+	if err != nil {
+		return "", nil, err
+	}
+
+	result, err := Vault.Write(d.transitPath("datakey/plaintext"), nil)
+	if err != nil {
+		Log.Error("Unable to create data key with Vault Transit", zap.Error(err))
+		return "", nil, err
+	}
+
+	plaintext, _ := result["plaintext"].(string)
+	wrapped, _ := result["ciphertext"].(string)
+	aead, err := parseBoltKey(plaintext)
+	if err != nil {
+		return "", nil, err
+	}
+
+	hash := sha256.Sum256([]byte(wrapped))
+	id := "transit-" + hex.EncodeToString(hash[:8])
+
+	encryption, err := tx.CreateBucketIfNotExists([]byte(boltEncryptionBucket))
+	if err != nil {
+		return "", nil, err
+	}
+
+	wrappedKeys, err := encryption.CreateBucketIfNotExists([]byte(boltEncryptionKeys))
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = wrappedKeys.Put([]byte(id), []byte(wrapped))
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = encryption.Put(boltEncryptionActiveKey, []byte(id))
+	if err != nil {
+		return "", nil, err
+	}
+
+	keys.add(id, aead)
+	Log.Info("Created new BoltDB data key", zap.String("key_id", id))
+
+	return id, aead, nil
+}
+
+// The entry location is authenticated so that a value can not be copied to another key
+func boltValueAdditionalData(bucket string, id string) []byte {
+	return []byte(bucket + "\x00" + id)
+}
+
+// Encrypts the value if the bucket is configured for encryption
+func encodeBoltValue(tx *bolt.Tx, bucket string, id string, value []byte) ([]byte, error) {
+	if !boltDatabaseFor(tx.DB()).bucketEncrypted(bucket) {
+		return value, nil
+	}
+
+	keyId, aead, err := activeBoltEncryptionKey(tx)
+	if err != nil {
+		Log.Error("Unable to get encryption key", zap.String("bucket", bucket), zap.Error(err))
+		return nil, err
+	}
+
+	return sealBoltValue(aead, keyId, bucket, id, value)
+}
+
+// Format: marker, 'e', key id length, key id, nonce, ciphertext
+func sealBoltValue(aead cipher.AEAD, keyId string, bucket string, id string, value []byte) ([]byte, error) {
+	if len(keyId) < 1 || len(keyId) > 255 {
+		return nil, fmt.Errorf("invalid encryption key id %q", keyId)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, 0, 3+len(keyId)+len(nonce)+len(value)+aead.Overhead())
+	result = append(result, boltValueMarker, boltValueEncrypted, byte(len(keyId)))
+	result = append(result, keyId...)
+	result = append(result, nonce...)
+
+	return aead.Seal(result, nonce, value, boltValueAdditionalData(bucket, id)), nil
+}
+
+// Key id of an encrypted value. Empty if the value is not encrypted
+func boltValueKeyId(value []byte) string {
+	if len(value) < 3 || value[0] != boltValueMarker || value[1] != boltValueEncrypted {
+		return ""
+	}
+
+	if len(value) < 3+int(value[2]) {
+		return ""
+	}
+
+	return string(value[3 : 3+int(value[2])])
+}
+
+// Decrypts the stored value. Values that are not encrypted are returned as is
+func decodeBoltValue(tx *bolt.Tx, bucket string, id string, value []byte) ([]byte, error) {
+	if len(value) < 2 || value[0] != boltValueMarker || value[1] != boltValueEncrypted {
+		return value, nil
+	}
+
+	keyId := boltValueKeyId(value)
+	if len(keyId) < 1 {
+		return nil, ErrBoltValueInvalid
+	}
+
+	aead, err := boltEncryptionKey(tx, keyId)
+	if err != nil {
+		Log.Error("Unable to get decryption key", zap.String("bucket", bucket), zap.String("key_id", keyId), zap.Error(err))
+		return nil, err
+	}
+
+	body := value[3+len(keyId):]
+	if len(body) < aead.NonceSize() {
+		return nil, ErrBoltValueInvalid
+	}
+
+	plaintext, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], boltValueAdditionalData(bucket, id))
+	if err != nil {
+		Log.Error("Unable to decrypt value", zap.String("bucket", bucket), zap.String("id", id), zap.Error(err))
+		return nil, err
+	}
+
+	return plaintext, nil
+}
+
+// ReencryptValues ... Rewrites values in the encrypted buckets that are not encrypted with the active key.
+// Revisions are not changed and no change events are recorded. Returns the number of values rewritten
+func (d *boltDbDatabase) ReencryptValues(batchSize int) (int, error) {
+	var count int
+	err := d.use(func(db *bolt.DB) error {
+		var err error
+		count, err = reencryptBoltValues(db, batchSize)
+		return err
+	})
+
+	return count, err
+}
+
+// RotateEncryptionKey ... With Vault Transit a new data key is created. With configured keys ActiveKeyId is used.
+// All values are then re-encrypted with the active key. Returns the active key id and the number of values rewritten
+func (d *boltDbDatabase) RotateEncryptionKey() (string, int, error) {
+	var keyId string
+	var count int
+	err := d.use(func(db *bolt.DB) error {
+		var err error
+		keyId, count, err = rotateBoltEncryptionKey(db)
+		return err
+	})
+
+	return keyId, count, err
+}
+
+func rotateBoltEncryptionKey(db *bolt.DB) (string, int, error) {
+	d := boltDatabaseFor(db)
+	if !d.encryptionEnabled() {
+		return "", 0, ErrBoltEncryptionNotEnabled
+	}
+
+	var keyId string
+	err := db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if d.transitEnabled() {
+			keyId, _, err = newBoltTransitKey(tx)
+		} else {
+			keyId, _, err = activeBoltEncryptionKey(tx)
+		}
+		return err
+	})
+	if err != nil {
+		Log.Error("Unable to rotate encryption key", zap.Error(err))
+		return "", 0, err
+	}
+
+	Log.Info("Rotating BoltDB values to encryption key", zap.String("key_id", keyId))
+
+	count, err := reencryptBoltValues(db, boltReencryptDefaultBatchSize)
+	return keyId, count, err
+}
+
+func reencryptBoltValues(db *bolt.DB, batchSize int) (int, error) {
+	d := boltDatabaseFor(db)
+	if !d.encryptionEnabled() {
+		return 0, ErrBoltEncryptionNotEnabled
+	}
+
+	if batchSize < 1 {
+		batchSize = boltReencryptDefaultBatchSize
+	}
+
+	total := 0
+	for _, bucket := range d.settings().Encryption.Buckets {
+		bucket = cleanBoltBucketPath(bucket)
+		// Each batch is its own transaction and continues after the last key of the previous one
+		var after []byte
+		for {
+			count := 0
+			var last []byte
+
+			err := db.Update(func(tx *bolt.Tx) error {
+				b := lookupBoltBucket(tx, bucket)
+				if b == nil {
+					return nil
+				}
+
+				activeId, aead, err := activeBoltEncryptionKey(tx)
+				if err != nil {
+					return err
+				}
+
+				updates := make(map[string][]byte)
+				cursor := b.Cursor()
+				key, value := cursor.First()
+				if after != nil {
+					key, value = cursor.Seek(after)
+					if key != nil && bytes.Equal(key, after) {
+						key, value = cursor.Next()
+					}
+				}
+
+				for ; key != nil && len(updates) < batchSize; key, value = cursor.Next() {
+					last = append([]byte{}, key...)
+
+					// Nested buckets have no value
+					if value == nil || boltValueKeyId(value) == activeId {
+						continue
+					}
+
+					plaintext, err := decodeBoltValue(tx, bucket, string(key), value)
+					if err != nil {
+						return err
+					}
+
+					encrypted, err := sealBoltValue(aead, activeId, bucket, string(key), plaintext)
+					if err != nil {
+						return err
+					}
+
+					updates[string(key)] = encrypted
+				}
+
+				for k, v := range updates {
+					err = b.Put([]byte(k), v)
+					if err != nil {
+						return err
+					}
+				}
+
+				count = len(updates)
+				return nil
+			})
+			if err != nil {
+				Log.Error("Error re-encrypting values", zap.String("bucket", bucket), zap.Error(err))
+				return total, err
+			}
+
+			total += count
+			if last == nil {
+				break
+			}
+			after = last
+		}
+	}
+
+	if total > 0 {
+		Log.Info("Re-encrypted BoltDB values", zap.Int("count", total))
+	}
+
+	return total, nil
+}
+
+// StartReencryption ... Periodically re-encrypts values written with an older key.
+// Stopped by StopReencryption or Close
+func (d *boltDbDatabase) StartReencryption(interval time.Duration, batchSize int) {
+	d.jobsLock.Lock()
+	defer d.jobsLock.Unlock()
+
+	if d.reencryption != nil {
+		Log.Warn("BoltDB re-encryption already running")
+		return
+	}
+
+	if interval <= 0 {
+		interval = time.Second * boltReencryptDefaultIntervalSecs
+	}
+
+	job := &boltReencryptionJob{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	d.reencryption = job
+
+	Log.Info("Starting BoltDB re-encryption", zap.Duration("interval", interval), zap.Int("batch_size", batchSize))
+
+	go func() {
+		defer close(job.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-job.stop:
+				return
+			case <-ticker.C:
+				d.ReencryptValues(batchSize)
+			}
+		}
+	}()
+}
+
+// StopReencryption ... Stops the background re-encryption and waits for a running pass to complete
+func (d *boltDbDatabase) StopReencryption() {
+	d.jobsLock.Lock()
+	job := d.reencryption
+	d.reencryption = nil
+	d.jobsLock.Unlock()
+
+	if job == nil {
+		return
+	}
+
+	close(job.stop)
+	<-job.done
+
+	Log.Info("BoltDB re-encryption stopped")
+}
@@ -0,0 +1,154 @@
+package platform
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func useBoltEncryption(t *testing.T, activeKeyId string, keyIds ...string) {
+	previous := internalConfig.Database.BoltDB.Encryption
+	previousKeys := Database.BoltDb.keys
+
+	encryption := &internalConfig.Database.BoltDB.Encryption
+	encryption.Enabled = true
+	encryption.Buckets = []string{"secrets"}
+	encryption.ActiveKeyId = activeKeyId
+	encryption.TransitKey = ""
+	encryption.VaultPath = ""
+	encryption.Keys = nil
+	for i, id := range keyIds {
+		key := bytes.Repeat([]byte{byte(i + 1)}, boltEncryptionKeySize)
+		encryption.Keys = append(encryption.Keys, struct {
+			Id  string
+			Key string
+		}{Id: id, Key: base64.StdEncoding.EncodeToString(key)})
+	}
+	Database.BoltDb.keys = nil
+
+	t.Cleanup(func() {
+		internalConfig.Database.BoltDB.Encryption = previous
+		Database.BoltDb.keys = previousKeys
+	})
+}
+
+func readRawBoltValue(t *testing.T, db *bolt.DB, bucket string, id string) []byte {
+	var value []byte
+	db.View(func(tx *bolt.Tx) error {
+		value = append([]byte{}, tx.Bucket([]byte(bucket)).Get([]byte(id))...)
+		return nil
+	})
+
+	return value
+}
+
+func TestEncryptedBucket(t *testing.T) {
+	db := useTempBoltDB(t)
+	useBoltEncryption(t, "key1", "key1")
+
+	err := Database.BoltDb.SaveObject("secrets", "item", testObject{Id: "item", Name: "very secret"})
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+	Database.BoltDb.SaveObject("plain", "item", testObject{Id: "item", Name: "not secret"})
+
+	raw := readRawBoltValue(t, db, "secrets", "item")
+	if bytes.Contains(raw, []byte("very secret")) || boltValueKeyId(raw) != "key1" {
+		t.Errorf("Expected the value to be encrypted with key1: %q", raw)
+	}
+
+	raw = readRawBoltValue(t, db, "plain", "item")
+	if !bytes.Contains(raw, []byte("not secret")) {
+		t.Errorf("Expected the value of an unencrypted bucket to be JSON: %q", raw)
+	}
+
+	result := testObject{}
+	err = Database.BoltDb.ReadObject("secrets", "item", &result)
+	if err != nil || result.Name != "very secret" {
+		t.Errorf("Unexpected result %+v, %v", result, err)
+	}
+
+	all, err := Database.BoltDb.ReadAllObjects("secrets")
+	if err != nil || all["item"] != `{"Id":"item","Name":"very secret","Surname":""}` {
+		t.Errorf("Unexpected values %v, %v", all, err)
+	}
+}
+
+func TestEncryptedValueBoundToKey(t *testing.T) {
+	db := useTempBoltDB(t)
+	useBoltEncryption(t, "key1", "key1")
+
+	Database.BoltDb.SaveObject("secrets", "a", testObject{Id: "a"})
+
+	// Copying the ciphertext to another id must not decrypt
+	db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("secrets"))
+		return b.Put([]byte("b"), append([]byte{}, b.Get([]byte("a"))...))
+	})
+
+	result := testObject{}
+	err := Database.BoltDb.ReadObject("secrets", "b", &result)
+	if err == nil {
+		t.Error("Expected copied ciphertext to fail authentication")
+	}
+}
+
+func TestRotateEncryptionKey(t *testing.T) {
+	db := useTempBoltDB(t)
+	useBoltEncryption(t, "key1", "key1", "key2")
+
+	// Written before the bucket was encrypted
+	internalConfig.Database.BoltDB.Encryption.Enabled = false
+	Database.BoltDb.SaveObject("secrets", "legacy", testObject{Id: "legacy"})
+	internalConfig.Database.BoltDB.Encryption.Enabled = true
+
+	for _, id := range []string{"a", "b", "c"} {
+		Database.BoltDb.SaveObject("secrets", id, testObject{Id: id})
+	}
+
+	revision, _ := Database.BoltDb.ReadObjectWithRevision("secrets", "a", &testObject{})
+
+	internalConfig.Database.BoltDB.Encryption.ActiveKeyId = "key2"
+	keyId, count, err := Database.BoltDb.RotateEncryptionKey()
+	if err != nil || keyId != "key2" || count != 4 {
+		t.Fatalf("Unexpected rotation result %s, %d, %v", keyId, count, err)
+	}
+
+	for _, id := range []string{"legacy", "a", "b", "c"} {
+		if boltValueKeyId(readRawBoltValue(t, db, "secrets", id)) != "key2" {
+			t.Errorf("Expected %s to be encrypted with key2", id)
+		}
+
+		result := testObject{}
+		err = Database.BoltDb.ReadObject("secrets", id, &result)
+		if err != nil || result.Id != id {
+			t.Errorf("Unexpected result for %s: %+v, %v", id, result, err)
+		}
+	}
+
+	rotated, _ := Database.BoltDb.ReadObjectWithRevision("secrets", "a", &testObject{})
+	if rotated != revision {
+		t.Errorf("Expected re-encryption to keep revision %d but got %d", revision, rotated)
+	}
+
+	count, err = Database.BoltDb.ReencryptValues(2)
+	if err != nil || count != 0 {
+		t.Errorf("Expected nothing left to re-encrypt but got %d, %v", count, err)
+	}
+}
+
+func TestEncryptionKeyNotFound(t *testing.T) {
+	useTempBoltDB(t)
+	useBoltEncryption(t, "key1", "key1")
+
+	Database.BoltDb.SaveObject("secrets", "item", testObject{Id: "item"})
+
+	useBoltEncryption(t, "key2", "key2")
+
+	err := Database.BoltDb.ReadObject("secrets", "item", &testObject{})
+	if err != ErrBoltEncryptionKeyNotFound {
+		t.Errorf("Expected ErrBoltEncryptionKeyNotFound but got %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+package platform
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"go.uber.org/zap"
+)
+
+const (
+	// Holds a nested bucket per data bucket with the expiry time of each key
+	boltExpiryBucket = "_expiry"
+
+	boltExpiryDefaultIntervalSeconds = 60
+	boltExpiryDefaultBatchSize       = 500
+)
+
+var (
+	ErrBoltInvalidTTL = errors.New("ttl must be greater than 0")
+)
+
+type boltExpiryReaper struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// SaveObjectWithTTL ... Same as SaveObject but the entry is treated as not found once the ttl has passed.
+// Expired entries are removed by the expiry reaper or PurgeExpired
+func (d *boltDbDatabase) SaveObjectWithTTL(bucket string, id string, object interface{}, ttl time.Duration) error {
+	if ttl <= 0 {
+		Log.Error("Invalid TTL for object", zap.String("bucket", bucket), zap.String("id", id), zap.Duration("ttl", ttl))
+		return ErrBoltInvalidTTL
+	}
+
+	return d.saveObject(context.Background(), bucket, id, object, time.Now().Add(ttl))
+}
+
+func encodeBoltExpiry(expiresAt time.Time) []byte {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(expiresAt.UnixNano()))
+	return value
+}
+
+func decodeBoltExpiry(value []byte) time.Time {
+	if len(value) != 8 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, int64(binary.BigEndian.Uint64(value)))
+}
+
+// Records or clears the expiry of a key. A zero expiresAt means the entry never expires
+func setBoltExpiry(tx *bolt.Tx, bucket string, id string, expiresAt time.Time) error {
+	if expiresAt.IsZero() {
+		expiry := tx.Bucket([]byte(boltExpiryBucket))
+		if expiry == nil {
+			return nil
+		}
+
+		b := expiry.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.Delete([]byte(id))
+	}
+
+	expiry, err := tx.CreateBucketIfNotExists([]byte(boltExpiryBucket))
+	if err != nil {
+		return err
+	}
+
+	b, err := expiry.CreateBucketIfNotExists([]byte(bucket))
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(id), encodeBoltExpiry(expiresAt))
+}
+
+func removeBoltExpiryBucket(tx *bolt.Tx, bucket string) error {
+	expiry := tx.Bucket([]byte(boltExpiryBucket))
+	if expiry == nil {
+		return nil
+	}
+
+	err := expiry.DeleteBucket([]byte(bucket))
+	if err != nil && err != bolt.ErrBucketNotFound {
+		return err
+	}
+
+	return nil
+}
+
+// Returns the expiry lookup for a bucket within a read transaction
+func boltExpiryChecker(tx *bolt.Tx, bucket string) func(id []byte) bool {
+	expiry := tx.Bucket([]byte(boltExpiryBucket))
+	if expiry == nil {
+		return func(id []byte) bool { return false }
+	}
+
+	b := expiry.Bucket([]byte(bucket))
+	if b == nil {
+		return func(id []byte) bool { return false }
+	}
+
+	now := time.Now()
+	return func(id []byte) bool {
+		value := b.Get(id)
+		if value == nil {
+			return false
+		}
+
+		return !decodeBoltExpiry(value).After(now)
+	}
+}
+
+// Expiry time of the entry or zero when it does not expire
+func boltExpiryOf(tx *bolt.Tx, bucket string, id string) time.Time {
+	expiry := tx.Bucket([]byte(boltExpiryBucket))
+	if expiry == nil {
+		return time.Time{}
+	}
+
+	b := expiry.Bucket([]byte(bucket))
+	if b == nil {
+		return time.Time{}
+	}
+
+	value := b.Get([]byte(id))
+	if value == nil {
+		return time.Time{}
+	}
+
+	return decodeBoltExpiry(value)
+}
+
+// PurgeExpired ... Removes up to batchSize expired entries in a single transaction.
+// Returns the number of entries removed
+func (d *boltDbDatabase) PurgeExpired(batchSize int) (int, error) {
+	var removed int
+	err := d.use(func(db *bolt.DB) error {
+		var err error
+		removed, err = purgeExpiredBoltEntries(db, batchSize)
+		return err
+	})
+
+	return removed, err
+}
+
+func purgeExpiredBoltEntries(db *bolt.DB, batchSize int) (int, error) {
+	if batchSize < 1 {
+		batchSize = boltExpiryDefaultBatchSize
+	}
+
+	removed := 0
+	now := time.Now()
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		expiry := tx.Bucket([]byte(boltExpiryBucket))
+		if expiry == nil {
+			return nil
+		}
+
+		expired := make(map[string][][]byte)
+		err := expiry.ForEach(func(bucket, _ []byte) error {
+			b := expiry.Bucket(bucket)
+			if b == nil {
+				return nil
+			}
+
+			cursor := b.Cursor()
+			for key, value := cursor.First(); key != nil && removed < batchSize; key, value = cursor.Next() {
+				if decodeBoltExpiry(value).After(now) {
+					continue
+				}
+
+				id := make([]byte, len(key))
+				copy(id, key)
+				expired[string(bucket)] = append(expired[string(bucket)], id)
+				removed++
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for bucket, ids := range expired {
+			data := lookupBoltBucket(tx, bucket)
+			times := expiry.Bucket([]byte(bucket))
+			for _, id := range ids {
+				if data != nil {
+					err = data.Delete(id)
+					if err != nil {
+						return err
+					}
+				}
+
+				err = times.Delete(id)
+				if err != nil {
+					return err
+				}
+
+				err = updateBoltIndexes(tx, bucket, string(id), nil)
+				if err != nil {
+					return err
+				}
+
+				err = recordBoltChange(tx, BoltChangeDelete, bucket, string(id), nil)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		Log.Error("Error purging expired entries", zap.Error(err))
+		return 0, err
+	}
+
+	if removed > 0 {
+		Log.Debug("Purged expired entries", zap.Int("count", removed))
+	}
+
+	return removed, nil
+}
+
+// StartExpiryReaper ... Starts the background removal of expired entries.
+// Each run purges in batches until no expired entries are left. Stopped by StopExpiryReaper or Close
+func (d *boltDbDatabase) StartExpiryReaper(interval time.Duration, batchSize int) {
+	d.jobsLock.Lock()
+	defer d.jobsLock.Unlock()
+
+	if d.reaper != nil {
+		Log.Warn("BoltDB expiry reaper already running")
+		return
+	}
+
+	if interval <= 0 {
+		interval = time.Second * boltExpiryDefaultIntervalSeconds
+	}
+
+	if batchSize < 1 {
+		batchSize = boltExpiryDefaultBatchSize
+	}
+
+	reaper := &boltExpiryReaper{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	d.reaper = reaper
+
+	Log.Info("Starting BoltDB expiry reaper", zap.Duration("interval", interval), zap.Int("batch_size", batchSize))
+
+	go func() {
+		defer close(reaper.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-reaper.stop:
+				return
+			case <-ticker.C:
+			}
+
+			for {
+				removed, err := d.PurgeExpired(batchSize)
+				if err != nil || removed < batchSize {
+					break
+				}
+
+				// Give other writers a chance between batches
+				select {
+				case <-reaper.stop:
+					return
+				default:
+				}
+			}
+		}
+	}()
+}
+
+// StopExpiryReaper ... Stops the background reaper and waits for a running purge to complete
+func (d *boltDbDatabase) StopExpiryReaper() {
+	d.jobsLock.Lock()
+	reaper := d.reaper
+	d.reaper = nil
+	d.jobsLock.Unlock()
+
+	if reaper == nil {
+		return
+	}
+
+	close(reaper.stop)
+	<-reaper.done
+
+	Log.Info("BoltDB expiry reaper stopped")
+}
@@ -0,0 +1,76 @@
+package platform
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSaveObjectWithTTLExpires(t *testing.T) {
+	useTempBoltDB(t)
+
+	err := Database.BoltDb.SaveObjectWithTTL("codes", "short", testObject{Id: "short"}, time.Millisecond*20)
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+
+	err = Database.BoltDb.SaveObjectWithTTL("codes", "long", testObject{Id: "long"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+
+	result := testObject{}
+	err = Database.BoltDb.ReadObject("codes", "short", &result)
+	if err != nil || result.Id != "short" {
+		t.Fatalf("Object should be readable before it expires: %v", err)
+	}
+
+	time.Sleep(time.Millisecond * 30)
+
+	err = Database.BoltDb.ReadObject("codes", "short", &result)
+	if err != ErrNoEntryFoundInDB {
+		t.Errorf("Expected ErrNoEntryFoundInDB for expired object, got %v", err)
+	}
+
+	all, err := Database.BoltDb.ReadAllObjects("codes")
+	if err != nil || len(all) != 1 {
+		t.Errorf("Expected only the unexpired object: %v %v", all, err)
+	}
+
+	removed, err := Database.BoltDb.PurgeExpired(10)
+	if err != nil || removed != 1 {
+		t.Errorf("Expected 1 purged entry: %d %v", removed, err)
+	}
+
+	// Saving without a TTL makes the entry permanent again
+	err = Database.BoltDb.SaveObject("codes", "long", testObject{Id: "long"})
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+
+	removed, err = Database.BoltDb.PurgeExpired(10)
+	if err != nil || removed != 0 {
+		t.Errorf("Expected nothing to purge: %d %v", removed, err)
+	}
+}
+
+func TestExpiryReaperStops(t *testing.T) {
+	useTempBoltDB(t)
+
+	err := Database.BoltDb.SaveObjectWithTTL("codes", "1", testObject{Id: "1"}, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+
+	Database.BoltDb.StartExpiryReaper(time.Millisecond*10, 1)
+	time.Sleep(time.Millisecond * 50)
+	Database.BoltDb.StopExpiryReaper()
+
+	all, err := Database.BoltDb.ReadAllObjects("codes")
+	if err != nil {
+		t.Fatalf("Error reading objects: %v", err)
+	}
+
+	if len(all) != 0 {
+		t.Errorf("Expired entry was not reaped: %v", all)
+	}
+}
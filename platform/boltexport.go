@@ -0,0 +1,360 @@
+package platform
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"go.uber.org/zap"
+)
+
+const (
+	BoltImportUpsert       = "upsert"
+	BoltImportSkipExisting = "skip"
+
+	boltImportDefaultBatchSize = 1000
+	// Lines are limited to this size when importing
+	boltImportMaxLineSize = 16 * 1024 * 1024
+)
+
+var (
+	ErrBoltImportInvalidMode   = errors.New("import mode must be upsert or skip")
+	ErrBoltImportInvalidRecord = errors.New("import record needs a bucket and a key")
+	ErrBoltBucketTypeRequired  = errors.New("bucket codec is not JSON and no type is registered for the bucket")
+
+	boltBucketTypes     = make(map[string]func() interface{})
+	boltBucketTypesLock sync.RWMutex
+)
+
+// BoltRecord ... A line of a JSON Lines export
+type BoltRecord struct {
+	Bucket string          `json:"bucket"`
+	Key    string          `json:"key"`
+	Value  json.RawMessage `json:"value"`
+	// Set for entries saved with a TTL. Records that have expired by the time they are imported are skipped
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// BoltImportOptions ... Mode is BoltImportUpsert (default) or BoltImportSkipExisting
+type BoltImportOptions struct {
+	Mode string
+	// Records written per transaction. A failed batch is rolled back, earlier batches stay committed
+	BatchSize int
+}
+
+// BoltImportResult ... Counts of an import. Line is the last line read
+type BoltImportResult struct {
+	Imported int
+	Skipped  int
+	Line     int
+}
+
+// Validator ... Implemented by registered bucket types to reject invalid values on import
+type Validator interface {
+	Validate() error
+}
+
+// RegisterBucketType ... Registers the type stored in a bucket. newObject returns a pointer to a new value.
+// Imported values are unmarshalled into it and validated, and buckets with a non JSON codec can be exported
+func RegisterBucketType(bucket string, newObject func() interface{}) {
+	bucket = cleanBoltBucketPath(bucket)
+	boltBucketTypesLock.Lock()
+	defer boltBucketTypesLock.Unlock()
+
+	boltBucketTypes[bucket] = newObject
+}
+
+func bucketType(bucket string) func() interface{} {
+	boltBucketTypesLock.RLock()
+	defer boltBucketTypesLock.RUnlock()
+
+	return boltBucketTypes[bucket]
+}
+
+// Internal buckets hold metadata of the data buckets and are not exported
+func isInternalBoltBucket(name string) bool {
+	return strings.HasPrefix(name, "_")
+}
+
+// Export ... Writes the unexpired entries of the buckets and their nested buckets as JSON Lines.
+// All data buckets are exported if none are given
+func (d *boltDbDatabase) Export(w io.Writer, buckets ...string) (int, error) {
+	var count int
+	err := d.use(func(db *bolt.DB) error {
+		var err error
+		count, err = exportBoltBuckets(db, w, buckets)
+		return err
+	})
+
+	return count, err
+}
+
+// Import ... Reads JSON Lines written by Export
+func (d *boltDbDatabase) Import(r io.Reader, options BoltImportOptions) (BoltImportResult, error) {
+	var result BoltImportResult
+	err := d.use(func(db *bolt.DB) error {
+		var err error
+		result, err = importBoltRecords(db, r, options)
+		return err
+	})
+
+	return result, err
+}
+
+func exportBoltBuckets(db *bolt.DB, w io.Writer, buckets []string) (int, error) {
+	writer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(writer)
+	count := 0
+
+	// A single transaction so that the export is a consistent snapshot
+	err := db.View(func(tx *bolt.Tx) error {
+		if len(buckets) < 1 {
+			err := tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+				if !isInternalBoltBucket(string(name)) {
+					buckets = append(buckets, string(name))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// Buckets nested in the selected buckets are exported with their path
+		paths := make([]string, 0, len(buckets))
+		for _, bucket := range buckets {
+			bucket = cleanBoltBucketPath(bucket)
+			if b := lookupBoltBucket(tx, bucket); b != nil {
+				paths = append(paths, nestedBoltBucketPaths(b, bucket)...)
+			} else {
+				paths = append(paths, bucket)
+			}
+		}
+
+		for _, bucket := range paths {
+			b := lookupBoltBucket(tx, bucket)
+			if b == nil {
+				Log.Warn("Bucket to export not found", zap.String("bucket", bucket))
+				continue
+			}
+
+			expired := boltExpiryChecker(tx, bucket)
+			cursor := b.Cursor()
+			for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+				// Nested buckets have no value
+				if value == nil || expired(key) {
+					continue
+				}
+
+				data, err := exportBoltValue(tx, bucket, string(key), value)
+				if err != nil {
+					return fmt.Errorf("%s/%s: %w", bucket, key, err)
+				}
+
+				record := BoltRecord{Bucket: bucket, Key: string(key), Value: data}
+				if expiresAt := boltExpiryOf(tx, bucket, string(key)); !expiresAt.IsZero() {
+					expiresAt = expiresAt.UTC()
+					record.ExpiresAt = &expiresAt
+				}
+
+				err = encoder.Encode(record)
+				if err != nil {
+					return err
+				}
+				count++
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		Log.Error("Error exporting buckets", zap.Error(err))
+		return count, err
+	}
+
+	return count, writer.Flush()
+}
+
+// Value of the entry as JSON. Values stored with another codec are converted with the registered bucket type
+func exportBoltValue(tx *bolt.Tx, bucket string, id string, value []byte) (json.RawMessage, error) {
+	value, err := decodeBoltValue(tx, bucket, id, value)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, codec, err := boltValuePayload(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if codec.Id() == JSONCodec.Id() {
+		return append(json.RawMessage{}, payload...), nil
+	}
+
+	newObject := bucketType(bucket)
+	if newObject == nil {
+		return nil, ErrBoltBucketTypeRequired
+	}
+
+	object := newObject()
+	err = codec.Unmarshal(payload, object)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(object)
+}
+
+func importBoltRecords(db *bolt.DB, r io.Reader, options BoltImportOptions) (BoltImportResult, error) {
+	result := BoltImportResult{}
+
+	if len(options.Mode) < 1 {
+		options.Mode = BoltImportUpsert
+	}
+	if options.Mode != BoltImportUpsert && options.Mode != BoltImportSkipExisting {
+		return result, ErrBoltImportInvalidMode
+	}
+
+	if options.BatchSize < 1 {
+		options.BatchSize = boltImportDefaultBatchSize
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), boltImportMaxLineSize)
+
+	batch := make([]BoltRecord, 0, options.BatchSize)
+	for {
+		more := scanner.Scan()
+		if more {
+			result.Line++
+
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) < 1 {
+				continue
+			}
+
+			record := BoltRecord{}
+			err := json.Unmarshal(line, &record)
+			if err != nil {
+				return result, fmt.Errorf("line %d: %w", result.Line, err)
+			}
+
+			record.Bucket = cleanBoltBucketPath(record.Bucket)
+			if len(record.Bucket) < 1 || len(record.Key) < 1 || isInternalBoltBucket(record.Bucket) {
+				return result, fmt.Errorf("line %d: %w", result.Line, ErrBoltImportInvalidRecord)
+			}
+
+			batch = append(batch, record)
+		}
+
+		if len(batch) >= options.BatchSize || (!more && len(batch) > 0) {
+			imported, skipped, err := importBoltBatch(db, batch, options.Mode)
+			if err != nil {
+				Log.Error("Error importing batch", zap.Int("line", result.Line), zap.Error(err))
+				return result, err
+			}
+
+			result.Imported += imported
+			result.Skipped += skipped
+			batch = batch[:0]
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+
+	Log.Info("Import completed", zap.Int("imported", result.Imported), zap.Int("skipped", result.Skipped))
+
+	return result, nil
+}
+
+func importBoltBatch(db *bolt.DB, batch []BoltRecord, mode string) (int, int, error) {
+	imported := 0
+	skipped := 0
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		for _, record := range batch {
+			if mode == BoltImportSkipExisting && currentBoltRevision(tx, record.Bucket, record.Key) > 0 {
+				skipped++
+				continue
+			}
+
+			// Restored with the same expiry as SaveObjectWithTTL gave it
+			expiresAt := time.Time{}
+			if record.ExpiresAt != nil {
+				if !record.ExpiresAt.After(now) {
+					skipped++
+					continue
+				}
+				expiresAt = *record.ExpiresAt
+			}
+
+			object, err := importBoltObject(tx, record)
+			if err != nil {
+				return fmt.Errorf("%s/%s: %w", record.Bucket, record.Key, err)
+			}
+
+			_, err = putBoltObject(tx, record.Bucket, record.Key, object, expiresAt)
+			if err != nil {
+				return err
+			}
+			imported++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return imported, skipped, nil
+}
+
+// Unmarshals and validates the value with the registered bucket type.
+// Without a type the JSON is stored as is, which needs the JSON codec
+func importBoltObject(tx *bolt.Tx, record BoltRecord) (interface{}, error) {
+	newObject := bucketType(record.Bucket)
+	if newObject == nil {
+		selected, err := codecForBucket(tx, record.Bucket)
+		if err != nil {
+			return nil, err
+		}
+		if selected.codec.Id() != JSONCodec.Id() {
+			return nil, ErrBoltBucketTypeRequired
+		}
+
+		if !json.Valid(record.Value) {
+			return nil, ErrBoltImportInvalidRecord
+		}
+
+		return record.Value, nil
+	}
+
+	object := newObject()
+	err := json.Unmarshal(record.Value, object)
+	if err != nil {
+		return nil, err
+	}
+
+	if validator, ok := object.(Validator); ok {
+		err = validator.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return object, nil
+}
@@ -0,0 +1,160 @@
+package platform
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+type validatedObject struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (o *validatedObject) Validate() error {
+	if len(o.Name) < 1 {
+		return errors.New("name is required")
+	}
+
+	return nil
+}
+
+func TestExportAndImport(t *testing.T) {
+	useTempBoltDB(t)
+
+	Database.BoltDb.SaveObject("people", "a", testObject{Id: "a", Name: "Anna"})
+	Database.BoltDb.SaveObject("people", "b", testObject{Id: "b", Name: "Ben"})
+	Database.BoltDb.SaveObject("orders", "1", testObject{Id: "1"})
+	Database.BoltDb.SaveObjectWithTTL("people", "expired", testObject{Id: "expired"}, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	buffer := bytes.Buffer{}
+	count, err := Database.BoltDb.Export(&buffer)
+	if err != nil || count != 3 {
+		t.Fatalf("Unexpected export result %d, %v", count, err)
+	}
+	if strings.Contains(buffer.String(), "_revisions") {
+		t.Errorf("Internal buckets must not be exported: %s", buffer.String())
+	}
+
+	exported := buffer.String()
+
+	useTempBoltDB(t)
+
+	result, err := Database.BoltDb.Import(strings.NewReader(exported), BoltImportOptions{BatchSize: 2})
+	if err != nil || result.Imported != 3 {
+		t.Fatalf("Unexpected import result %+v, %v", result, err)
+	}
+
+	person := testObject{}
+	err = Database.BoltDb.ReadObject("people", "b", &person)
+	if err != nil || person.Name != "Ben" {
+		t.Errorf("Unexpected imported value %+v, %v", person, err)
+	}
+
+	Database.BoltDb.SaveObject("people", "a", testObject{Id: "a", Name: "Changed"})
+	result, err = Database.BoltDb.Import(strings.NewReader(exported), BoltImportOptions{Mode: BoltImportSkipExisting})
+	if err != nil || result.Skipped != 3 || result.Imported != 0 {
+		t.Errorf("Unexpected skip result %+v, %v", result, err)
+	}
+
+	Database.BoltDb.ReadObject("people", "a", &person)
+	if person.Name != "Changed" {
+		t.Errorf("Expected existing value to be kept but got %+v", person)
+	}
+}
+
+func TestExportSelectedBucketsWithCodec(t *testing.T) {
+	useTempBoltDB(t)
+	useBucketCodec(t, "people", "gob", true)
+
+	Database.BoltDb.SaveObject("people", "a", testObject{Id: "a", Name: "Anna"})
+	Database.BoltDb.SaveObject("orders", "1", testObject{Id: "1"})
+
+	buffer := bytes.Buffer{}
+	_, err := Database.BoltDb.Export(&buffer, "people")
+	if !errors.Is(err, ErrBoltBucketTypeRequired) {
+		t.Fatalf("Expected ErrBoltBucketTypeRequired but got %v", err)
+	}
+
+	RegisterBucketType("people", func() interface{} { return &testObject{} })
+	defer delete(boltBucketTypes, "people")
+
+	buffer.Reset()
+	count, err := Database.BoltDb.Export(&buffer, "people")
+	if err != nil || count != 1 {
+		t.Fatalf("Unexpected export result %d, %v", count, err)
+	}
+
+	expected := `{"bucket":"people","key":"a","value":{"Id":"a","Name":"Anna","Surname":""}}` + "\n"
+	if buffer.String() != expected {
+		t.Errorf("Unexpected export %s", buffer.String())
+	}
+}
+
+func TestImportValidation(t *testing.T) {
+	useTempBoltDB(t)
+
+	RegisterBucketType("validated", func() interface{} { return &validatedObject{} })
+	defer delete(boltBucketTypes, "validated")
+
+	input := `{"bucket":"validated","key":"1","value":{"id":"1","name":"One"}}
+{"bucket":"validated","key":"2","value":{"id":"2","name":"Two"}}
+{"bucket":"validated","key":"3","value":{"id":"3"}}
+`
+
+	result, err := Database.BoltDb.Import(strings.NewReader(input), BoltImportOptions{BatchSize: 2})
+	if err == nil || result.Imported != 2 {
+		t.Fatalf("Expected the last batch to fail validation but got %+v, %v", result, err)
+	}
+
+	_, err = Database.BoltDb.Import(strings.NewReader(`{"bucket":"_revisions","key":"1","value":1}`), BoltImportOptions{})
+	if !errors.Is(err, ErrBoltImportInvalidRecord) {
+		t.Errorf("Expected ErrBoltImportInvalidRecord but got %v", err)
+	}
+
+	_, err = Database.BoltDb.Import(strings.NewReader(input), BoltImportOptions{Mode: "merge"})
+	if err != ErrBoltImportInvalidMode {
+		t.Errorf("Expected ErrBoltImportInvalidMode but got %v", err)
+	}
+}
+
+func TestExportAndImportKeepExpiry(t *testing.T) {
+	useTempBoltDB(t)
+
+	Database.BoltDb.SaveObjectWithTTL("codes", "long", testObject{Id: "long"}, time.Hour)
+	Database.BoltDb.SaveObjectWithTTL("codes", "short", testObject{Id: "short"}, time.Millisecond*50)
+
+	buffer := bytes.Buffer{}
+	count, err := Database.BoltDb.Export(&buffer, "codes")
+	if err != nil || count != 2 {
+		t.Fatalf("Unexpected export result %d, %v", count, err)
+	}
+	if strings.Count(buffer.String(), `"expiresAt"`) != 2 {
+		t.Errorf("Expected the expiry in every record: %s", buffer.String())
+	}
+
+	time.Sleep(time.Millisecond * 60)
+	db := useTempBoltDB(t)
+
+	result, err := Database.BoltDb.Import(strings.NewReader(buffer.String()), BoltImportOptions{})
+	if err != nil || result.Imported != 1 || result.Skipped != 1 {
+		t.Fatalf("Expected the expired record to be skipped but got %+v, %v", result, err)
+	}
+
+	db.View(func(tx *bolt.Tx) error {
+		expiresAt := boltExpiryOf(tx, "codes", "long")
+		if expiresAt.Before(time.Now().Add(time.Minute*59)) || expiresAt.After(time.Now().Add(time.Hour)) {
+			t.Errorf("Expected the imported entry to keep its expiry but got %v", expiresAt)
+		}
+		return nil
+	})
+
+	if Database.BoltDb.ReadObject("codes", "short", &testObject{}) != ErrNoEntryFoundInDB {
+		t.Error("Expected the expired record not to be imported")
+	}
+}
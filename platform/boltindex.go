@@ -0,0 +1,441 @@
+package platform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"go.uber.org/zap"
+)
+
+const (
+	// Holds a nested bucket per data bucket with a bucket per indexed path.
+	// Index keys are the encoded value followed by the id, the value is the id
+	boltIndexBucket = "_index"
+	// Index keys written for each id, so that they can be removed when the value changes
+	boltIndexKeysBucket = "\x00keys"
+
+	// Type prefixes of index keys. Values of a type sort together and in their natural order
+	boltIndexNull   = 0x01
+	boltIndexBool   = 0x02
+	boltIndexNumber = 0x03
+	boltIndexString = 0x04
+	// Ends the value so that a string sorts before the longer strings it is a prefix of
+	boltIndexTerminator = 0x00
+)
+
+var (
+	ErrIndexEncryptedBucket = errors.New("values of encrypted buckets cannot be indexed")
+)
+
+// CreateIndex ... Indexes a JSON path of the documents in the bucket and keeps it up to date on writes.
+// Existing documents are indexed in the same transaction. Queries on the path then avoid a full scan
+func (d *boltDbDatabase) CreateIndex(bucket string, path string) error {
+	bucket = cleanBoltBucketPath(bucket)
+	if d.bucketEncrypted(bucket) {
+		return ErrIndexEncryptedBucket
+	}
+
+	err := d.update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte(boltIndexBucket))
+		if err != nil {
+			return err
+		}
+
+		indexes, err := root.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+
+		if indexes.Bucket([]byte(path)) != nil {
+			return nil
+		}
+
+		_, err = indexes.CreateBucket([]byte(path))
+		if err != nil {
+			return err
+		}
+
+		return rebuildBoltIndexes(tx, bucket)
+	})
+	if err != nil {
+		Log.Error("Error creating index", zap.String("bucket", bucket), zap.String("path", path), zap.Error(err))
+		return err
+	}
+
+	Log.Info("Index created", zap.String("bucket", bucket), zap.String("path", path))
+
+	return nil
+}
+
+// DropIndex ... Removes the index of the path. Queries on it scan the bucket again
+func (d *boltDbDatabase) DropIndex(bucket string, path string) error {
+	bucket = cleanBoltBucketPath(bucket)
+	return d.update(func(tx *bolt.Tx) error {
+		indexes := boltIndexes(tx, bucket)
+		if indexes == nil || indexes.Bucket([]byte(path)) == nil {
+			return nil
+		}
+
+		err := indexes.DeleteBucket([]byte(path))
+		if err != nil {
+			return err
+		}
+
+		return rebuildBoltIndexes(tx, bucket)
+	})
+}
+
+// Query ... Runs the query over the documents of the bucket. Values stored with a codec other than JSON
+// need a type registered with RegisterBucketType
+func (d *boltDbDatabase) Query(bucket string, query *Query) (_ QueryResult, err error) {
+	bucket = cleanBoltBucketPath(bucket)
+	defer observeBoltOperation(d, "query", time.Now(), &err)
+	q, err := query.normalized()
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	documents := make([]queryDocument, 0)
+	err = d.view(func(tx *bolt.Tx) error {
+		b := lookupBoltBucket(tx, bucket)
+		if b == nil {
+			return nil
+		}
+
+		expired := boltExpiryChecker(tx, bucket)
+		visit := func(key []byte, value []byte) error {
+			// Nested buckets have no value
+			if value == nil || expired(key) {
+				return nil
+			}
+
+			data, err := exportBoltValue(tx, bucket, string(key), value)
+			if err != nil {
+				return fmt.Errorf("%s/%s: %w", bucket, key, err)
+			}
+
+			document, matched, err := q.match(data)
+			if err != nil {
+				return fmt.Errorf("%s/%s: %w", bucket, key, err)
+			}
+
+			if matched {
+				documents = append(documents, queryDocument{id: string(key), value: data, document: document})
+			}
+			return nil
+		}
+
+		ids, indexed := boltIndexCandidates(tx, bucket, q)
+		if !indexed {
+			return b.ForEach(visit)
+		}
+
+		for _, id := range ids {
+			err := visit(id, b.Get(id))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		Log.Error("Error running query", zap.String("bucket", bucket), zap.Error(err))
+		return QueryResult{}, err
+	}
+
+	return q.result(documents), nil
+}
+
+func boltIndexes(tx *bolt.Tx, bucket string) *bolt.Bucket {
+	root := tx.Bucket([]byte(boltIndexBucket))
+	if root == nil {
+		return nil
+	}
+
+	return root.Bucket([]byte(bucket))
+}
+
+// Paths indexed for the bucket
+func boltIndexPaths(indexes *bolt.Bucket) []string {
+	paths := make([]string, 0)
+	indexes.ForEach(func(name, value []byte) error {
+		if value == nil && string(name) != boltIndexKeysBucket {
+			paths = append(paths, string(name))
+		}
+		return nil
+	})
+
+	return paths
+}
+
+// Replaces the index entries of the id with those of the JSON document. A nil document only removes them
+func updateBoltIndexes(tx *bolt.Tx, bucket string, id string, document []byte) error {
+	indexes := boltIndexes(tx, bucket)
+	if indexes == nil {
+		return nil
+	}
+
+	written, err := indexes.CreateBucketIfNotExists([]byte(boltIndexKeysBucket))
+	if err != nil {
+		return err
+	}
+
+	if previous := written.Get([]byte(id)); previous != nil {
+		keys := make(map[string][][]byte)
+		err = json.Unmarshal(previous, &keys)
+		if err != nil {
+			return err
+		}
+
+		for path, pathKeys := range keys {
+			index := indexes.Bucket([]byte(path))
+			if index == nil {
+				continue
+			}
+
+			for _, key := range pathKeys {
+				err = index.Delete(key)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if document == nil {
+		return written.Delete([]byte(id))
+	}
+
+	var parsed interface{}
+	err = json.Unmarshal(document, &parsed)
+	if err != nil {
+		return ErrQueryInvalidDocument
+	}
+
+	keys := make(map[string][][]byte)
+	for _, path := range boltIndexPaths(indexes) {
+		index := indexes.Bucket([]byte(path))
+
+		value, ok := queryLookup(parsed, path)
+		if !ok {
+			continue
+		}
+
+		values := []interface{}{value}
+		if elements, isArray := value.([]interface{}); isArray {
+			values = elements
+		}
+
+		for _, v := range values {
+			encoded := encodeBoltIndexValue(v)
+			if encoded == nil {
+				continue
+			}
+
+			key := append(encoded, id...)
+			err = index.Put(key, []byte(id))
+			if err != nil {
+				return err
+			}
+			keys[path] = append(keys[path], key)
+		}
+	}
+
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	return written.Put([]byte(id), data)
+}
+
+// Writes the index entries of every document in the bucket again
+func rebuildBoltIndexes(tx *bolt.Tx, bucket string) error {
+	indexes := boltIndexes(tx, bucket)
+	if indexes == nil {
+		return nil
+	}
+
+	paths := boltIndexPaths(indexes)
+	for _, name := range append(paths, boltIndexKeysBucket) {
+		err := indexes.DeleteBucket([]byte(name))
+		if err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+	}
+
+	for _, path := range paths {
+		_, err := indexes.CreateBucket([]byte(path))
+		if err != nil {
+			return err
+		}
+	}
+
+	b := lookupBoltBucket(tx, bucket)
+	if b == nil || len(paths) < 1 {
+		return nil
+	}
+
+	return b.ForEach(func(key, value []byte) error {
+		if value == nil {
+			return nil
+		}
+
+		document, err := exportBoltValue(tx, bucket, string(key), value)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", bucket, key, err)
+		}
+
+		return updateBoltIndexes(tx, bucket, string(key), document)
+	})
+}
+
+// Removes the index entries of a removed bucket. The indexes stay defined for new documents
+func clearBoltIndexes(tx *bolt.Tx, bucket string) error {
+	return rebuildBoltIndexes(tx, bucket)
+}
+
+// Ids of the documents that can match the indexed filters, in key order.
+// indexed is false when none of the filters has an index
+func boltIndexCandidates(tx *bolt.Tx, bucket string, q *Query) (ids [][]byte, indexed bool) {
+	indexes := boltIndexes(tx, bucket)
+	if indexes == nil {
+		return nil, false
+	}
+
+	var candidates map[string]bool
+	for _, filter := range q.Filters {
+		index := indexes.Bucket([]byte(filter.Path))
+		if index == nil || filter.Operator == QueryContains {
+			continue
+		}
+
+		matched := make(map[string]bool)
+		values := []interface{}{filter.Value}
+		if filter.Operator == QueryIn {
+			values = filter.Value.([]interface{})
+		}
+
+		for _, value := range values {
+			for _, variant := range boltIndexVariants(value) {
+				scanBoltIndex(index, filter.Operator, variant, matched)
+			}
+		}
+
+		if candidates == nil {
+			candidates = matched
+			continue
+		}
+
+		for id := range candidates {
+			if !matched[id] {
+				delete(candidates, id)
+			}
+		}
+	}
+
+	if candidates == nil {
+		return nil, false
+	}
+
+	ids = make([][]byte, 0, len(candidates))
+	for id := range candidates {
+		ids = append(ids, []byte(id))
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i], ids[j]) < 0
+	})
+
+	return ids, true
+}
+
+// Adds the ids of the index entries that can match the operator. Range scans stay within the type of the value,
+// gt and lt include equal values because the filters are checked again on the documents
+func scanBoltIndex(index *bolt.Bucket, operator string, value interface{}, matched map[string]bool) {
+	encoded := encodeBoltIndexValue(value)
+	if encoded == nil {
+		return
+	}
+
+	cursor := index.Cursor()
+	var key, id []byte
+
+	switch operator {
+	case QueryEq, QueryIn:
+		for key, id = cursor.Seek(encoded); key != nil && bytes.HasPrefix(key, encoded); key, id = cursor.Next() {
+			matched[string(id)] = true
+		}
+	case QueryGt, QueryGte:
+		for key, id = cursor.Seek(encoded); key != nil && key[0] == encoded[0]; key, id = cursor.Next() {
+			matched[string(id)] = true
+		}
+	case QueryLt, QueryLte:
+		for key, id = cursor.Seek(encoded[:1]); key != nil && key[0] == encoded[0]; key, id = cursor.Next() {
+			if bytes.Compare(key, encoded) > 0 && !bytes.HasPrefix(key, encoded) {
+				break
+			}
+			matched[string(id)] = true
+		}
+	}
+}
+
+// String filter values also match numbers and booleans, the same as the document scan
+func boltIndexVariants(value interface{}) []interface{} {
+	variants := []interface{}{value}
+
+	s, ok := value.(string)
+	if !ok {
+		return variants
+	}
+
+	if number, err := strconv.ParseFloat(s, 64); err == nil {
+		variants = append(variants, number)
+	}
+	if boolean, err := strconv.ParseBool(s); err == nil {
+		variants = append(variants, boolean)
+	}
+
+	return variants
+}
+
+// Order preserving encoding of a JSON value. Objects and arrays are not indexed
+func encodeBoltIndexValue(value interface{}) []byte {
+	switch v := value.(type) {
+	case nil:
+		return []byte{boltIndexNull, boltIndexTerminator}
+	case bool:
+		if v {
+			return []byte{boltIndexBool, 1, boltIndexTerminator}
+		}
+		return []byte{boltIndexBool, 0, boltIndexTerminator}
+	case float64:
+		bits := math.Float64bits(v)
+		if bits&(1<<63) == 0 {
+			bits ^= 1 << 63
+		} else {
+			bits = ^bits
+		}
+
+		encoded := make([]byte, 10)
+		encoded[0] = boltIndexNumber
+		binary.BigEndian.PutUint64(encoded[1:], bits)
+		encoded[9] = boltIndexTerminator
+		return encoded
+	case string:
+		encoded := make([]byte, 0, len(v)+2)
+		encoded = append(encoded, boltIndexString)
+		encoded = append(encoded, v...)
+		return append(encoded, boltIndexTerminator)
+	}
+
+	return nil
+}
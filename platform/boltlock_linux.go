@@ -0,0 +1,50 @@
+package platform
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Finds the process holding the lock on the file from /proc/locks. 0 if it is not found
+func boltLockHolder(fileName string) int {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return 0
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+
+	locks, err := os.Open("/proc/locks")
+	if err != nil {
+		return 0
+	}
+	defer locks.Close()
+
+	// Lines look like: 1: FLOCK  ADVISORY  WRITE 1234 fd:01:56789 0 EOF
+	inode := fmt.Sprintf(":%d", stat.Ino)
+	scanner := bufio.NewScanner(locks)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || fields[1] == "->" {
+			continue
+		}
+
+		if !strings.HasSuffix(fields[5], inode) {
+			continue
+		}
+
+		pid, err := strconv.Atoi(fields[4])
+		if err == nil {
+			return pid
+		}
+	}
+
+	return 0
+}
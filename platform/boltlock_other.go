@@ -0,0 +1,8 @@
+//go:build !linux
+
+package platform
+
+// The lock holder is only looked up on Linux
+func boltLockHolder(fileName string) int {
+	return 0
+}
@@ -0,0 +1,366 @@
+package platform
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"go.uber.org/zap"
+)
+
+const (
+	boltMigrationsBucket = "_migrations"
+)
+
+var (
+	boltMigrations     = make(map[boltMigrationId]BoltMigration)
+	boltMigrationsLock sync.Mutex
+
+	ErrBoltMigrationInvalidVersion   = errors.New("migration version must be greater than 0")
+	ErrBoltMigrationDuplicateVersion = errors.New("migration version already registered")
+	ErrBoltMigrationNoMigrateFunc    = errors.New("migration has no migrate function")
+
+	// Returned internally to roll back a dry run
+	errBoltMigrationDryRun = errors.New("migration dry run")
+)
+
+// BoltMigration ... A numbered change to the data stored in BoltDB.
+// Migrations are applied in version order and each version is only ever applied once
+type BoltMigration struct {
+	Version     int
+	Description string
+	Migrate     func(tx *bolt.Tx) error
+	// Name of the database from platform.database.boltdatabases to migrate. Empty or "default" is Database.BoltDb.
+	// Versions are numbered per database
+	Database string
+}
+
+type boltMigrationId struct {
+	database string
+	version  int
+}
+
+// BoltMigrationStatus ... Applied state of a registered or previously applied migration
+type BoltMigrationStatus struct {
+	Version     int       `json:"version"`
+	Description string    `json:"description"`
+	Applied     bool      `json:"applied"`
+	AppliedAt   time.Time `json:"appliedAt,omitempty"`
+}
+
+type boltMigrationRecord struct {
+	Version     int       `json:"version"`
+	Description string    `json:"description"`
+	AppliedAt   time.Time `json:"appliedAt"`
+}
+
+// RegisterBoltMigration ... Register a migration to be applied when the service starts.
+// Call this from an init function or before starting the server
+func RegisterBoltMigration(migration BoltMigration) error {
+	if migration.Version < 1 {
+		Log.Error("Invalid migration version", zap.Int("version", migration.Version))
+		return ErrBoltMigrationInvalidVersion
+	}
+
+	if migration.Migrate == nil {
+		Log.Error("Migration has no migrate function", zap.Int("version", migration.Version))
+		return ErrBoltMigrationNoMigrateFunc
+	}
+
+	migration.Database = boltMigrationDatabase(migration.Database)
+	id := boltMigrationId{database: migration.Database, version: migration.Version}
+
+	boltMigrationsLock.Lock()
+	defer boltMigrationsLock.Unlock()
+
+	if _, ok := boltMigrations[id]; ok {
+		Log.Error("Migration version already registered",
+			zap.Int("version", migration.Version),
+			zap.String("database", migration.Database))
+		return ErrBoltMigrationDuplicateVersion
+	}
+
+	boltMigrations[id] = migration
+
+	return nil
+}
+
+// The name migrations of a database are registered under. The default database has no name
+func boltMigrationDatabase(name string) string {
+	name = strings.ToLower(name)
+	if name == "default" {
+		return ""
+	}
+
+	return name
+}
+
+func registeredBoltMigrations(database string) []BoltMigration {
+	database = boltMigrationDatabase(database)
+
+	boltMigrationsLock.Lock()
+	defer boltMigrationsLock.Unlock()
+
+	result := make([]BoltMigration, 0, len(boltMigrations))
+	for id, v := range boltMigrations {
+		if id.database == database {
+			result = append(result, v)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result
+}
+
+func boltMigrationKey(version int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(version))
+	return key
+}
+
+// RunMigrations ... Applies all pending migrations registered for this database in a single transaction.
+// With dryRun the migrations are executed and then rolled back.
+// Returns the migrations that were (or would have been) applied
+func (d *boltDbDatabase) RunMigrations(dryRun bool) ([]BoltMigrationStatus, error) {
+	var applied []BoltMigrationStatus
+	err := d.use(func(db *bolt.DB) error {
+		var err error
+		applied, err = runBoltMigrations(db, d.name, dryRun)
+		return err
+	})
+
+	return applied, err
+}
+
+// ListMigrations ... Returns the migrations registered for and previously applied to this database in version order
+func (d *boltDbDatabase) ListMigrations() ([]BoltMigrationStatus, error) {
+	var statuses []BoltMigrationStatus
+	err := d.use(func(db *bolt.DB) error {
+		var err error
+		statuses, err = listBoltMigrations(db, d.name)
+		return err
+	})
+
+	return statuses, err
+}
+
+// Applies the pending migrations of the default database when it is used and of every named database.
+// Read only databases are skipped
+func runPlatformBoltMigrations(config *Config) error {
+	if boltDbInUse(config) && !config.Database.BoltDB.ReadOnly {
+		_, err := Database.BoltDb.RunMigrations(false)
+		if err != nil {
+			return err
+		}
+	}
+
+	boltDatabasesLock.RLock()
+	named := make([]*boltDbDatabase, 0, len(boltDatabases))
+	for _, d := range boltDatabases {
+		named = append(named, d)
+	}
+	boltDatabasesLock.RUnlock()
+
+	for _, d := range named {
+		if d.settings().ReadOnly {
+			continue
+		}
+
+		_, err := d.RunMigrations(false)
+		if err != nil {
+			return fmt.Errorf("BoltDB %s: %w", d.name, err)
+		}
+	}
+
+	return nil
+}
+
+func runBoltMigrations(db *bolt.DB, database string, dryRun bool) ([]BoltMigrationStatus, error) {
+	applied := make([]BoltMigrationStatus, 0)
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(boltMigrationsBucket))
+		if err != nil {
+			Log.Error("Error creating migrations bucket", zap.Error(err))
+			return err
+		}
+
+		for _, migration := range registeredBoltMigrations(database) {
+			if b.Get(boltMigrationKey(migration.Version)) != nil {
+				continue
+			}
+
+			Log.Info("Applying migration",
+				zap.Int("version", migration.Version),
+				zap.String("description", migration.Description),
+				zap.Bool("dry_run", dryRun))
+
+			err = migration.Migrate(tx)
+			if err != nil {
+				Log.Error("Migration failed", zap.Int("version", migration.Version), zap.Error(err))
+				return err
+			}
+
+			record := boltMigrationRecord{
+				Version:     migration.Version,
+				Description: migration.Description,
+				AppliedAt:   time.Now().UTC(),
+			}
+
+			data, err := json.Marshal(record)
+			if err != nil {
+				Log.Error("Error marshalling migration record", zap.Error(err))
+				return err
+			}
+
+			err = b.Put(boltMigrationKey(migration.Version), data)
+			if err != nil {
+				Log.Error("Error recording migration", zap.Int("version", migration.Version), zap.Error(err))
+				return err
+			}
+
+			applied = append(applied, BoltMigrationStatus{
+				Version:     record.Version,
+				Description: record.Description,
+				Applied:     !dryRun,
+				AppliedAt:   record.AppliedAt,
+			})
+		}
+
+		if dryRun {
+			return errBoltMigrationDryRun
+		}
+
+		return nil
+	})
+
+	if err != nil && err != errBoltMigrationDryRun {
+		return nil, err
+	}
+
+	if len(applied) > 0 {
+		Log.Info("Migrations completed", zap.Int("count", len(applied)), zap.Bool("dry_run", dryRun))
+	}
+
+	return applied, nil
+}
+
+func listBoltMigrations(db *bolt.DB, database string) ([]BoltMigrationStatus, error) {
+	statuses := make(map[int]BoltMigrationStatus)
+
+	for _, migration := range registeredBoltMigrations(database) {
+		statuses[migration.Version] = BoltMigrationStatus{
+			Version:     migration.Version,
+			Description: migration.Description,
+		}
+	}
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(boltMigrationsBucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(key, value []byte) error {
+			record := boltMigrationRecord{}
+			err := json.Unmarshal(value, &record)
+			if err != nil {
+				Log.Error("Error unmarshalling migration record", zap.Error(err))
+				return err
+			}
+
+			statuses[record.Version] = BoltMigrationStatus{
+				Version:     record.Version,
+				Description: record.Description,
+				Applied:     true,
+				AppliedAt:   record.AppliedAt,
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		Log.Error("Error reading migrations", zap.Error(err))
+		return nil, err
+	}
+
+	result := make([]BoltMigrationStatus, 0, len(statuses))
+	for _, v := range statuses {
+		result = append(result, v)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result, nil
+}
+
+// MigrateBucketObjects ... Helper for migrations that rewrites every value in a bucket.
+// Return the new value from convert, or nil to delete the entry the way RemoveObject does. New values are written
+// the way SaveObject does, so their revision goes up and watchers get a put event, and they keep their expiry.
+// Values of encrypted buckets are decrypted before convert is called and encrypted again before they are written.
+// Nested buckets are skipped
+func MigrateBucketObjects(tx *bolt.Tx, bucket string, convert func(id string, value []byte) ([]byte, error)) error {
+	bucket = cleanBoltBucketPath(bucket)
+	b := lookupBoltBucket(tx, bucket)
+	if b == nil {
+		return nil
+	}
+
+	updates := make(map[string][]byte)
+	err := b.ForEach(func(key, value []byte) error {
+		// Nested buckets have no value
+		if value == nil {
+			return nil
+		}
+
+		// Values are only valid for the life of the transaction and may not be modified
+		current, err := decodeBoltValue(tx, bucket, string(key), value)
+		if err != nil {
+			return err
+		}
+		current = append([]byte{}, current...)
+
+		converted, err := convert(string(key), current)
+		if err != nil {
+			return err
+		}
+
+		updates[string(key)] = converted
+		return nil
+	})
+	if err != nil {
+		Log.Error("Error converting bucket objects", zap.String("bucket", bucket), zap.Error(err))
+		return err
+	}
+
+	for key, value := range updates {
+		if value == nil {
+			err = removeBoltObject(tx, b, bucket, key)
+		} else {
+			// Values in another codec have no JSON for the change feed and are indexed by the rebuild below
+			var data []byte
+			if payload, codec, err := boltValuePayload(value); err == nil && codec.Id() == JSONCodec.Id() {
+				data = payload
+			}
+			_, err = putBoltValue(tx, b, bucket, key, value, data, data, boltExpiryOf(tx, bucket, key))
+		}
+
+		if err != nil {
+			Log.Error("Error writing migrated object", zap.String("bucket", bucket), zap.String("id", key), zap.Error(err))
+			return err
+		}
+	}
+
+	return rebuildBoltIndexes(tx, bucket)
+}
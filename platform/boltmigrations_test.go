@@ -0,0 +1,280 @@
+package platform
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func useCleanMigrationRegistry(t *testing.T) {
+	boltMigrationsLock.Lock()
+	previous := boltMigrations
+	boltMigrations = make(map[boltMigrationId]BoltMigration)
+	boltMigrationsLock.Unlock()
+
+	t.Cleanup(func() {
+		boltMigrationsLock.Lock()
+		boltMigrations = previous
+		boltMigrationsLock.Unlock()
+	})
+}
+
+type testObjectV1 struct {
+	Id   string
+	Name string
+}
+
+func TestRunMigrationsOnlyOnce(t *testing.T) {
+	useTempBoltDB(t)
+	useCleanMigrationRegistry(t)
+
+	err := Database.BoltDb.SaveObject("people", "1", testObjectV1{Id: "1", Name: "John Smith"})
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+
+	runs := 0
+	err = RegisterBoltMigration(BoltMigration{
+		Version:     1,
+		Description: "Add surname",
+		Migrate: func(tx *bolt.Tx) error {
+			runs++
+			return MigrateBucketObjects(tx, "people", func(id string, value []byte) ([]byte, error) {
+				old := testObjectV1{}
+				err := json.Unmarshal(value, &old)
+				if err != nil {
+					return nil, err
+				}
+
+				return json.Marshal(testObject{Id: old.Id, Name: "John", Surname: "Smith"})
+			})
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error registering migration: %v", err)
+	}
+
+	err = RegisterBoltMigration(BoltMigration{Version: 1, Migrate: func(tx *bolt.Tx) error { return nil }})
+	if err != ErrBoltMigrationDuplicateVersion {
+		t.Errorf("Expected duplicate version error, got %v", err)
+	}
+
+	applied, err := Database.BoltDb.RunMigrations(true)
+	if err != nil || len(applied) != 1 {
+		t.Fatalf("Dry run failed: %v %v", applied, err)
+	}
+
+	statuses, err := Database.BoltDb.ListMigrations()
+	if err != nil || len(statuses) != 1 || statuses[0].Applied {
+		t.Fatalf("Dry run should not record the migration: %v %v", statuses, err)
+	}
+
+	applied, err = Database.BoltDb.RunMigrations(false)
+	if err != nil || len(applied) != 1 {
+		t.Fatalf("Migration failed: %v %v", applied, err)
+	}
+
+	applied, err = Database.BoltDb.RunMigrations(false)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("Migration should only be applied once: %v %v", applied, err)
+	}
+
+	if runs != 2 {
+		t.Errorf("Expected 2 runs including the dry run, got %d", runs)
+	}
+
+	result := testObject{}
+	err = Database.BoltDb.ReadObject("people", "1", &result)
+	if err != nil {
+		t.Fatalf("Error reading migrated object: %v", err)
+	}
+
+	if result.Surname != "Smith" {
+		t.Errorf("Object was not migrated: %v", result)
+	}
+}
+
+func TestMigrateBucketObjectsRemoves(t *testing.T) {
+	useTempBoltDB(t)
+	useCleanMigrationRegistry(t)
+	useChangeFeed(t)
+
+	err := Database.BoltDb.SaveObjectWithTTL("people", "1", testObjectV1{Id: "1", Name: "John Smith"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+	err = Database.BoltDb.SaveObject("people", "2", testObjectV1{Id: "2", Name: "Jane Smith"})
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+
+	err = Database.BoltDb.update(func(tx *bolt.Tx) error {
+		_, err := tx.Bucket([]byte("people")).CreateBucket([]byte("archive"))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Error creating nested bucket: %v", err)
+	}
+
+	converted := make([]string, 0)
+	err = RegisterBoltMigration(BoltMigration{
+		Version:     1,
+		Description: "Remove John",
+		Migrate: func(tx *bolt.Tx) error {
+			return MigrateBucketObjects(tx, "people", func(id string, value []byte) ([]byte, error) {
+				converted = append(converted, id)
+				if id == "1" {
+					return nil, nil
+				}
+				return value, nil
+			})
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error registering migration: %v", err)
+	}
+
+	_, err = Database.BoltDb.RunMigrations(false)
+	if err != nil {
+		t.Fatalf("Migration failed: %v", err)
+	}
+
+	if len(converted) != 2 {
+		t.Errorf("Expected the nested bucket to be skipped but convert got %v", converted)
+	}
+
+	Database.BoltDb.view(func(tx *bolt.Tx) error {
+		if !boltExpiryOf(tx, "people", "1").IsZero() {
+			t.Error("Expected the expiry of the removed entry to be cleared")
+		}
+		if currentBoltRevision(tx, "people", "1") != 0 || storedBoltRevision(tx, "people", "1") != 1 {
+			t.Error("Expected the removed entry to have no revision and keep its revision counter")
+		}
+		return nil
+	})
+
+	watcher, err := Database.BoltDb.WatchFrom("people", "", 2)
+	if err != nil {
+		t.Fatalf("Error watching bucket: %v", err)
+	}
+	defer watcher.Close()
+
+	// Entries are written in no particular order
+	events := make(map[string]BoltChangeEvent)
+	for i := 0; i < 2; i++ {
+		event := receiveChangeEvent(t, watcher)
+		events[event.Id] = event
+	}
+
+	if events["1"].Type != BoltChangeDelete {
+		t.Errorf("Expected a delete event for the removed entry but got %+v", events["1"])
+	}
+	if events["2"].Type != BoltChangePut || len(events["2"].Value) < 1 {
+		t.Errorf("Expected a put event with the value of the rewritten entry but got %+v", events["2"])
+	}
+
+	revision, err := Database.BoltDb.ReadObjectWithRevision("people", "2", &testObjectV1{})
+	if err != nil || revision != 2 {
+		t.Errorf("Expected the rewrite to bump the revision to 2 but got %d, %v", revision, err)
+	}
+}
+
+func TestMigrateBucketObjectsKeepsExpiry(t *testing.T) {
+	useTempBoltDB(t)
+	useCleanMigrationRegistry(t)
+
+	err := Database.BoltDb.SaveObjectWithTTL("people", "1", testObjectV1{Id: "1", Name: "John Smith"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+
+	var expiresAt time.Time
+	Database.BoltDb.view(func(tx *bolt.Tx) error {
+		expiresAt = boltExpiryOf(tx, "people", "1")
+		return nil
+	})
+
+	err = RegisterBoltMigration(BoltMigration{
+		Version: 1,
+		Migrate: func(tx *bolt.Tx) error {
+			return MigrateBucketObjects(tx, "people", func(id string, value []byte) ([]byte, error) {
+				return []byte(`{"Id":"1","Name":"John","Surname":"Smith"}`), nil
+			})
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error registering migration: %v", err)
+	}
+
+	_, err = Database.BoltDb.RunMigrations(false)
+	if err != nil {
+		t.Fatalf("Migration failed: %v", err)
+	}
+
+	Database.BoltDb.view(func(tx *bolt.Tx) error {
+		if !boltExpiryOf(tx, "people", "1").Equal(expiresAt) {
+			t.Errorf("Expected the expiry %v to be kept but got %v", expiresAt, boltExpiryOf(tx, "people", "1"))
+		}
+		return nil
+	})
+}
+
+func TestMigrationsOfNamedDatabases(t *testing.T) {
+	useTempBoltDB(t)
+	useCleanMigrationRegistry(t)
+
+	cache := &boltDbDatabase{name: "cache", config: &boltDBConfig{Enabled: true, FileName: filepath.Join(t.TempDir(), "cache.db")}}
+	err := cache.Open()
+	if err != nil {
+		t.Fatalf("Unable to open named database: %v", err)
+	}
+
+	boltDatabasesLock.Lock()
+	boltDatabases["cache"] = cache
+	boltDatabasesLock.Unlock()
+	t.Cleanup(func() {
+		boltDatabasesLock.Lock()
+		delete(boltDatabases, "cache")
+		boltDatabasesLock.Unlock()
+		cache.Close()
+	})
+
+	migrated := make(map[string]int)
+	for _, database := range []string{"", "Cache"} {
+		database := database
+		err = RegisterBoltMigration(BoltMigration{
+			Version:  1,
+			Database: database,
+			Migrate: func(tx *bolt.Tx) error {
+				migrated[database]++
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Expected versions to be numbered per database but got %v", err)
+		}
+	}
+
+	err = RegisterBoltMigration(BoltMigration{Version: 1, Database: "default", Migrate: func(tx *bolt.Tx) error { return nil }})
+	if err != ErrBoltMigrationDuplicateVersion {
+		t.Errorf("Expected \"default\" to name the default database but got %v", err)
+	}
+
+	config, _ := GetPlatformConfiguration()
+	err = runPlatformBoltMigrations(config)
+	if err != nil {
+		t.Fatalf("Migrations failed: %v", err)
+	}
+
+	if migrated["Cache"] != 1 {
+		t.Errorf("Expected the migration of the named database to run once but got %v", migrated)
+	}
+
+	statuses, err := cache.ListMigrations()
+	if err != nil || len(statuses) != 1 || !statuses[0].Applied {
+		t.Errorf("Expected the migration to be applied to the named database but got %v, %v", statuses, err)
+	}
+}
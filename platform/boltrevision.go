@@ -0,0 +1,159 @@
+package platform
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"go.uber.org/zap"
+)
+
+const (
+	// Holds a nested bucket per data bucket with the revision of each key. Kept when the key is removed,
+	// so that a recreated entry continues from the last revision and old ETags do not match it
+	boltRevisionBucket = "_revisions"
+)
+
+var (
+	ErrRevisionConflict = errors.New("revision conflict")
+)
+
+// RevisionConflictError ... Returned by CompareAndSave when the stored revision is not the expected one.
+// errors.Is(err, ErrRevisionConflict) matches it
+type RevisionConflictError struct {
+	Bucket   string
+	Id       string
+	Expected uint64
+	Actual   uint64
+}
+
+func (e *RevisionConflictError) Error() string {
+	return fmt.Sprintf("revision conflict for %s/%s: expected %d, actual %d", e.Bucket, e.Id, e.Expected, e.Actual)
+}
+
+func (e *RevisionConflictError) Is(target error) bool {
+	return target == ErrRevisionConflict
+}
+
+// Revision of an entry. 0 if it does not exist.
+// Entries written before revisions were tracked are at revision 1
+func currentBoltRevision(tx *bolt.Tx, bucket string, id string) uint64 {
+	b := lookupBoltBucket(tx, bucket)
+	if b == nil || b.Get([]byte(id)) == nil || boltExpiryChecker(tx, bucket)([]byte(id)) {
+		return 0
+	}
+
+	if revision := storedBoltRevision(tx, bucket, id); revision > 0 {
+		return revision
+	}
+
+	return 1
+}
+
+// Last revision recorded for the key, also after it was removed or expired. 0 if none was recorded
+func storedBoltRevision(tx *bolt.Tx, bucket string, id string) uint64 {
+	revisions := tx.Bucket([]byte(boltRevisionBucket))
+	if revisions == nil || revisions.Bucket([]byte(bucket)) == nil {
+		return 0
+	}
+
+	value := revisions.Bucket([]byte(bucket)).Get([]byte(id))
+	if len(value) != 8 {
+		return 0
+	}
+
+	return binary.BigEndian.Uint64(value)
+}
+
+func nextBoltRevision(tx *bolt.Tx, bucket string, id string) (uint64, error) {
+	revision := currentBoltRevision(tx, bucket, id)
+	if stored := storedBoltRevision(tx, bucket, id); stored > revision {
+		revision = stored
+	}
+	revision++
+
+	revisions, err := tx.CreateBucketIfNotExists([]byte(boltRevisionBucket))
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := revisions.CreateBucketIfNotExists([]byte(bucket))
+	if err != nil {
+		return 0, err
+	}
+
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, revision)
+
+	return revision, b.Put([]byte(id), value)
+}
+
+// ReadObjectWithRevision ... Same as ReadObject but also returns the revision of the entry
+func (d *boltDbDatabase) ReadObjectWithRevision(bucket string, id string, object interface{}) (_ uint64, err error) {
+	bucket = cleanBoltBucketPath(bucket)
+	defer observeBoltOperation(d, "read", time.Now(), &err)
+	var revision uint64
+	err = d.view(func(tx *bolt.Tx) error {
+		revision = currentBoltRevision(tx, bucket, id)
+		if revision == 0 {
+			return ErrNoEntryFoundInDB
+		}
+
+		return unmarshalBoltObject(tx, bucket, id, lookupBoltBucket(tx, bucket).Get([]byte(id)), object)
+	})
+	if err != nil {
+		if err != ErrNoEntryFoundInDB {
+			Log.Error("Error reading from database", zap.Error(err))
+		}
+		return 0, err
+	}
+
+	return revision, nil
+}
+
+// CompareAndSave ... Saves the object only if the stored revision is expectedRevision.
+// Use 0 to only create the entry if it does not exist. Returns the new revision or a *RevisionConflictError
+func (d *boltDbDatabase) CompareAndSave(bucket string, id string, object interface{}, expectedRevision uint64) (uint64, error) {
+	return d.compareAndSave(bucket, id, object, expectedRevision, func(actual uint64) bool {
+		return actual == expectedRevision
+	})
+}
+
+// CompareAndSaveIfMatch ... Saves the object only if the entry exists and its revision matches the If-Match header.
+// Returns the new revision or a *RevisionConflictError with the first revision of the header as Expected
+func (d *boltDbDatabase) CompareAndSaveIfMatch(bucket string, id string, object interface{}, ifMatch IfMatch) (uint64, error) {
+	var expected uint64
+	if len(ifMatch.Revisions) > 0 {
+		expected = ifMatch.Revisions[0]
+	}
+
+	return d.compareAndSave(bucket, id, object, expected, ifMatch.Matches)
+}
+
+func (d *boltDbDatabase) compareAndSave(bucket string, id string, object interface{}, expected uint64, matches func(actual uint64) bool) (_ uint64, err error) {
+	bucket = cleanBoltBucketPath(bucket)
+	defer observeBoltOperation(d, "compare_and_save", time.Now(), &err)
+	var revision uint64
+	err = d.update(func(tx *bolt.Tx) error {
+		actual := currentBoltRevision(tx, bucket, id)
+		if !matches(actual) {
+			return &RevisionConflictError{Bucket: bucket, Id: id, Expected: expected, Actual: actual}
+		}
+
+		var err error
+		revision, err = putBoltObject(tx, bucket, id, object, time.Time{})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrRevisionConflict) {
+			Log.Warn("Revision conflict", zap.String("bucket", bucket), zap.String("id", id), zap.Error(err))
+		} else {
+			Log.Error("Error updating DB", zap.Error(err))
+		}
+		return 0, err
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,149 @@
+package platform
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompareAndSave(t *testing.T) {
+	useTempBoltDB(t)
+
+	revision, err := Database.BoltDb.CompareAndSave("people", "1", testObject{Id: "1", Name: "John"}, 0)
+	if err != nil || revision != 1 {
+		t.Fatalf("Expected revision 1 on create: %d %v", revision, err)
+	}
+
+	_, err = Database.BoltDb.CompareAndSave("people", "1", testObject{Id: "1", Name: "Jane"}, 0)
+	if !errors.Is(err, ErrRevisionConflict) {
+		t.Errorf("Expected conflict when creating an existing entry, got %v", err)
+	}
+
+	err = Database.BoltDb.SaveObject("people", "1", testObject{Id: "1", Name: "Jack"})
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+
+	result := testObject{}
+	revision, err = Database.BoltDb.ReadObjectWithRevision("people", "1", &result)
+	if err != nil || revision != 2 || result.Name != "Jack" {
+		t.Fatalf("Expected revision 2 after SaveObject: %d %v %v", revision, result, err)
+	}
+
+	_, err = Database.BoltDb.CompareAndSave("people", "1", testObject{Id: "1", Name: "Stale"}, 1)
+	conflict := &RevisionConflictError{}
+	if !errors.As(err, &conflict) || conflict.Actual != 2 {
+		t.Fatalf("Expected conflict with actual revision 2, got %v", err)
+	}
+
+	revision, err = Database.BoltDb.CompareAndSave("people", "1", testObject{Id: "1", Name: "Fresh"}, 2)
+	if err != nil || revision != 3 {
+		t.Fatalf("Expected revision 3: %d %v", revision, err)
+	}
+
+	recorder := httptest.NewRecorder()
+	if !WriteRevisionConflict(recorder, conflict) {
+		t.Fatalf("Conflict should be written")
+	}
+
+	if recorder.Code != http.StatusPreconditionFailed || recorder.Header().Get("ETag") != `"2"` {
+		t.Errorf("Expected 412 with ETag, got %d %s", recorder.Code, recorder.Header().Get("ETag"))
+	}
+}
+
+func TestRequireIfMatch(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPut, "/people/1", nil)
+	recorder := httptest.NewRecorder()
+	_, ok := RequireIfMatch(recorder, request)
+	if ok || recorder.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected 428 without If-Match, got %d", recorder.Code)
+	}
+
+	request.Header.Set("If-Match", RevisionETag(7))
+	ifMatch, ok := RequireIfMatch(httptest.NewRecorder(), request)
+	if !ok || !ifMatch.Matches(7) || ifMatch.Matches(8) {
+		t.Errorf("Expected revision 7, got %+v", ifMatch)
+	}
+
+	request.Header.Set("If-Match", "abc")
+	recorder = httptest.NewRecorder()
+	_, ok = RequireIfMatch(recorder, request)
+	if ok || recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid If-Match, got %d", recorder.Code)
+	}
+}
+
+func TestIfMatchForms(t *testing.T) {
+	tests := []struct {
+		header  string
+		matches map[uint64]bool
+	}{
+		{"*", map[uint64]bool{0: false, 1: true, 9: true}},
+		{`"3", "5"`, map[uint64]bool{3: true, 4: false, 5: true}},
+		{`W/"3"`, map[uint64]bool{3: false}},
+		{`W/"3", "4"`, map[uint64]bool{3: false, 4: true}},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodPut, "/people/1", nil)
+		request.Header.Set("If-Match", test.header)
+		ifMatch, present, err := RevisionFromIfMatch(request)
+		if err != nil || !present {
+			t.Fatalf("Unexpected result for %s: %v %v", test.header, present, err)
+		}
+
+		for revision, expected := range test.matches {
+			if ifMatch.Matches(revision) != expected {
+				t.Errorf("Expected %s to match revision %d: %v", test.header, revision, expected)
+			}
+		}
+	}
+}
+
+func TestCompareAndSaveIfMatch(t *testing.T) {
+	useTempBoltDB(t)
+
+	_, err := Database.BoltDb.CompareAndSaveIfMatch("people", "1", testObject{Id: "1"}, IfMatch{Any: true})
+	if !errors.Is(err, ErrRevisionConflict) {
+		t.Errorf("Expected * not to match an entry that does not exist, got %v", err)
+	}
+
+	Database.BoltDb.SaveObject("people", "1", testObject{Id: "1"})
+
+	_, err = Database.BoltDb.CompareAndSaveIfMatch("people", "1", testObject{Id: "1"}, IfMatch{})
+	if !errors.Is(err, ErrRevisionConflict) {
+		t.Errorf("Expected only weak ETags to fail the precondition, got %v", err)
+	}
+
+	revision, err := Database.BoltDb.CompareAndSaveIfMatch("people", "1", testObject{Id: "1"}, IfMatch{Revisions: []uint64{5, 1}})
+	if err != nil || revision != 2 {
+		t.Errorf("Expected a match in the list to save revision 2, got %d %v", revision, err)
+	}
+}
+
+func TestRevisionContinuesAfterRemove(t *testing.T) {
+	useTempBoltDB(t)
+
+	Database.BoltDb.SaveObject("people", "1", testObject{Id: "1"})
+	Database.BoltDb.SaveObject("people", "1", testObject{Id: "1"})
+	Database.BoltDb.RemoveObject("people", "1")
+
+	_, err := Database.BoltDb.ReadObjectWithRevision("people", "1", &testObject{})
+	if err != ErrNoEntryFoundInDB {
+		t.Fatalf("Expected the entry to be removed, got %v", err)
+	}
+
+	revision, err := Database.BoltDb.CompareAndSave("people", "1", testObject{Id: "1"}, 0)
+	if err != nil || revision != 3 {
+		t.Errorf("Expected the recreated entry to continue at revision 3, got %d %v", revision, err)
+	}
+
+	Database.BoltDb.RemoveBucket("people")
+	Database.BoltDb.SaveObject("people", "1", testObject{Id: "1"})
+
+	revision, _ = Database.BoltDb.ReadObjectWithRevision("people", "1", &testObject{})
+	if revision != 4 {
+		t.Errorf("Expected the revision to continue after the bucket was removed, got %d", revision)
+	}
+}
@@ -0,0 +1,394 @@
+package platform
+
+import (
+	"errors"
+	"expvar"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"go.uber.org/zap"
+)
+
+const (
+	// Bytes copied per transaction when compacting
+	boltCompactTxSize = 64 * 1024 * 1024
+	// Written next to the database file and renamed over it when complete
+	boltCompactSuffix = ".compact"
+)
+
+var (
+	ErrBoltCompactReadOnly = errors.New("a read only BoltDB cannot be compacted")
+)
+
+// BoltStats ... Size and page usage of a BoltDB file. Buckets includes the internal buckets
+type BoltStats struct {
+	Name     string `json:"name"`
+	FileName string `json:"fileName"`
+	FileSize int64  `json:"fileSize"`
+	PageSize int    `json:"pageSize"`
+	// Pages that can be reused for new data
+	FreePages int `json:"freePages"`
+	// Pages freed by transactions that are still being read
+	PendingPages     int                        `json:"pendingPages"`
+	FreeBytes        int                        `json:"freeBytes"`
+	FreelistBytes    int                        `json:"freelistBytes"`
+	Transactions     int                        `json:"transactions"`
+	OpenTransactions int                        `json:"openTransactions"`
+	Buckets          map[string]BoltBucketStats `json:"buckets"`
+	Cache            map[string]CacheStats      `json:"cache,omitempty"`
+}
+
+// BoltBucketStats ... Counts include nested buckets
+type BoltBucketStats struct {
+	Keys          int `json:"keys"`
+	NestedBuckets int `json:"nestedBuckets"`
+	Depth         int `json:"depth"`
+	LeafPages     int `json:"leafPages"`
+	BranchPages   int `json:"branchPages"`
+	// Bytes of the pages allocated to the bucket and the bytes actually used
+	AllocatedBytes int `json:"allocatedBytes"`
+	UsedBytes      int `json:"usedBytes"`
+}
+
+// BoltCompactResult ... File sizes before and after compacting
+type BoltCompactResult struct {
+	SizeBefore int64         `json:"sizeBefore"`
+	SizeAfter  int64         `json:"sizeAfter"`
+	Duration   time.Duration `json:"duration"`
+}
+
+func init() {
+	// Available on /debug/vars when expvar is served
+	expvar.Publish("boltdb", expvar.Func(func() interface{} {
+		return Database.BoltStats()
+	}))
+}
+
+// Stats ... Page and bucket statistics of the open database
+func (d *boltDbDatabase) Stats() (BoltStats, error) {
+	stats := BoltStats{
+		Name:    d.name,
+		Buckets: make(map[string]BoltBucketStats),
+	}
+	if len(stats.Name) < 1 {
+		stats.Name = "default"
+	}
+
+	err := d.use(func(db *bolt.DB) error {
+		dbStats := db.Stats()
+		stats.FileName = db.Path()
+		stats.PageSize = db.Info().PageSize
+		stats.FreePages = dbStats.FreePageN
+		stats.PendingPages = dbStats.PendingPageN
+		stats.FreeBytes = dbStats.FreeAlloc
+		stats.FreelistBytes = dbStats.FreelistInuse
+		stats.Transactions = dbStats.TxN
+		stats.OpenTransactions = dbStats.OpenTxN
+
+		if info, err := os.Stat(db.Path()); err == nil {
+			stats.FileSize = info.Size()
+		}
+
+		return db.View(func(tx *bolt.Tx) error {
+			return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				bucketStats := b.Stats()
+				stats.Buckets[string(name)] = BoltBucketStats{
+					Keys:           bucketStats.KeyN,
+					NestedBuckets:  bucketStats.BucketN - 1,
+					Depth:          bucketStats.Depth,
+					LeafPages:      bucketStats.LeafPageN + bucketStats.LeafOverflowN,
+					BranchPages:    bucketStats.BranchPageN + bucketStats.BranchOverflowN,
+					AllocatedBytes: bucketStats.LeafAlloc + bucketStats.BranchAlloc,
+					UsedBytes:      bucketStats.LeafInuse + bucketStats.BranchInuse,
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		Log.Error("Error reading bucket statistics", zap.String("name", stats.Name), zap.Error(err))
+		return stats, err
+	}
+
+	stats.Cache = d.CacheStats()
+
+	return stats, nil
+}
+
+// BoltStats ... Statistics of the default and named databases that are open, by name
+func (p *PlatformDatabases) BoltStats() map[string]BoltStats {
+	databases := []*boltDbDatabase{&p.BoltDb}
+
+	boltDatabasesLock.RLock()
+	for _, d := range boltDatabases {
+		databases = append(databases, d)
+	}
+	boltDatabasesLock.RUnlock()
+
+	result := make(map[string]BoltStats)
+	for _, d := range databases {
+		stats, err := d.Stats()
+		if err != nil {
+			continue
+		}
+		result[stats.Name] = stats
+	}
+
+	return result
+}
+
+// Compact ... Rewrites the file without free pages. Calls made while compacting wait until the
+// compacted file is open. The original file is only replaced once the copy is complete
+func (d *boltDbDatabase) Compact() (BoltCompactResult, error) {
+	settings := d.settings()
+	if settings.ReadOnly {
+		return BoltCompactResult{}, ErrBoltCompactReadOnly
+	}
+
+	d.swapLock.Lock()
+	defer d.swapLock.Unlock()
+
+	if d.db == nil {
+		if d.openErr != nil {
+			return BoltCompactResult{}, d.openErr
+		}
+		return BoltCompactResult{}, ErrBoltDBNoDBObject
+	}
+
+	old := d.db
+	fileName := old.Path()
+
+	Log.Info("Compacting BoltDB", zap.String("name", d.name), zap.String("filename", fileName))
+
+	// Calls hold swapLock for their whole transaction, so none is running and nothing is written during the copy
+	err := old.Close()
+	if err != nil {
+		Log.Error("Error closing BoltDB for compaction", zap.Error(err))
+		return BoltCompactResult{}, err
+	}
+
+	result, compactErr := compactBoltFile(fileName, "")
+
+	db, err := openBoltDatabase(fileName, settings.FileMode, settings.OpenTimeoutSeconds, false)
+	if err != nil {
+		Log.Error("Error reopening BoltDB after compaction", zap.String("filename", fileName), zap.Error(err))
+		d.db = nil
+		d.openErr = err
+		return result, err
+	}
+
+	db.NoSync = settings.NoSync
+	db.NoGrowSync = settings.NoGrowSync
+	d.db = db
+	rebindBoltWatchers(old, db)
+
+	if compactErr != nil {
+		return result, compactErr
+	}
+
+	Log.Info("BoltDB compacted",
+		zap.String("name", d.name),
+		zap.Int64("size_before", result.SizeBefore),
+		zap.Int64("size_after", result.SizeAfter),
+		zap.Duration("duration", result.Duration))
+
+	return result, nil
+}
+
+// Copies the closed database into a new file. The copy replaces the original when outFile is empty
+func compactBoltFile(fileName string, outFile string) (BoltCompactResult, error) {
+	start := time.Now()
+	result := BoltCompactResult{}
+
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return result, err
+	}
+	result.SizeBefore = info.Size()
+
+	target := outFile
+	if len(target) < 1 {
+		target = fileName + boltCompactSuffix
+	}
+	os.Remove(target)
+
+	src, err := openBoltDatabase(fileName, "", boltDefaultOpenTimeoutSeconds, true)
+	if err != nil {
+		return result, err
+	}
+	defer src.Close()
+
+	dst, err := openBoltDatabase(target, strconv.FormatUint(uint64(info.Mode().Perm()), 8), boltDefaultOpenTimeoutSeconds, false)
+	if err != nil {
+		return result, err
+	}
+
+	err = copyBoltDatabase(src, dst)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		Log.Error("Error compacting BoltDB", zap.String("filename", fileName), zap.Error(err))
+		os.Remove(target)
+		return result, err
+	}
+
+	src.Close()
+
+	if len(outFile) < 1 {
+		err = os.Rename(target, fileName)
+		if err != nil {
+			Log.Error("Error replacing BoltDB with the compacted copy", zap.String("filename", fileName), zap.Error(err))
+			os.Remove(target)
+			return result, err
+		}
+	}
+
+	compacted := fileName
+	if len(outFile) > 0 {
+		compacted = outFile
+	}
+	if info, err := os.Stat(compacted); err == nil {
+		result.SizeAfter = info.Size()
+	}
+	result.Duration = time.Since(start)
+
+	return result, nil
+}
+
+// Writes the buckets into the destination in transactions of boltCompactTxSize
+type boltCompactor struct {
+	dst  *bolt.DB
+	tx   *bolt.Tx
+	size int
+}
+
+func copyBoltDatabase(src *bolt.DB, dst *bolt.DB) error {
+	tx, err := dst.Begin(true)
+	if err != nil {
+		return err
+	}
+
+	c := &boltCompactor{dst: dst, tx: tx}
+	err = src.View(func(srcTx *bolt.Tx) error {
+		return srcTx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return c.copyBucket(b, [][]byte{name})
+		})
+	})
+	if err != nil {
+		c.tx.Rollback()
+		return err
+	}
+
+	return c.tx.Commit()
+}
+
+func (c *boltCompactor) copyBucket(src *bolt.Bucket, path [][]byte) error {
+	_, err := c.bucket(path)
+	if err != nil {
+		return err
+	}
+
+	err = src.ForEach(func(key, value []byte) error {
+		// Nested buckets have no value
+		if value == nil {
+			return c.copyBucket(src.Bucket(key), append(path[:len(path):len(path)], key))
+		}
+
+		err := c.reserve(len(key) + len(value))
+		if err != nil {
+			return err
+		}
+
+		b, err := c.bucket(path)
+		if err != nil {
+			return err
+		}
+
+		// Keys are copied in order so pages can be filled completely
+		b.FillPercent = 1.0
+		return b.Put(key, value)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Revisions and change feed sequences continue from the same numbers
+	b, err := c.bucket(path)
+	if err != nil {
+		return err
+	}
+
+	return b.SetSequence(src.Sequence())
+}
+
+func (c *boltCompactor) bucket(path [][]byte) (*bolt.Bucket, error) {
+	b, err := c.tx.CreateBucketIfNotExists(path[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range path[1:] {
+		b, err = b.CreateBucketIfNotExists(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// Commits and starts a new transaction when the current one is full
+func (c *boltCompactor) reserve(size int) error {
+	c.size += size
+	if c.size < boltCompactTxSize {
+		return nil
+	}
+
+	err := c.tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	c.tx, err = c.dst.Begin(true)
+	c.size = size
+
+	return err
+}
+
+// BoltStatsRoute ... Admin route with the statistics of all open databases. Authentication is always required
+func BoltStatsRoute(path string, rolesRequired []string) Route {
+	return Route{
+		Path:   path,
+		Method: http.MethodGet,
+		HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+			JsonMarshaller.WriteJsonResponse(w, http.StatusOK, Database.BoltStats())
+		},
+		RolesRequired: rolesRequired,
+		AuthRequired:  true,
+	}
+}
+
+// BoltCompactRoute ... Admin route that compacts the database named by ?database=, the default database if empty.
+// Authentication is always required
+func BoltCompactRoute(path string, rolesRequired []string) Route {
+	return Route{
+		Path:   path,
+		Method: http.MethodPost,
+		HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+			result, err := Database.Bolt(r.URL.Query().Get("database")).Compact()
+			if err != nil {
+				Log.Error("Error compacting BoltDB", zap.Error(err))
+				JsonMarshaller.WriteError(w, err)
+				return
+			}
+
+			JsonMarshaller.WriteJsonResponse(w, http.StatusOK, result)
+		},
+		RolesRequired: rolesRequired,
+		AuthRequired:  true,
+	}
+}
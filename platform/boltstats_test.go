@@ -0,0 +1,135 @@
+package platform
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestBoltStats(t *testing.T) {
+	useTempBoltDB(t)
+
+	for i := 0; i < 10; i++ {
+		Database.BoltDb.SaveObject("people", fmt.Sprint(i), testObject{Id: fmt.Sprint(i)})
+	}
+
+	stats, err := Database.BoltDb.Stats()
+	if err != nil {
+		t.Fatalf("Error reading stats: %v", err)
+	}
+
+	if stats.Name != "default" || stats.FileSize < 1 || stats.PageSize < 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if stats.Buckets["people"].Keys != 10 {
+		t.Errorf("Expected 10 keys but got %+v", stats.Buckets["people"])
+	}
+	if _, ok := stats.Buckets[boltRevisionBucket]; !ok {
+		t.Error("Expected internal buckets in the stats")
+	}
+
+	if _, ok := Database.BoltStats()["default"]; !ok {
+		t.Error("Expected the default database in BoltStats")
+	}
+}
+
+func TestBoltCompact(t *testing.T) {
+	useTempBoltDB(t)
+
+	large := strings.Repeat("a", 4096)
+	for i := 0; i < 200; i++ {
+		Database.BoltDb.SaveObject("people", fmt.Sprint(i), testObject{Id: fmt.Sprint(i), Name: large})
+	}
+	for i := 0; i < 190; i++ {
+		Database.BoltDb.RemoveObject("people", fmt.Sprint(i))
+	}
+
+	revision, _ := Database.BoltDb.ReadObjectWithRevision("people", "195", &testObject{})
+
+	result, err := Database.BoltDb.Compact()
+	if err != nil {
+		t.Fatalf("Error compacting: %v", err)
+	}
+	defer Database.BoltDb.db.Close()
+
+	if result.SizeAfter >= result.SizeBefore {
+		t.Errorf("Expected the file to shrink but got %+v", result)
+	}
+
+	item := testObject{}
+	current, err := Database.BoltDb.ReadObjectWithRevision("people", "195", &item)
+	if err != nil || item.Id != "195" || current != revision {
+		t.Errorf("Unexpected value after compaction %+v, revision %d, %v", item, current, err)
+	}
+
+	err = Database.BoltDb.SaveObject("people", "new", testObject{Id: "new"})
+	if err != nil {
+		t.Errorf("Expected writes after compaction to work but got %v", err)
+	}
+}
+
+func TestCompactWaitsForTransactions(t *testing.T) {
+	useTempBoltDB(t)
+	Database.BoltDb.SaveObject("people", "a", testObject{Id: "a"})
+
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- Database.BoltDb.view(func(tx *bolt.Tx) error {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			if lookupBoltBucket(tx, "people").Get([]byte("a")) == nil {
+				return ErrNoEntryFoundInDB
+			}
+			return nil
+		})
+	}()
+
+	<-started
+	_, err := Database.BoltDb.Compact()
+	if err != nil {
+		t.Fatalf("Error compacting: %v", err)
+	}
+	defer Database.BoltDb.db.Close()
+
+	err = <-done
+	if err != nil {
+		t.Errorf("Expected the running transaction to complete before the file was closed but got %v", err)
+	}
+}
+
+func TestCompactCommand(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "command.db")
+	db, err := openBoltDatabase(fileName, "", 1, false)
+	if err != nil {
+		t.Fatalf("Unable to create database: %v", err)
+	}
+	db.Update(func(tx *bolt.Tx) error {
+		_, err := putBoltObject(tx, "people", "a", testObject{Id: "a", Name: "Anna"}, time.Time{})
+		return err
+	})
+	db.Close()
+
+	compacted := filepath.Join(t.TempDir(), "compacted.db")
+	out := bytes.Buffer{}
+	err = RunDatabaseCommand([]string{"compact", "-file", fileName, "-out", compacted}, &out)
+	if err != nil {
+		t.Fatalf("Error running compact: %v", err)
+	}
+
+	if _, err := os.Stat(compacted); err != nil {
+		t.Fatalf("Expected the compacted copy: %v", err)
+	}
+
+	out.Reset()
+	err = RunDatabaseCommand([]string{"stats", "-file", compacted}, &out)
+	if err != nil || !strings.Contains(out.String(), `"people"`) {
+		t.Errorf("Unexpected stats output %s, %v", out.String(), err)
+	}
+}
@@ -0,0 +1,360 @@
+package platform
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"go.uber.org/zap"
+)
+
+const (
+	// Holds the last sequence number and the retained change events keyed by sequence
+	boltChangeFeedBucket = "_changefeed"
+	boltChangeFeedLog    = "log"
+
+	BoltChangePut    = "put"
+	BoltChangeDelete = "delete"
+
+	boltChangeFeedDefaultRetention = 10000
+	boltWatcherBufferSize          = 256
+)
+
+var (
+	ErrBoltChangeFeedNotEnabled = errors.New("BoltDB change feed is not enabled")
+	ErrBoltWatcherOverflow      = errors.New("watcher fell behind and was closed. Resume with WatchFrom")
+	ErrBoltChangeFeedTruncated  = errors.New("changes after the sequence are no longer retained. Read the bucket again and use Watch")
+
+	boltChangeFeedSequenceKey = []byte("sequence")
+
+	boltWatchers     = make(map[*BoltWatcher]bool)
+	boltWatchersLock sync.Mutex
+)
+
+// BoltChangeEvent ... A committed change to a bucket.
+// An empty Id on a delete event means the whole bucket was removed
+type BoltChangeEvent struct {
+	Sequence uint64          `json:"sequence"`
+	Type     string          `json:"type"`
+	Bucket   string          `json:"bucket"`
+	Id       string          `json:"id"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Time     time.Time       `json:"time"`
+}
+
+// BoltWatcher ... Subscription to committed changes of a bucket
+type BoltWatcher struct {
+	db     *bolt.DB
+	bucket string
+	prefix string
+
+	events    chan BoltChangeEvent
+	live      chan BoltChangeEvent
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+func (d *boltDbDatabase) changeFeedEnabled() bool {
+	return d.settings().ChangeFeed.Enabled
+}
+
+func (d *boltDbDatabase) changeFeedRetention() uint64 {
+	if d.settings().ChangeFeed.Retention > 0 {
+		return uint64(d.settings().ChangeFeed.Retention)
+	}
+
+	return boltChangeFeedDefaultRetention
+}
+
+func boltSequenceKey(sequence uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, sequence)
+	return key
+}
+
+// Records the change in the same transaction and publishes it to watchers once committed
+func recordBoltChange(tx *bolt.Tx, changeType string, bucket string, id string, value []byte) error {
+	d := boltDatabaseFor(tx.DB())
+	if !d.changeFeedEnabled() {
+		return nil
+	}
+
+	feed, err := tx.CreateBucketIfNotExists([]byte(boltChangeFeedBucket))
+	if err != nil {
+		return err
+	}
+
+	changeLog, err := feed.CreateBucketIfNotExists([]byte(boltChangeFeedLog))
+	if err != nil {
+		return err
+	}
+
+	sequence := uint64(1)
+	if current := feed.Get(boltChangeFeedSequenceKey); len(current) == 8 {
+		sequence = binary.BigEndian.Uint64(current) + 1
+	}
+
+	err = feed.Put(boltChangeFeedSequenceKey, boltSequenceKey(sequence))
+	if err != nil {
+		return err
+	}
+
+	event := BoltChangeEvent{
+		Sequence: sequence,
+		Type:     changeType,
+		Bucket:   bucket,
+		Id:       id,
+		Time:     time.Now().UTC(),
+	}
+	if value != nil {
+		// The value is only valid for the life of the transaction
+		event.Value = append(json.RawMessage{}, value...)
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	err = changeLog.Put(boltSequenceKey(sequence), data)
+	if err != nil {
+		return err
+	}
+
+	retention := d.changeFeedRetention()
+	if sequence > retention {
+		err = changeLog.Delete(boltSequenceKey(sequence - retention))
+		if err != nil {
+			return err
+		}
+	}
+
+	db := tx.DB()
+	tx.OnCommit(func() {
+		publishBoltChange(db, event)
+	})
+
+	return nil
+}
+
+func publishBoltChange(db *bolt.DB, event BoltChangeEvent) {
+	boltWatchersLock.Lock()
+	defer boltWatchersLock.Unlock()
+
+	for watcher := range boltWatchers {
+		if watcher.db != db || !watcher.matches(event) {
+			continue
+		}
+
+		select {
+		case watcher.live <- event:
+		default:
+			Log.Warn("BoltDB watcher fell behind. Closing it", zap.String("bucket", watcher.bucket),
+				zap.Uint64("sequence", event.Sequence))
+			delete(boltWatchers, watcher)
+			close(watcher.live)
+		}
+	}
+}
+
+// Keeps the watchers of a database that was reopened, e.g. after compaction
+func rebindBoltWatchers(old *bolt.DB, db *bolt.DB) {
+	boltWatchersLock.Lock()
+	defer boltWatchersLock.Unlock()
+
+	for watcher := range boltWatchers {
+		if watcher.db == old {
+			watcher.db = db
+		}
+	}
+}
+
+func (w *BoltWatcher) matches(event BoltChangeEvent) bool {
+	if event.Bucket != w.bucket {
+		return false
+	}
+
+	// Bucket removals are sent to every watcher of the bucket
+	return len(event.Id) < 1 || strings.HasPrefix(event.Id, w.prefix)
+}
+
+// Watch ... Subscribes to changes committed after this call for keys in the bucket starting with prefix
+func (d *boltDbDatabase) Watch(bucket string, prefix string) (*BoltWatcher, error) {
+	return d.watch(bucket, prefix, 0, false)
+}
+
+// WatchFrom ... Same as Watch but first replays retained changes after the given sequence number.
+// Use the Sequence of the last event processed to resume after a restart. Returns ErrBoltChangeFeedTruncated
+// when changes after the sequence were already removed by the retention
+func (d *boltDbDatabase) WatchFrom(bucket string, prefix string, sequence uint64) (*BoltWatcher, error) {
+	return d.watch(bucket, prefix, sequence, true)
+}
+
+// LastSequence ... Sequence number of the last committed change
+func (d *boltDbDatabase) LastSequence() (uint64, error) {
+	var sequence uint64
+	err := d.view(func(tx *bolt.Tx) error {
+		feed := tx.Bucket([]byte(boltChangeFeedBucket))
+		if feed == nil {
+			return nil
+		}
+
+		if current := feed.Get(boltChangeFeedSequenceKey); len(current) == 8 {
+			sequence = binary.BigEndian.Uint64(current)
+		}
+
+		return nil
+	})
+
+	return sequence, err
+}
+
+func (d *boltDbDatabase) watch(bucket string, prefix string, from uint64, replay bool) (*BoltWatcher, error) {
+	bucket = cleanBoltBucketPath(bucket)
+	if !d.changeFeedEnabled() {
+		return nil, ErrBoltChangeFeedNotEnabled
+	}
+
+	watcher := &BoltWatcher{
+		bucket: bucket,
+		prefix: prefix,
+		events: make(chan BoltChangeEvent),
+		live:   make(chan BoltChangeEvent, boltWatcherBufferSize),
+		done:   make(chan struct{}),
+	}
+
+	backlog := make([]BoltChangeEvent, 0)
+	err := d.use(func(db *bolt.DB) error {
+		// Register before reading the log so that nothing committed in between is missed
+		boltWatchersLock.Lock()
+		watcher.db = db
+		boltWatchers[watcher] = true
+		boltWatchersLock.Unlock()
+
+		if !replay {
+			return nil
+		}
+
+		return db.View(func(tx *bolt.Tx) error {
+			feed := tx.Bucket([]byte(boltChangeFeedBucket))
+			if feed == nil {
+				return nil
+			}
+
+			// The oldest retained change has to follow from, otherwise changes in between were missed
+			oldest := uint64(1)
+			if current := feed.Get(boltChangeFeedSequenceKey); len(current) == 8 {
+				oldest = binary.BigEndian.Uint64(current) + 1
+			}
+			changeLog := feed.Bucket([]byte(boltChangeFeedLog))
+			if changeLog != nil {
+				if first, _ := changeLog.Cursor().First(); len(first) == 8 {
+					oldest = binary.BigEndian.Uint64(first)
+				}
+			}
+			if oldest > from+1 {
+				return ErrBoltChangeFeedTruncated
+			}
+
+			if changeLog == nil {
+				return nil
+			}
+
+			cursor := changeLog.Cursor()
+			for key, value := cursor.Seek(boltSequenceKey(from + 1)); key != nil; key, value = cursor.Next() {
+				event := BoltChangeEvent{}
+				err := json.Unmarshal(value, &event)
+				if err != nil {
+					return err
+				}
+
+				if watcher.matches(event) {
+					backlog = append(backlog, event)
+				}
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		if err != ErrBoltChangeFeedTruncated {
+			Log.Error("Error reading change feed", zap.Error(err))
+		}
+		watcher.Close()
+		return nil, err
+	}
+
+	go watcher.run(backlog, from)
+
+	return watcher, nil
+}
+
+func (w *BoltWatcher) run(backlog []BoltChangeEvent, lastSent uint64) {
+	defer close(w.events)
+
+	for _, event := range backlog {
+		select {
+		case w.events <- event:
+			lastSent = event.Sequence
+		case <-w.done:
+			return
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-w.live:
+			if !ok {
+				select {
+				case <-w.done:
+				default:
+					w.err = ErrBoltWatcherOverflow
+				}
+				return
+			}
+
+			// Already sent from the backlog
+			if event.Sequence <= lastSent {
+				continue
+			}
+
+			select {
+			case w.events <- event:
+				lastSent = event.Sequence
+			case <-w.done:
+				return
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Events ... Closed when the watcher is closed or falls behind. Check Err once it is closed
+func (w *BoltWatcher) Events() <-chan BoltChangeEvent {
+	return w.events
+}
+
+// Err ... ErrBoltWatcherOverflow if the watcher was closed because the consumer fell behind
+func (w *BoltWatcher) Err() error {
+	return w.err
+}
+
+func (w *BoltWatcher) Close() {
+	w.closeOnce.Do(func() {
+		// Closed first so that run does not report the closed live channel as an overflow
+		close(w.done)
+
+		boltWatchersLock.Lock()
+		if boltWatchers[w] {
+			delete(boltWatchers, w)
+			close(w.live)
+		}
+		boltWatchersLock.Unlock()
+	})
+}
@@ -0,0 +1,186 @@
+package platform
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useChangeFeed(t *testing.T) {
+	previous := internalConfig.Database.BoltDB.ChangeFeed
+	internalConfig.Database.BoltDB.ChangeFeed.Enabled = true
+	t.Cleanup(func() {
+		internalConfig.Database.BoltDB.ChangeFeed = previous
+	})
+}
+
+func receiveChangeEvent(t *testing.T, watcher *BoltWatcher) BoltChangeEvent {
+	select {
+	case event := <-watcher.Events():
+		return event
+	case <-time.After(time.Second):
+		t.Fatalf("No change event received")
+	}
+
+	return BoltChangeEvent{}
+}
+
+func TestWatchBucket(t *testing.T) {
+	useTempBoltDB(t)
+	useChangeFeed(t)
+
+	watcher, err := Database.BoltDb.Watch("orders", "a-")
+	if err != nil {
+		t.Fatalf("Error watching bucket: %v", err)
+	}
+	defer watcher.Close()
+
+	Database.BoltDb.SaveObject("orders", "b-1", testObject{Id: "b-1"})
+	Database.BoltDb.SaveObject("orders", "a-1", testObject{Id: "a-1"})
+	Database.BoltDb.RemoveObject("orders", "a-1")
+
+	event := receiveChangeEvent(t, watcher)
+	if event.Type != BoltChangePut || event.Id != "a-1" || event.Sequence != 2 {
+		t.Errorf("Unexpected put event: %+v", event)
+	}
+
+	event = receiveChangeEvent(t, watcher)
+	if event.Type != BoltChangeDelete || event.Id != "a-1" || event.Sequence != 3 {
+		t.Errorf("Unexpected delete event: %+v", event)
+	}
+
+	// Resume after the first event as if the consumer restarted
+	resumed, err := Database.BoltDb.WatchFrom("orders", "", 1)
+	if err != nil {
+		t.Fatalf("Error resuming watch: %v", err)
+	}
+	defer resumed.Close()
+
+	event = receiveChangeEvent(t, resumed)
+	if event.Sequence != 2 {
+		t.Errorf("Expected replay from sequence 2, got %+v", event)
+	}
+
+	sequence, err := Database.BoltDb.LastSequence()
+	if err != nil || sequence != 3 {
+		t.Errorf("Expected last sequence 3: %d %v", sequence, err)
+	}
+}
+
+func TestChangeFeedHandler(t *testing.T) {
+	useTempBoltDB(t)
+	useChangeFeed(t)
+
+	server := httptest.NewServer(ChangeFeedHandler("", "orders", ""))
+	defer server.Close()
+
+	Database.BoltDb.SaveObject("orders", "1", testObject{Id: "1"})
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	request.Header.Set("Last-Event-ID", "0")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Error calling change feed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Unexpected content type %s", response.Header.Get("Content-Type"))
+	}
+
+	reader := bufio.NewReader(response.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "id: 1" {
+		t.Errorf("Expected the first event id, got %q %v", line, err)
+	}
+}
+
+func TestWatchFromTruncated(t *testing.T) {
+	useTempBoltDB(t)
+	useChangeFeed(t)
+	internalConfig.Database.BoltDB.ChangeFeed.Retention = 2
+
+	for i := 1; i <= 4; i++ {
+		Database.BoltDb.SaveObject("orders", fmt.Sprint(i), testObject{Id: fmt.Sprint(i)})
+	}
+
+	_, err := Database.BoltDb.WatchFrom("orders", "", 1)
+	if err != ErrBoltChangeFeedTruncated {
+		t.Errorf("Expected ErrBoltChangeFeedTruncated when changes were removed, got %v", err)
+	}
+
+	watcher, err := Database.BoltDb.WatchFrom("orders", "", 2)
+	if err != nil {
+		t.Fatalf("Expected the retained changes to be replayed, got %v", err)
+	}
+	defer watcher.Close()
+
+	if event := receiveChangeEvent(t, watcher); event.Sequence != 3 {
+		t.Errorf("Expected replay from sequence 3, got %+v", event)
+	}
+
+	server := httptest.NewServer(ChangeFeedHandler("default", "orders", ""))
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"?from=1", nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Error calling change feed: %v", err)
+	}
+	defer response.Body.Close()
+
+	reader := bufio.NewReader(response.Body)
+	id, _ := reader.ReadString('\n')
+	event, _ := reader.ReadString('\n')
+	if strings.TrimSpace(id) != "id: 4" || strings.TrimSpace(event) != "event: reset" {
+		t.Errorf("Expected a reset event at the last sequence, got %q %q", id, event)
+	}
+}
+
+func TestChangeFeedHandlerOfNamedDatabase(t *testing.T) {
+	useTempBoltDB(t)
+
+	config := &boltDBConfig{Enabled: true, FileName: filepath.Join(t.TempDir(), "feed.db")}
+	config.ChangeFeed.Enabled = true
+	feed := &boltDbDatabase{name: "feed", config: config}
+	err := feed.Open()
+	if err != nil {
+		t.Fatalf("Unable to open named database: %v", err)
+	}
+
+	boltDatabasesLock.Lock()
+	boltDatabases["feed"] = feed
+	boltDatabasesLock.Unlock()
+	t.Cleanup(func() {
+		boltDatabasesLock.Lock()
+		delete(boltDatabases, "feed")
+		boltDatabasesLock.Unlock()
+		feed.Close()
+	})
+
+	server := httptest.NewServer(ChangeFeedHandler("feed", "orders", ""))
+	defer server.Close()
+
+	feed.SaveObject("orders", "1", testObject{Id: "1"})
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"?from=0", nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Error calling change feed: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the change feed of the named database but got %d", response.StatusCode)
+	}
+
+	line, err := bufio.NewReader(response.Body).ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "id: 1" {
+		t.Errorf("Expected the first event id, got %q %v", line, err)
+	}
+}
@@ -0,0 +1,172 @@
+package platform
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cacheDefaultMaxEntries = 1000
+)
+
+// Cache ... Bounded in-memory LRU cache with optional expiry. Safe for concurrent use.
+// Values are returned as stored so treat them as read only
+type Cache struct {
+	lock       sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	entries    map[string]*list.Element
+	order      *list.List
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// CacheStats ... Counters since the cache was created
+type CacheStats struct {
+	Entries   int     `json:"entries"`
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	Evictions uint64  `json:"evictions"`
+	HitRate   float64 `json:"hitRate"`
+}
+
+type cacheEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// NewCache ... Keeps at most maxEntries values, evicting the least recently used.
+// Entries expire after ttl. Use 0 for entries that only leave the cache when evicted
+func NewCache(maxEntries int, ttl time.Duration) *Cache {
+	if maxEntries < 1 {
+		maxEntries = cacheDefaultMaxEntries
+	}
+
+	return &Cache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get ... Returns the value and true if it is cached and has not expired
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.removeElement(element)
+		c.misses++
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	c.hits++
+
+	return entry.value, true
+}
+
+// Set ... Caches the value with the default ttl of the cache
+func (c *Cache) Set(key string, value interface{}) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL ... Caches the value until ttl has passed. 0 means no expiry
+func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value, expiresAt: expiresAt})
+
+	for c.order.Len() > c.maxEntries {
+		c.removeElement(c.order.Back())
+		c.evictions++
+	}
+}
+
+// Delete ... Removes the key from the cache
+func (c *Cache) Delete(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+// DeletePrefix ... Removes all keys starting with prefix
+func (c *Cache) DeletePrefix(prefix string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for key, element := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(element)
+		}
+	}
+}
+
+// Clear ... Removes all entries. The statistics are kept
+func (c *Cache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// Len ... Number of cached entries, including expired entries that have not been removed yet
+func (c *Cache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *Cache) Stats() CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stats := CacheStats{
+		Entries:   c.order.Len(),
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+	if c.hits+c.misses > 0 {
+		stats.HitRate = float64(c.hits) / float64(c.hits+c.misses)
+	}
+
+	return stats
+}
+
+func (c *Cache) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
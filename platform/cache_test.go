@@ -0,0 +1,164 @@
+package platform
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func useBoltCache(t *testing.T, maxEntries int) {
+	previous := internalConfig.Database.BoltDB.Cache
+
+	internalConfig.Database.BoltDB.Cache.Enabled = true
+	internalConfig.Database.BoltDB.Cache.MaxEntries = maxEntries
+	internalConfig.Database.BoltDB.Cache.TTLSeconds = 0
+	internalConfig.Database.BoltDB.Cache.Buckets = nil
+	Database.BoltDb.dropCaches()
+
+	t.Cleanup(func() {
+		internalConfig.Database.BoltDB.Cache = previous
+		Database.BoltDb.dropCaches()
+	})
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewCache(2, 0)
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("a")
+	cache.Set("c", 3)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if value, ok := cache.Get("a"); !ok || value != 1 {
+		t.Errorf("Expected a to be cached but got %v", value)
+	}
+
+	stats := cache.Stats()
+	if stats.Entries != 2 || stats.Evictions != 1 || stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if stats.HitRate < 0.66 || stats.HitRate > 0.67 {
+		t.Errorf("Unexpected hit rate %f", stats.HitRate)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	cache := NewCache(10, time.Hour)
+
+	cache.SetWithTTL("short", 1, time.Millisecond)
+	cache.Set("long", 2)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := cache.Get("short"); ok {
+		t.Error("Expected short to have expired")
+	}
+	if _, ok := cache.Get("long"); !ok {
+		t.Error("Expected long to be cached")
+	}
+
+	cache.Set("prefix/1", 1)
+	cache.Set("prefix/2", 2)
+	cache.DeletePrefix("prefix/")
+	if cache.Len() != 1 {
+		t.Errorf("Expected only long to remain but got %d entries", cache.Len())
+	}
+}
+
+func TestCacheConcurrentUse(t *testing.T) {
+	cache := NewCache(50, 0)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := fmt.Sprintf("%d", j%100)
+				if _, ok := cache.Get(key); !ok {
+					cache.Set(key, worker)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if cache.Len() > 50 {
+		t.Errorf("Cache grew beyond its limit: %d", cache.Len())
+	}
+}
+
+func TestBoltObjectCache(t *testing.T) {
+	db := useTempBoltDB(t)
+	useBoltCache(t, 10)
+
+	Database.BoltDb.SaveObject("people", "a", testObject{Id: "a", Name: "Anna"})
+
+	for i := 0; i < 3; i++ {
+		result := testObject{}
+		err := Database.BoltDb.ReadObject("people", "a", &result)
+		if err != nil || result.Name != "Anna" {
+			t.Fatalf("Unexpected result %+v, %v", result, err)
+		}
+	}
+
+	stats := Database.BoltDb.CacheStats()["people"]
+	if stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// Writes through the platform invalidate the entry
+	Database.BoltDb.SaveObject("people", "a", testObject{Id: "a", Name: "Changed"})
+	result := testObject{}
+	Database.BoltDb.ReadObject("people", "a", &result)
+	if result.Name != "Changed" {
+		t.Errorf("Expected the saved value but got %+v", result)
+	}
+
+	Database.BoltDb.RemoveObject("people", "a")
+	err := Database.BoltDb.ReadObject("people", "a", &result)
+	if err != ErrNoEntryFoundInDB {
+		t.Errorf("Expected ErrNoEntryFoundInDB after remove but got %v", err)
+	}
+
+	// Cached values are read without the database, so changes made directly to the file are not seen
+	Database.BoltDb.SaveObject("people", "b", testObject{Id: "b", Name: "Ben"})
+	Database.BoltDb.ReadObject("people", "b", &result)
+	db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("people")).Put([]byte("b"), []byte(`{"Id":"b","Name":"Direct"}`))
+	})
+	Database.BoltDb.ReadObject("people", "b", &result)
+	if result.Name != "Ben" {
+		t.Errorf("Expected the cached value but got %+v", result)
+	}
+
+	Database.BoltDb.ClearCache()
+	Database.BoltDb.ReadObject("people", "b", &result)
+	if result.Name != "Direct" {
+		t.Errorf("Expected the value from the file after ClearCache but got %+v", result)
+	}
+}
+
+func TestBoltObjectCacheRespectsExpiry(t *testing.T) {
+	useTempBoltDB(t)
+	useBoltCache(t, 10)
+
+	Database.BoltDb.SaveObjectWithTTL("sessions", "s", testObject{Id: "s"}, 20*time.Millisecond)
+
+	result := testObject{}
+	err := Database.BoltDb.ReadObject("sessions", "s", &result)
+	if err != nil {
+		t.Fatalf("Error reading object: %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	err = Database.BoltDb.ReadObject("sessions", "s", &result)
+	if err != ErrNoEntryFoundInDB {
+		t.Errorf("Expected the cached entry to expire with the database entry but got %v", err)
+	}
+}
@@ -111,25 +111,103 @@ type Config struct {
 
 	HTTP struct {
 		Server struct {
-			ListeningAddress             string
-			TLSCertFileName              string
-			TLSKeyFileName               string
-			TLSEnabled                   bool
+			ListeningAddress string
+			TLSCertFileName  string
+			TLSKeyFileName   string
+			TLSEnabled       bool
+			// 1.2 (default) or 1.3
+			TLSMinVersion string
+			// Go names of the TLS 1.2 cipher suites, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Defaults to the Go defaults
+			TLSCipherSuites []string
+			// Plain HTTP listener that redirects to the TLS listener
+			HTTPSRedirect httpsRedirectConfig
+			// HSTS, Content-Security-Policy and the other security headers on every response
+			SecurityHeaders securityHeadersConfig
+			// Deprecated: Use Cors. Allows any origin, method and header when Cors is not enabled
 			AllowCorsForLocalDevelopment bool
+
+			// Default policy of routes that do not set Route.Cors
+			Cors corsConfig
+
+			// Seconds. The defaults are used when not set
+			ReadTimeoutSeconds       int
+			ReadHeaderTimeoutSeconds int
+			WriteTimeoutSeconds      int
+			IdleTimeoutSeconds       int
+			// Seconds running requests get to complete after SIGINT or SIGTERM
+			ShutdownGracePeriodSeconds int
+
+			// Default limit of routes that do not set Route.RateLimit
+			RateLimit struct {
+				Enabled bool
+				// Requests per second and the number of requests allowed at once
+				Rate  float64
+				Burst int
+				// ip, user or apikey, see RateLimit.Key
+				Key string
+				// Number of clients tracked per route. Defaults to 10000
+				MaxKeys int
+				// Limit by the first X-Forwarded-For address. Only enable behind a proxy that sets it
+				TrustForwardedFor bool
+			}
 		}
 
 		Clients []httpClientConfig
 	}
 
+	// Built-in liveness and readiness routes and the gRPC health service
+	Health struct {
+		Enabled bool
+		// Default to /healthz and /readyz
+		LivenessPath  string
+		ReadinessPath string
+		// Seconds each check may take before it is reported down. Defaults to 5
+		TimeoutSeconds int
+	}
+
+	// Prometheus metrics route
+	Metrics struct {
+		Enabled bool
+		// Defaults to /metrics
+		Path string
+	}
+
+	// OpenTelemetry traces exported over OTLP/HTTP
+	Tracing struct {
+		Enabled bool
+		// host:port of the collector. Uses OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318 when empty
+		Endpoint string
+		// Plain HTTP instead of HTTPS
+		Insecure bool
+		// Share of new traces that are recorded, between 0 and 1. Defaults to 1.
+		// Traces started by a caller follow the decision of the caller
+		SampleRatio float64
+		// Defaults to the component name
+		ServiceName string
+	}
+
 	Grpc struct {
 		Server struct {
 			ListeningAddress string
 			TLSCertFileName  string
 			TLSKeyFileName   string
 			TLSEnabled       bool
+			// 1.2 (default) or 1.3
+			TLSMinVersion string
+			// Go names of the TLS 1.2 cipher suites, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Defaults to the Go defaults
+			TLSCipherSuites []string
 
 			// For things like login paths that wonth have security
 			UnAuthenticatedPaths []string
+
+			// Seconds running calls get to complete after SIGINT or SIGTERM
+			ShutdownGracePeriodSeconds int
+
+			// Limit per method. Off when Rate is 0
+			RateLimit struct {
+				Rate  float64
+				Burst int
+			}
 		}
 	}
 
@@ -166,9 +244,32 @@ type Config struct {
 	}
 
 	Database struct {
-		BoltDB struct {
-			Enabled  bool
-			FileName string
+		// Backend behind Database.Store: boltdb (default), bbolt or memory.
+		// bbolt uses the BoltDB settings and file
+		Backend string
+
+		BoltDB boltDBConfig
+		// More BoltDB files opened and closed with the default one. Access them with Database.Bolt(name).
+		// Names are lower case
+		BoltDatabases map[string]boltDBConfig
+
+		SQL struct {
+			Enabled bool
+			// Name of a database/sql driver imported by the service, e.g. postgres, mysql or sqlite
+			Driver string
+			DSN    string
+			// Read the DSN from Vault instead of the config file
+			VaultPath   string
+			VaultDSNKey string
+
+			MaxOpenConnections           int
+			MaxIdleConnections           int
+			ConnectionMaxLifetimeSeconds int
+			ConnectionMaxIdleTimeSeconds int
+			HealthCheckIntervalSeconds   int
+			// Queries slower than this are logged as warnings. 0 disables
+			SlowQueryMs     int64
+			MigrationsTable string
 		}
 	}
 
@@ -193,6 +294,80 @@ type Config struct {
 	}
 }
 
+// BoltDBConfig ... Settings of a BoltDB file
+type boltDBConfig struct {
+	Enabled  bool
+	FileName string
+	// Octal permissions used when the file is created. Defaults to 0600
+	FileMode string
+	// Seconds to wait for the file lock held by another process. Defaults to 5
+	OpenTimeoutSeconds int
+	// Opens with a shared lock so that several processes can read the file. Writes fail
+	ReadOnly bool
+	// Skip fsync after each commit and when the file grows. Faster but a crash can lose or corrupt data
+	NoSync     bool
+	NoGrowSync bool
+
+	Expiry struct {
+		ReaperEnabled         bool
+		ReaperIntervalSeconds int
+		// Maximum number of entries removed per transaction
+		ReaperBatchSize int
+	}
+
+	// Records committed changes for Watch and the change feed route
+	ChangeFeed struct {
+		Enabled bool
+		// Number of change events kept for resuming watchers
+		Retention int
+	}
+
+	// Read-through cache of decoded objects, invalidated by writes. Only the listed buckets
+	// are cached when Buckets is set, otherwise every bucket uses MaxEntries and TTLSeconds
+	Cache struct {
+		Enabled    bool
+		MaxEntries int
+		// 0 keeps entries until they are evicted or invalidated
+		TTLSeconds int
+		Buckets    []struct {
+			Bucket     string
+			MaxEntries int
+			TTLSeconds int
+		}
+	}
+
+	// Codec of new values per bucket: json (default), gob, msgpack, protobuf or a registered codec.
+	// Compress uses zstd
+	Codecs []struct {
+		Bucket   string
+		Codec    string
+		Compress bool
+	}
+
+	// AES-GCM encryption of the values in the listed buckets
+	Encryption struct {
+		Enabled bool
+		Buckets []string
+		// Id of the key used for new values. Ignored when TransitKey is set
+		ActiveKeyId string
+		// Base64 encoded 32 byte keys. Old keys must be kept until all values are rotated
+		Keys []struct {
+			Id  string
+			Key string
+		}
+		// Vault secret with more keys. Each entry is a key id with a base64 encoded key
+		VaultPath string
+		// Vault Transit key that wraps the data keys. TransitMount defaults to transit
+		TransitMount string
+		TransitKey   string
+
+		// Re-encrypts values written with an older key in the background
+		ReencryptEnabled         bool
+		ReencryptIntervalSeconds int
+		ReencryptBatchSize       int
+	}
+}
+
 // HTTPClientConfig ... For HTTP client configuration
 type httpClientConfig struct {
 	ID                 string
@@ -255,5 +430,7 @@ func createDefaultConfiguration() {
 	internalConfig.HTTP.Server.ListeningAddress = "127.0.0.1:10000"
 	internalConfig.Log.FileLoggingEnabled = false
 	internalConfig.Database.BoltDB.Enabled = false
+	internalConfig.Health.Enabled = true
+	internalConfig.Metrics.Enabled = true
 	internalConfig.Component.ComponentName = "Not Specified"
 }
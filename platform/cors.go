@@ -0,0 +1,214 @@
+package platform
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	corsDefaultAllowedHeaders = []string{"Accept", "Authorization", "Content-Type"}
+
+	// Used while the deprecated AllowCorsForLocalDevelopment setting is on
+	corsLocalDevelopmentPolicy = &CorsPolicy{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"*"},
+		AllowedHeaders: []string{"*"},
+	}
+
+	errCorsPreflightRejected = NewError(http.StatusForbidden, ErrorCodeForbidden, "Origin, method or headers not allowed by the CORS policy")
+
+	ErrCorsWildcardCredentials = errors.New("CORS policy cannot allow credentials for any origin")
+)
+
+// CorsPolicy ... Cross origin requests that browsers may make
+type CorsPolicy struct {
+	// Exact origins such as https://app.example.com, patterns with one * such as https://*.example.com, or * for any
+	AllowedOrigins []string
+	// Defaults to the method of the route. * allows any
+	AllowedMethods []string
+	// Defaults to Accept, Authorization and Content-Type. * allows any
+	AllowedHeaders []string
+	// Response headers scripts may read
+	ExposedHeaders []string
+	// Allow cookies and Authorization. The request origin is returned instead of *. Not allowed with * in AllowedOrigins
+	AllowCredentials bool
+	// Seconds browsers may cache a preflight response. Not sent when 0
+	MaxAgeSeconds int
+}
+
+type corsConfig struct {
+	Enabled    bool
+	CorsPolicy `mapstructure:",squash"`
+}
+
+// Configured policy, nil when CORS is off
+func defaultCorsPolicy(config *Config) *CorsPolicy {
+	if config.HTTP.Server.Cors.Enabled {
+		policy := config.HTTP.Server.Cors.CorsPolicy
+		return &policy
+	}
+
+	if config.HTTP.Server.AllowCorsForLocalDevelopment {
+		return corsLocalDevelopmentPolicy
+	}
+
+	return nil
+}
+
+// Policy of the route, or the configured policy when the route has none
+func routeCorsPolicy(route Route, config *Config) *CorsPolicy {
+	if route.Cors != nil {
+		return route.Cors
+	}
+
+	return defaultCorsPolicy(config)
+}
+
+// Credentials for any origin would let every site make authenticated calls
+func (p *CorsPolicy) validate() error {
+	if p.AllowCredentials && corsContains(p.AllowedOrigins, "*") {
+		return ErrCorsWildcardCredentials
+	}
+
+	return nil
+}
+
+func corsContains(values []string, value string) bool {
+	for _, v := range values {
+		if v == "*" || strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *CorsPolicy) originAllowed(origin string) bool {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+
+		star := strings.Index(allowed, "*")
+		if star < 0 {
+			continue
+		}
+
+		prefix, suffix := strings.ToLower(allowed[:star]), strings.ToLower(allowed[star+1:])
+		lowerOrigin := strings.ToLower(origin)
+		if len(lowerOrigin) > len(prefix)+len(suffix) && strings.HasPrefix(lowerOrigin, prefix) && strings.HasSuffix(lowerOrigin, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A policy for any origin answers with * and never allows credentials
+func (p *CorsPolicy) writeOrigin(header http.Header, origin string) {
+	if corsContains(p.AllowedOrigins, "*") {
+		header.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+
+	header.Set("Access-Control-Allow-Origin", origin)
+	if p.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Answers a preflight for a request with method to a resource served with routeMethod
+func (p *CorsPolicy) writePreflight(w http.ResponseWriter, r *http.Request, routeMethod string) bool {
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get("Access-Control-Request-Method")
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	if !p.originAllowed(origin) {
+		return false
+	}
+
+	methods := p.AllowedMethods
+	if len(methods) < 1 {
+		methods = []string{routeMethod}
+	}
+	if !corsContains(methods, method) {
+		return false
+	}
+
+	headers := p.AllowedHeaders
+	if len(headers) < 1 {
+		headers = corsDefaultAllowedHeaders
+	}
+
+	requestedHeaders := make([]string, 0)
+	for _, v := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		v = strings.TrimSpace(v)
+		if len(v) < 1 {
+			continue
+		}
+		if !corsContains(headers, v) {
+			return false
+		}
+		requestedHeaders = append(requestedHeaders, v)
+	}
+
+	p.writeOrigin(header, origin)
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	header.Set("Access-Control-Allow-Methods", method)
+	if len(requestedHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(requestedHeaders, ", "))
+	}
+	if p.MaxAgeSeconds > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(p.MaxAgeSeconds))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return true
+}
+
+func isCorsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && len(r.Header.Get("Origin")) > 0 && len(r.Header.Get("Access-Control-Request-Method")) > 0
+}
+
+// Adds the CORS headers to responses of requests from allowed origins. Every response to a request with
+// an Origin varies by it, so that caches do not serve one origin the response of another
+func corsMiddleware(next http.Handler, policy *CorsPolicy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if len(origin) > 0 {
+			w.Header().Add("Vary", "Origin")
+		}
+		if len(origin) > 0 && policy.originAllowed(origin) {
+			policy.writeOrigin(w.Header(), origin)
+			if len(policy.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Answers preflights with the policy of the route the browser is about to call. policies holds the policy of each route
+func corsPreflightHandler(router *mux.Router, policies map[*mux.Route]*CorsPolicy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actual := r.Clone(r.Context())
+		actual.Method = r.Header.Get("Access-Control-Request-Method")
+
+		match := mux.RouteMatch{}
+		if router.Match(actual, &match) && match.Route != nil {
+			policy := policies[match.Route]
+			if policy != nil && policy.writePreflight(w, r, actual.Method) {
+				return
+			}
+		}
+
+		JsonMarshaller.WriteError(w, errCorsPreflightRejected)
+	})
+}
@@ -0,0 +1,149 @@
+package platform
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCorsOriginAllowed(t *testing.T) {
+	policy := &CorsPolicy{AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"}}
+
+	allowed := map[string]bool{
+		"https://app.example.com":  true,
+		"HTTPS://APP.EXAMPLE.COM":  true,
+		"https://a.example.org":    true,
+		"https://a.b.example.org":  true,
+		"https://example.org":      false,
+		"https://.example.org":     false,
+		"http://app.example.com":   false,
+		"https://evil.example.com": false,
+		"https://a.example.org.io": false,
+	}
+
+	for origin, expected := range allowed {
+		if policy.originAllowed(origin) != expected {
+			t.Errorf("Expected %s allowed to be %v", origin, expected)
+		}
+	}
+}
+
+func TestCorsRoutes(t *testing.T) {
+	routes := Routes{
+		Route{
+			Path:        "/items",
+			Method:      http.MethodPost,
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {},
+			Cors: &CorsPolicy{
+				AllowedOrigins:   []string{"https://*.example.com"},
+				ExposedHeaders:   []string{"X-Request-Id"},
+				AllowCredentials: true,
+				MaxAgeSeconds:    600,
+			},
+		},
+		Route{
+			Path:        "/open",
+			Method:      http.MethodGet,
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {},
+		},
+	}
+
+	router, err := newRouter(routes)
+	if err != nil {
+		t.Fatalf("Error creating router: %v", err)
+	}
+
+	preflight := func(path string, origin string, method string, headers string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodOptions, path, nil)
+		request.Header.Set("Origin", origin)
+		request.Header.Set("Access-Control-Request-Method", method)
+		request.Header.Set("Access-Control-Request-Headers", headers)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	response := preflight("/items", "https://app.example.com", http.MethodPost, "content-type")
+	if response.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 but got %d", response.Code)
+	}
+	if response.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		response.Header().Get("Access-Control-Allow-Credentials") != "true" ||
+		response.Header().Get("Access-Control-Allow-Methods") != http.MethodPost ||
+		response.Header().Get("Access-Control-Allow-Headers") != "content-type" ||
+		response.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("Unexpected preflight headers %v", response.Header())
+	}
+
+	if preflight("/items", "https://app.example.net", http.MethodPost, "").Code != http.StatusForbidden {
+		t.Error("Expected an origin that does not match to be rejected")
+	}
+
+	if preflight("/items", "https://app.example.com", http.MethodDelete, "").Code != http.StatusForbidden {
+		t.Error("Expected a method the route does not serve to be rejected")
+	}
+
+	if preflight("/items", "https://app.example.com", http.MethodPost, "X-Custom").Code != http.StatusForbidden {
+		t.Error("Expected a header that is not allowed to be rejected")
+	}
+
+	// The test config enables AllowCorsForLocalDevelopment, which is used when a route has no policy
+	response = preflight("/open", "http://localhost:3000", http.MethodGet, "X-Custom")
+	if response.Code != http.StatusNoContent || response.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("Expected the permissive default policy but got %d %v", response.Code, response.Header())
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/items", nil)
+	request.Header.Set("Origin", "https://app.example.com")
+	actual := httptest.NewRecorder()
+	router.ServeHTTP(actual, request)
+	if actual.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		actual.Header().Get("Access-Control-Expose-Headers") != "X-Request-Id" ||
+		actual.Header().Get("Vary") != "Origin" {
+		t.Errorf("Unexpected headers %v", actual.Header())
+	}
+}
+
+func TestCorsWildcardCredentials(t *testing.T) {
+	routes := Routes{
+		Route{
+			Path:        "/items",
+			Method:      http.MethodGet,
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {},
+			Cors:        &CorsPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		},
+	}
+
+	_, err := newRouter(routes)
+	if !errors.Is(err, ErrCorsWildcardCredentials) {
+		t.Errorf("Expected ErrCorsWildcardCredentials but got %v", err)
+	}
+
+	// Even without validation a wildcard match does not allow credentials
+	header := http.Header{}
+	(&CorsPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true}).writeOrigin(header, "https://evil.example.com")
+	if header.Get("Access-Control-Allow-Origin") != "*" || len(header.Get("Access-Control-Allow-Credentials")) > 0 {
+		t.Errorf("Unexpected headers %v", header)
+	}
+}
+
+func TestCorsVaryForRejectedOrigin(t *testing.T) {
+	policy := &CorsPolicy{AllowedOrigins: []string{"https://app.example.com"}}
+	handler := corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), policy)
+
+	request := httptest.NewRequest(http.MethodGet, "/items", nil)
+	request.Header.Set("Origin", "https://other.example.com")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Header().Get("Vary") != "Origin" || len(response.Header().Get("Access-Control-Allow-Origin")) > 0 {
+		t.Errorf("Expected only Vary: Origin for an origin that is not allowed but got %v", response.Header())
+	}
+
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/items", nil))
+	if len(response.Header().Get("Vary")) > 0 {
+		t.Errorf("Expected no Vary without an Origin but got %v", response.Header())
+	}
+}
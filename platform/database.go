@@ -2,26 +2,101 @@ package platform
 
 import (
 	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DatabaseBackendBoltDB = "boltdb"
+	DatabaseBackendBbolt  = "bbolt"
+	DatabaseBackendMemory = "memory"
 )
 
 var (
-	Database              PlatformDatabases
-	ErrNoEntryFoundInDB   = errors.New("No entry found in the database")
-	ErrBoltDbIsNotEnabled = errors.New("Bolt DB is not enabled")
+	Database                     PlatformDatabases
+	ErrNoEntryFoundInDB          = errors.New("No entry found in the database")
+	ErrBoltDbIsNotEnabled        = errors.New("Bolt DB is not enabled")
+	ErrDatabaseUnknownBackend    = errors.New("unknown database backend")
+	ErrDatabaseReadOnlyTx        = errors.New("write attempted in a read only transaction")
+	ErrDatabaseStoreNotAvailable = errors.New("no database store configured")
 )
 
 func init() {
-	Database := PlatformDatabases{}
-	Database.BoltDb = boltDbDatabase{}
-	// err := Database.BoltDb.createBoltDatabase()
-	// Database.BoltDb.createBoltDatabase()
-	// if err == ErrBoltDbIsNotEnabled {
-	// 	// we do nothing
-	// } else {
-	// 	Logger.Fatal("Error creating BoltDB database", zap.)
-	// }
+	InitializeLogger()
+
+	config, err := GetPlatformConfiguration()
+	if err != nil {
+		Log.Error("Unable to read platform configuration", zap.Error(err))
+		panic(errors.New("unable to get configuration"))
+	}
+
+	err = initializeDatabaseStore(config)
+	if err != nil {
+		Log.Error("Unable to create database store", zap.String("backend", config.Database.Backend), zap.Error(err))
+		panic(err)
+	}
+}
+
+func initializeDatabaseStore(config *Config) error {
+	switch config.Database.Backend {
+	case "", DatabaseBackendBoltDB:
+		// Opened in the boltdb init
+		if config.Database.BoltDB.Enabled {
+			Database.Store = &Database.BoltDb
+		}
+	case DatabaseBackendBbolt:
+		if config.Database.BoltDB.Enabled {
+			store, err := openBboltStore(&config.Database.BoltDB)
+			if err != nil {
+				return err
+			}
+			Database.Store = store
+		}
+	case DatabaseBackendMemory:
+		Database.Store = NewMemoryStore()
+	default:
+		return ErrDatabaseUnknownBackend
+	}
+
+	Log.Debug("Database store selected", zap.String("backend", config.Database.Backend))
+
+	return nil
 }
 
 type PlatformDatabases struct {
 	BoltDb boltDbDatabase
+
+	// Backend selected with platform.database.backend. Prefer this over BoltDb in handlers
+	// so that the backend can be switched, e.g. to the memory store in tests
+	Store KeyValueStore
+}
+
+// KeyValueStore ... Object storage implemented by the database backends.
+// Objects are stored as JSON, or with the codec of the bucket on BoltDb, in buckets named by a path such as tenant/a/orders
+type KeyValueStore interface {
+	SaveObject(bucket string, id string, object interface{}) error
+	SaveObjectWithTTL(bucket string, id string, object interface{}, ttl time.Duration) error
+	ReadObject(bucket string, id string, object interface{}) error
+	// Values are in the format they were stored in, JSON unless the bucket has another codec
+	ReadAllObjects(bucket string) (map[string]string, error)
+	RemoveObject(bucket string, id string) error
+	RemoveBucket(bucket string) error
+
+	// Calls fn for each unexpired entry in key order. Returning an error stops the iteration
+	ForEach(bucket string, fn func(id string, value []byte) error) error
+	// Read only transaction
+	View(fn func(tx KeyValueTx) error) error
+	// Read write transaction. Changes are discarded if fn returns an error
+	Update(fn func(tx KeyValueTx) error) error
+
+	Close() error
+}
+
+// KeyValueTx ... Operations available inside a KeyValueStore transaction
+type KeyValueTx interface {
+	SaveObject(bucket string, id string, object interface{}) error
+	ReadObject(bucket string, id string, object interface{}) error
+	RemoveObject(bucket string, id string) error
+	ForEach(bucket string, fn func(id string, value []byte) error) error
 }
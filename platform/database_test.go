@@ -0,0 +1,230 @@
+package platform
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func testKeyValueStore(t *testing.T, store KeyValueStore) {
+	err := store.SaveObject("people", "2", testObject{Id: "2", Name: "Jane"})
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+
+	err = store.SaveObject("people", "1", testObject{Id: "1", Name: "John"})
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+
+	result := testObject{}
+	err = store.ReadObject("people", "1", &result)
+	if err != nil || result.Name != "John" {
+		t.Fatalf("Error reading object: %v %v", result, err)
+	}
+
+	err = store.ReadObject("people", "3", &result)
+	if err != ErrNoEntryFoundInDB {
+		t.Errorf("Expected ErrNoEntryFoundInDB, got %v", err)
+	}
+
+	ids := make([]string, 0)
+	err = store.ForEach("people", func(id string, value []byte) error {
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil || len(ids) != 2 || ids[0] != "1" {
+		t.Errorf("Expected ordered iteration: %v %v", ids, err)
+	}
+
+	// A failed transaction must not change anything
+	errRollback := errors.New("rollback")
+	err = store.Update(func(tx KeyValueTx) error {
+		err := tx.RemoveObject("people", "1")
+		if err != nil {
+			return err
+		}
+
+		return errRollback
+	})
+	if err != errRollback {
+		t.Errorf("Expected the transaction error, got %v", err)
+	}
+
+	err = store.ReadObject("people", "1", &result)
+	if err != nil {
+		t.Errorf("Rolled back remove should not be visible: %v", err)
+	}
+
+	err = store.View(func(tx KeyValueTx) error {
+		return tx.SaveObject("people", "4", testObject{Id: "4"})
+	})
+	if err != ErrDatabaseReadOnlyTx {
+		t.Errorf("Expected ErrDatabaseReadOnlyTx, got %v", err)
+	}
+
+	err = store.SaveObjectWithTTL("people", "temp", testObject{Id: "temp"}, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Error saving object with TTL: %v", err)
+	}
+	time.Sleep(time.Millisecond * 5)
+
+	all, err := store.ReadAllObjects("people")
+	if err != nil || len(all) != 2 {
+		t.Errorf("Expected 2 unexpired objects: %v %v", all, err)
+	}
+
+	err = store.RemoveObject("people", "2")
+	if err != nil {
+		t.Fatalf("Error removing object: %v", err)
+	}
+
+	err = store.RemoveBucket("people")
+	if err != nil {
+		t.Fatalf("Error removing bucket: %v", err)
+	}
+
+	_, err = store.ReadAllObjects("people")
+	if err != ErrNoEntryFoundInDB {
+		t.Errorf("Expected ErrNoEntryFoundInDB for removed bucket, got %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testKeyValueStore(t, NewMemoryStore())
+}
+
+func TestBboltStore(t *testing.T) {
+	store, err := OpenBboltStore(filepath.Join(t.TempDir(), "bbolt.db"))
+	if err != nil {
+		t.Fatalf("Error opening bbolt store: %v", err)
+	}
+	defer store.Close()
+
+	testKeyValueStore(t, store)
+}
+
+func TestBoltDbStore(t *testing.T) {
+	useTempBoltDB(t)
+
+	testKeyValueStore(t, &Database.BoltDb)
+}
+
+func TestShutdownClosesStore(t *testing.T) {
+	useTempBoltDB(t)
+
+	previous := Database.Store
+	t.Cleanup(func() {
+		Database.Store = previous
+	})
+
+	fileName := filepath.Join(t.TempDir(), "bbolt.db")
+	store, err := OpenBboltStore(fileName)
+	if err != nil {
+		t.Fatalf("Error opening bbolt store: %v", err)
+	}
+	Database.Store = store
+
+	shutdownPlatform()
+
+	// Times out while the file lock of the first handle is still held
+	reopened, err := OpenBboltStore(fileName)
+	if err != nil {
+		t.Fatalf("Expected the store to be opened again after shutdown: %v", err)
+	}
+	reopened.Close()
+}
+
+func TestBboltStoreReadsBoltDbFile(t *testing.T) {
+	db := useTempBoltDB(t)
+	useBucketCodec(t, "tenant/packed", "msgpack", true)
+
+	Database.BoltDb.SaveObject("tenant/a/orders", "1", testObject{Id: "1", Name: "John"})
+	Database.BoltDb.SaveObject("tenant/packed", "1", testObject{Id: "1", Name: "Jane"})
+	fileName := db.Path()
+	db.Close()
+
+	store, err := OpenBboltStore(fileName)
+	if err != nil {
+		t.Fatalf("Error opening bbolt store: %v", err)
+	}
+
+	for _, bucket := range []string{"tenant/a/orders", "/tenant/packed/"} {
+		result := testObject{}
+		err = store.ReadObject(bucket, "1", &result)
+		if err != nil || result.Id != "1" {
+			t.Errorf("Expected the value of %s written by BoltDb but got %+v, %v", bucket, result, err)
+		}
+	}
+
+	err = store.SaveObject("tenant/a/orders", "1", testObject{Id: "1", Name: "Johnny"})
+	if err != nil {
+		t.Fatalf("Error saving object: %v", err)
+	}
+	store.Close()
+
+	reopened, err := bolt.Open(fileName, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Unable to reopen BoltDB: %v", err)
+	}
+	Database.BoltDb.db = reopened
+	t.Cleanup(func() {
+		reopened.Close()
+	})
+
+	result := testObject{}
+	revision, err := Database.BoltDb.ReadObjectWithRevision("tenant/a/orders", "1", &result)
+	if err != nil || revision != 2 || result.Name != "Johnny" {
+		t.Errorf("Expected revision 2 of the value written by bbolt but got %d %+v, %v", revision, result, err)
+	}
+
+	err = Database.BoltDb.CreateIndex("tenant/a/orders", "Name")
+	if err != nil {
+		t.Fatalf("Error creating index: %v", err)
+	}
+	reopened.Close()
+
+	_, err = OpenBboltStore(fileName)
+	if !errors.Is(err, ErrBboltFeatureUnsupported) {
+		t.Errorf("Expected a file with indexes to be refused but got %v", err)
+	}
+
+	settings := &boltDBConfig{FileName: filepath.Join(t.TempDir(), "bbolt.db")}
+	settings.Encryption.Enabled = true
+	_, err = openBboltStore(settings)
+	if !errors.Is(err, ErrBboltFeatureUnsupported) {
+		t.Errorf("Expected encryption to be refused but got %v", err)
+	}
+}
+
+func TestBboltStoreOpenOptions(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "bbolt.db")
+	store, err := openBboltStore(&boltDBConfig{FileName: fileName, FileMode: "0640", NoSync: true})
+	if err != nil {
+		t.Fatalf("Error opening bbolt store: %v", err)
+	}
+	if !store.(*bboltDatabase).db.NoSync {
+		t.Error("Expected NoSync to be applied")
+	}
+	store.SaveObject("people", "1", testObject{Id: "1"})
+	store.Close()
+
+	info, err := os.Stat(fileName)
+	if err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640 but got %v, %v", info, err)
+	}
+
+	store, err = openBboltStore(&boltDBConfig{FileName: fileName, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("Error opening bbolt store read only: %v", err)
+	}
+	defer store.Close()
+
+	if store.SaveObject("people", "2", testObject{Id: "2"}) == nil {
+		t.Error("Expected writes to fail on a read only store")
+	}
+}
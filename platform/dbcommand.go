@@ -0,0 +1,328 @@
+package platform
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	ErrDatabaseCommandUnknown = errors.New("unknown database command")
+	ErrDatabaseCommandNoFile  = errors.New("no database file specified")
+)
+
+// RunDatabaseCommand ... Runs a database maintenance command against a BoltDB file.
+// Services can call this from main so that their registered migrations are available, e.g.
+//
+//	if len(os.Args) > 1 && os.Args[1] == "db" {
+//		err := platform.RunDatabaseCommand(os.Args[2:], os.Stdout)
+//		...
+//	}
+func RunDatabaseCommand(args []string, out io.Writer) error {
+	if len(args) < 1 {
+		printDatabaseCommandUsage(out)
+		return ErrDatabaseCommandUnknown
+	}
+
+	switch args[0] {
+	case "migrate":
+		return runMigrateCommand(args[1:], out)
+	case "encryption":
+		return runEncryptionCommand(args[1:], out)
+	case "export":
+		return runExportCommand(args[1:], out)
+	case "import":
+		return runImportCommand(args[1:], out)
+	case "stats":
+		return runStatsCommand(args[1:], out)
+	case "compact":
+		return runCompactCommand(args[1:], out)
+	default:
+		printDatabaseCommandUsage(out)
+		return ErrDatabaseCommandUnknown
+	}
+}
+
+func printDatabaseCommandUsage(out io.Writer) {
+	fmt.Fprintln(out, "Usage:")
+	fmt.Fprintln(out, "  migrate list  -file <db file> [-database <name>]")
+	fmt.Fprintln(out, "  migrate apply -file <db file> [-database <name>] [-dry-run]")
+	fmt.Fprintln(out, "  encryption rotate    -file <db file>")
+	fmt.Fprintln(out, "  encryption reencrypt -file <db file> [-batch-size <n>]")
+	fmt.Fprintln(out, "  export -file <db file> [-buckets <a,b>] [-out <jsonl file>]")
+	fmt.Fprintln(out, "  import -file <db file> -in <jsonl file> [-mode upsert|skip] [-batch-size <n>]")
+	fmt.Fprintln(out, "  stats   -file <db file>")
+	fmt.Fprintln(out, "  compact -file <db file> [-out <db file>]")
+}
+
+func openBoltFile(fileName string, readOnly bool) (*bolt.DB, error) {
+	if len(fileName) < 1 {
+		return nil, ErrDatabaseCommandNoFile
+	}
+
+	if _, err := os.Stat(fileName); err != nil {
+		return nil, err
+	}
+
+	return openBoltDatabase(fileName, "", boltDefaultOpenTimeoutSeconds, readOnly)
+}
+
+func runMigrateCommand(args []string, out io.Writer) error {
+	if len(args) < 1 {
+		printDatabaseCommandUsage(out)
+		return ErrDatabaseCommandUnknown
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	flags.SetOutput(out)
+	fileName := flags.String("file", "", "BoltDB file")
+	dryRun := flags.Bool("dry-run", false, "Run the migrations and roll them back")
+	database := flags.String("database", "", "Name the migrations were registered for. Empty is the default database")
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		db, err := openBoltFile(*fileName, true)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		statuses, err := listBoltMigrations(db, *database)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range statuses {
+			if v.Applied {
+				fmt.Fprintf(out, "%6d  applied  %s  %s\n", v.Version, v.AppliedAt.Format(time.RFC3339), v.Description)
+			} else {
+				fmt.Fprintf(out, "%6d  pending  %s\n", v.Version, v.Description)
+			}
+		}
+	case "apply":
+		db, err := openBoltFile(*fileName, false)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		applied, err := runBoltMigrations(db, *database, *dryRun)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range applied {
+			if *dryRun {
+				fmt.Fprintf(out, "%6d  would apply  %s\n", v.Version, v.Description)
+			} else {
+				fmt.Fprintf(out, "%6d  applied  %s\n", v.Version, v.Description)
+			}
+		}
+
+		if len(applied) < 1 {
+			fmt.Fprintln(out, "No pending migrations")
+		}
+	default:
+		printDatabaseCommandUsage(out)
+		return ErrDatabaseCommandUnknown
+	}
+
+	return nil
+}
+
+func runEncryptionCommand(args []string, out io.Writer) error {
+	if len(args) < 1 {
+		printDatabaseCommandUsage(out)
+		return ErrDatabaseCommandUnknown
+	}
+
+	flags := flag.NewFlagSet("encryption "+args[0], flag.ContinueOnError)
+	flags.SetOutput(out)
+	fileName := flags.String("file", "", "BoltDB file")
+	batchSize := flags.Int("batch-size", boltReencryptDefaultBatchSize, "Values rewritten per transaction")
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "rotate":
+		db, err := openBoltFile(*fileName, false)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		keyId, count, err := rotateBoltEncryptionKey(db)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "Rotated %d values to key %s\n", count, keyId)
+	case "reencrypt":
+		db, err := openBoltFile(*fileName, false)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		count, err := reencryptBoltValues(db, *batchSize)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "Re-encrypted %d values\n", count)
+	default:
+		printDatabaseCommandUsage(out)
+		return ErrDatabaseCommandUnknown
+	}
+
+	return nil
+}
+
+func runExportCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(out)
+	fileName := flags.String("file", "", "BoltDB file")
+	buckets := flags.String("buckets", "", "Comma separated buckets. All buckets if empty")
+	outFile := flags.String("out", "", "JSON Lines file. Written to stdout if empty")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	db, err := openBoltFile(*fileName, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	selected := make([]string, 0)
+	for _, v := range strings.Split(*buckets, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			selected = append(selected, v)
+		}
+	}
+
+	if len(*outFile) < 1 {
+		_, err = exportBoltBuckets(db, out, selected)
+		return err
+	}
+
+	file, err := os.Create(*outFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	count, err := exportBoltBuckets(db, file, selected)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Exported %d records\n", count)
+
+	return file.Close()
+}
+
+func runImportCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(out)
+	fileName := flags.String("file", "", "BoltDB file")
+	inFile := flags.String("in", "", "JSON Lines file written by export")
+	mode := flags.String("mode", BoltImportUpsert, "upsert or skip existing keys")
+	batchSize := flags.Int("batch-size", boltImportDefaultBatchSize, "Records written per transaction")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if len(*fileName) < 1 || len(*inFile) < 1 {
+		return ErrDatabaseCommandNoFile
+	}
+
+	file, err := os.Open(*inFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Created if it does not exist so that a new environment can be seeded
+	db, err := openBoltDatabase(*fileName, "", boltDefaultOpenTimeoutSeconds, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := importBoltRecords(db, file, BoltImportOptions{Mode: *mode, BatchSize: *batchSize})
+	fmt.Fprintf(out, "Imported %d records, skipped %d\n", result.Imported, result.Skipped)
+
+	return err
+}
+
+func runStatsCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	flags.SetOutput(out)
+	fileName := flags.String("file", "", "BoltDB file")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	db, err := openBoltFile(*fileName, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	d := &boltDbDatabase{name: *fileName, config: &boltDBConfig{}, db: db}
+	stats, err := d.Stats()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, string(data))
+
+	return nil
+}
+
+// The service must be stopped, a running service holds the file lock. Use Compact or BoltCompactRoute instead
+func runCompactCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("compact", flag.ContinueOnError)
+	flags.SetOutput(out)
+	fileName := flags.String("file", "", "BoltDB file")
+	outFile := flags.String("out", "", "Compacted copy. The file is replaced if empty")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if len(*fileName) < 1 {
+		return ErrDatabaseCommandNoFile
+	}
+
+	result, err := compactBoltFile(*fileName, *outFile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Compacted %d bytes to %d bytes in %s\n", result.SizeBefore, result.SizeAfter, result.Duration)
+
+	return nil
+}
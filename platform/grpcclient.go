@@ -0,0 +1,19 @@
+package platform
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+)
+
+// GrpcClientOptions ... Dial options that add client spans and pass traceparent to the server
+func GrpcClientOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	}
+}
+
+// NewGrpcClient ... grpc.NewClient with GrpcClientOptions. Call it with the context of the incoming request
+// for the calls to be part of its trace
+func NewGrpcClient(target string, options ...grpc.DialOption) (*grpc.ClientConn, error) {
+	return grpc.NewClient(target, append(GrpcClientOptions(), options...)...)
+}
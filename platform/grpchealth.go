@@ -0,0 +1,72 @@
+package platform
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	grpcHealthWatchInterval = 5 * time.Second
+)
+
+// Serves grpc.health.v1.Health from the readiness checks. The empty service name reports all checks,
+// any other name the check with that name
+type grpcHealthServer struct{}
+
+func registerGrpcHealthServer(server *grpc.Server) {
+	grpc_health_v1.RegisterHealthServer(server, &grpcHealthServer{})
+}
+
+func (g *grpcHealthServer) status(ctx context.Context, service string) (grpc_health_v1.HealthCheckResponse_ServingStatus, error) {
+	report := runHealthChecks(ctx, true, service)
+	if len(service) > 0 && len(report.Checks) < 1 {
+		return grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN, status.Errorf(codes.NotFound, "unknown service %s", service)
+	}
+
+	if !report.Up() {
+		return grpc_health_v1.HealthCheckResponse_NOT_SERVING, nil
+	}
+
+	return grpc_health_v1.HealthCheckResponse_SERVING, nil
+}
+
+func (g *grpcHealthServer) Check(ctx context.Context, request *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	servingStatus, err := g.status(ctx, request.Service)
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpc_health_v1.HealthCheckResponse{Status: servingStatus}, nil
+}
+
+// Watch ... Sends the current status and then every change, checked every few seconds
+func (g *grpcHealthServer) Watch(request *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	last := grpc_health_v1.HealthCheckResponse_UNKNOWN
+
+	for {
+		servingStatus, err := g.status(stream.Context(), request.Service)
+		if err != nil {
+			// Unknown services are reported as a status on a watch so that the client keeps waiting for them
+			servingStatus = grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+
+		if servingStatus != last {
+			err = stream.Send(&grpc_health_v1.HealthCheckResponse{Status: servingStatus})
+			if err != nil {
+				return err
+			}
+			last = servingStatus
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return status.Error(codes.Canceled, "stream has ended")
+		case <-time.After(grpcHealthWatchInterval):
+		}
+	}
+}
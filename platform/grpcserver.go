@@ -2,12 +2,14 @@ package platform
 
 import (
 	"context"
+	"crypto/tls"
 	"embed"
 	"io/fs"
 	"net"
 	"net/http"
 	"strings"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -26,6 +28,13 @@ type GRPCService interface {
 }
 
 func StartGrpcServerWithWeb(services []GRPCService, webDirectoryName string, webAssets *embed.FS) {
+	StartGrpcServerWithWebContext(context.Background(), services, webDirectoryName, webAssets)
+}
+
+// StartGrpcServerWithWebContext ... Same as StartGrpcServerWithWeb but also stops when ctx is cancelled.
+// Returns once running calls got the grace period to complete after ctx is cancelled or SIGINT or SIGTERM
+// is received, and the platform is shut down
+func StartGrpcServerWithWebContext(ctx context.Context, services []GRPCService, webDirectoryName string, webAssets *embed.FS) {
 	InitializeLogger()
 
 	config, err := GetPlatformConfiguration()
@@ -34,6 +43,27 @@ func StartGrpcServerWithWeb(services []GRPCService, webDirectoryName string, web
 		panic(err)
 	}
 
+	err = Database.BoltOpenError()
+	if err != nil {
+		Log.Error("BoltDB could not be opened", zap.Error(err))
+		panic(err)
+	}
+	defer shutdownPlatform()
+
+	err = runPlatformBoltMigrations(config)
+	if err != nil {
+		Log.Error("Error applying BoltDB migrations", zap.Error(err))
+		panic(err)
+	}
+
+	if config.Database.SQL.Enabled {
+		_, err = Database.RunSQLMigrations()
+		if err != nil {
+			Log.Error("Error applying SQL migrations", zap.Error(err))
+			panic(err)
+		}
+	}
+
 	conf := config.Grpc.Server
 	Log.Info("Starting new GRPC server", zap.String("ListeningAddress", conf.ListeningAddress))
 	lis, err := net.Listen("tcp", conf.ListeningAddress)
@@ -54,29 +84,54 @@ func StartGrpcServerWithWeb(services []GRPCService, webDirectoryName string, web
 		uiHandler = http.FileServer(http.FS(uiFs))
 	}
 
-	serverOptions := []grpc.ServerOption{}
+	// Server spans and traceparent from the incoming metadata
+	serverOptions := []grpc.ServerOption{grpc.StatsHandler(otelgrpc.NewServerHandler())}
+	// Recovery comes first so that panics in the other interceptors are caught too
+	interceptors := []grpc.UnaryServerInterceptor{RecoveryUnaryInterceptor()}
+	streamInterceptors := []grpc.StreamServerInterceptor{RecoveryStreamInterceptor()}
 
+	var tlsConfig *tls.Config
 	if conf.TLSEnabled {
-		creds, err := credentials.NewServerTLSFromFile(conf.TLSCertFileName, conf.TLSKeyFileName)
+		tlsConfig, err = newTLSConfig(conf.TLSMinVersion, conf.TLSCipherSuites)
+		if err != nil {
+			Log.Error("Invalid TLS settings for GRPC server", zap.Error(err))
+			return
+		}
+
+		certificate, err := tls.LoadX509KeyPair(conf.TLSCertFileName, conf.TLSKeyFileName)
 		if err != nil {
 			Log.Error("failed to load TLS credentials for GRPC server", zap.Error(err))
 			return
 		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
 
-		serverOptions = append(serverOptions, grpc.Creds(creds))
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
 
 		if config.Auth.Server.LocalJwt.Enabled {
 			// Add JWT authentication interceptor
-			serverOptions = append(serverOptions, grpc.UnaryInterceptor(grpcAuthInterceptor))
+			interceptors = append(interceptors, grpcAuthInterceptor)
 		}
 	}
 
+	if conf.RateLimit.Rate > 0 {
+		interceptors = append(interceptors, RateLimitUnaryInterceptor(conf.RateLimit.Rate, conf.RateLimit.Burst))
+		streamInterceptors = append(streamInterceptors, RateLimitStreamInterceptor(conf.RateLimit.Rate, conf.RateLimit.Burst))
+	}
+
+	serverOptions = append(serverOptions,
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...))
+
 	grpcServer := grpc.NewServer(serverOptions...)
 
 	for _, service := range services {
 		service.Register(grpcServer)
 	}
 
+	if config.Health.Enabled {
+		registerGrpcHealthServer(grpcServer)
+	}
+
 	mux := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Route gRPC requests to grpcServer
 		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
@@ -87,15 +142,47 @@ func StartGrpcServerWithWeb(services []GRPCService, webDirectoryName string, web
 		uiHandler.ServeHTTP(w, r)
 	})
 
+	grace := durationOrDefault(conf.ShutdownGracePeriodSeconds, httpServerDefaultShutdownGracePeriod)
+	served := make(chan error, 1)
+
 	if webEnabled {
-		if err := http.ServeTLS(lis, mux, conf.TLSCertFileName, conf.TLSKeyFileName); err != nil {
-			Log.Error("failed to serve web content", zap.Error(err))
-		}
-	} else {
-		if err := grpcServer.Serve(lis); err != nil {
-			Log.Error("failed to serve gRPC", zap.Error(err))
-		}
+		// gRPC is served through the HTTP server here, so the TLS settings go on the HTTP server
+		webServer := &http.Server{Handler: mux, TLSConfig: tlsConfig}
+		go func() {
+			served <- webServer.ServeTLS(lis, conf.TLSCertFileName, conf.TLSKeyFileName)
+		}()
+
+		serveUntilStopped(ctx, "gRPC", served, grace, func(shutdownCtx context.Context) {
+			err := webServer.Shutdown(shutdownCtx)
+			if err != nil {
+				Log.Warn("Calls still running after the grace period were cancelled", zap.Error(err))
+				webServer.Close()
+			}
+
+			// GracefulStop can not drain calls served through ServeHTTP, the HTTP server did that above
+			grpcServer.Stop()
+		})
+		return
 	}
+
+	go func() {
+		served <- grpcServer.Serve(lis)
+	}()
+
+	serveUntilStopped(ctx, "gRPC", served, grace, func(shutdownCtx context.Context) {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			Log.Warn("Calls still running after the grace period were cancelled")
+			grpcServer.Stop()
+		}
+	})
 }
 
 func StartGrpcServer(services []GRPCService) {
@@ -103,6 +190,11 @@ func StartGrpcServer(services []GRPCService) {
 }
 
 func grpcAuthInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	// Orchestrators probe the health service without a token
+	if strings.HasPrefix(info.FullMethod, "/grpc.health.v1.Health/") {
+		return handler(ctx, req)
+	}
+
 	if len(internalConfig.Grpc.Server.UnAuthenticatedPaths) > 0 {
 		for _, path := range internalConfig.Grpc.Server.UnAuthenticatedPaths {
 			if path == info.FullMethod {
@@ -0,0 +1,58 @@
+package platform
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStartGrpcServerContext(t *testing.T) {
+	useTempBoltDB(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to find a free port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	previous := internalConfig.Grpc.Server
+	internalConfig.Grpc.Server.ListeningAddress = address
+	internalConfig.Grpc.Server.TLSEnabled = false
+	t.Cleanup(func() {
+		internalConfig.Grpc.Server = previous
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		StartGrpcServerWithWebContext(ctx, nil, "", nil)
+		close(stopped)
+	}()
+
+	var conn net.Conn
+	for i := 0; i < 50; i++ {
+		conn, err = net.Dial("tcp", address)
+		if err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+	conn.Close()
+
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Server did not stop after the context was cancelled")
+	}
+
+	if Database.BoltDb.db != nil {
+		t.Error("Expected the platform to be shut down after the server stopped")
+	}
+}
@@ -0,0 +1,372 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	vaultapi "github.com/hashicorp/vault/api"
+	"go.uber.org/zap"
+)
+
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+
+	healthDefaultLivenessPath  = "/healthz"
+	healthDefaultReadinessPath = "/readyz"
+	healthDefaultTimeout       = 5 * time.Second
+)
+
+var (
+	ErrHealthCheckTimeout        = errors.New("health check timed out")
+	ErrHealthCheckNameRequired   = errors.New("health check needs a name")
+	ErrHealthBoltDbClosed        = errors.New("BoltDB is not open")
+	ErrHealthSQLUnhealthy        = errors.New("SQL database failed its last health check")
+	ErrHealthVaultUnreachable    = errors.New("no Vault address is reachable")
+	ErrHealthVaultSealed         = errors.New("Vault is sealed")
+	ErrHealthVaultNotInitialized = errors.New("Vault is not initialized")
+	ErrHealthIdpUnreachable      = errors.New("IDP well known configuration is not reachable")
+	ErrHealthOAuthTokenNotFresh  = errors.New("OAuth client token is missing or expired")
+
+	healthChecksLock sync.RWMutex
+	healthChecks     = make(map[string]healthCheck)
+)
+
+// HealthCheckFunc ... Returns nil when the dependency is healthy. ctx is cancelled when the check times out
+type HealthCheckFunc func(ctx context.Context) error
+
+type healthCheck struct {
+	check HealthCheckFunc
+	// Liveness checks run for both routes, the others only for readiness
+	liveness bool
+}
+
+// HealthCheckResult ... Outcome of a single check
+type HealthCheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthReport ... Overall status is down when any check is down
+type HealthReport struct {
+	Status string              `json:"status"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+// Up ... True when every check passed
+func (h HealthReport) Up() bool {
+	return h.Status == HealthStatusUp
+}
+
+// RegisterHealthCheck ... Adds a readiness check, e.g. for a downstream service. A check with the same name is replaced
+func RegisterHealthCheck(name string, check HealthCheckFunc) error {
+	return registerHealthCheck(name, check, false)
+}
+
+// RegisterLivenessCheck ... Adds a check that also fails liveness. Only use it for failures a restart fixes
+func RegisterLivenessCheck(name string, check HealthCheckFunc) error {
+	return registerHealthCheck(name, check, true)
+}
+
+// UnregisterHealthCheck ... Removes a custom check
+func UnregisterHealthCheck(name string) {
+	healthChecksLock.Lock()
+	defer healthChecksLock.Unlock()
+
+	delete(healthChecks, name)
+}
+
+func registerHealthCheck(name string, check HealthCheckFunc, liveness bool) error {
+	if len(name) < 1 || check == nil {
+		return ErrHealthCheckNameRequired
+	}
+
+	healthChecksLock.Lock()
+	defer healthChecksLock.Unlock()
+
+	healthChecks[name] = healthCheck{check: check, liveness: liveness}
+
+	return nil
+}
+
+// Checks of the enabled subsystems followed by the registered checks
+func collectHealthChecks(config *Config) map[string]healthCheck {
+	checks := make(map[string]healthCheck)
+
+	if boltDbInUse(config) {
+		checks["boltdb"] = healthCheck{check: checkBoltDbHealth, liveness: true}
+	}
+
+	if config.Database.SQL.Enabled {
+		checks["sql"] = healthCheck{check: checkSQLHealth}
+	}
+
+	if config.Vault.Enabled {
+		checks["vault"] = healthCheck{check: checkVaultHealth}
+	}
+
+	if config.Auth.Server.OAuth.Enabled && len(config.Auth.Server.OAuth.IdpWellKnownURL) > 0 {
+		checks["oauth_idp"] = healthCheck{check: checkIdpHealth}
+	}
+
+	for _, v := range config.Auth.Client.OAuth {
+		id := v.ID
+		checks["oauth_token_"+id] = healthCheck{check: func(ctx context.Context) error {
+			return checkOAuthTokenHealth(id)
+		}}
+	}
+
+	healthChecksLock.RLock()
+	for name, v := range healthChecks {
+		checks[name] = v
+	}
+	healthChecksLock.RUnlock()
+
+	return checks
+}
+
+// CheckHealth ... Runs the liveness checks, or all checks for readiness, in parallel
+func CheckHealth(ctx context.Context, readiness bool) HealthReport {
+	return runHealthChecks(ctx, readiness, "")
+}
+
+// Runs the checks, only the named one if name is set. Every check gets the configured timeout
+func runHealthChecks(ctx context.Context, readiness bool, name string) HealthReport {
+	config, err := GetPlatformConfiguration()
+	if err != nil {
+		return HealthReport{
+			Status: HealthStatusDown,
+			Checks: []HealthCheckResult{{Name: "config", Status: HealthStatusDown, Error: err.Error()}},
+		}
+	}
+
+	timeout := durationOrDefault(config.Health.TimeoutSeconds, healthDefaultTimeout)
+
+	checks := collectHealthChecks(config)
+	names := make([]string, 0, len(checks))
+	for k, v := range checks {
+		if (readiness || v.liveness) && (len(name) < 1 || name == k) {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	report := HealthReport{Status: HealthStatusUp, Checks: make([]HealthCheckResult, len(names))}
+
+	wait := sync.WaitGroup{}
+	for i, k := range names {
+		wait.Add(1)
+		go func(i int, name string, check HealthCheckFunc) {
+			defer wait.Done()
+			report.Checks[i] = runHealthCheck(ctx, name, check, timeout)
+		}(i, k, checks[k].check)
+	}
+	wait.Wait()
+
+	for _, v := range report.Checks {
+		if v.Status != HealthStatusUp {
+			report.Status = HealthStatusDown
+		}
+	}
+
+	return report
+}
+
+func runHealthCheck(ctx context.Context, name string, check HealthCheckFunc, timeout time.Duration) HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	// Checks that ignore ctx are abandoned when they time out
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				done <- fmt.Errorf("health check panicked: %v", recovered)
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ErrHealthCheckTimeout
+	}
+
+	result := HealthCheckResult{
+		Name:      name,
+		Status:    HealthStatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		Log.Warn("Health check failed", zap.String("check", name), zap.Error(err))
+		result.Status = HealthStatusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+func checkBoltDbHealth(ctx context.Context) error {
+	err := Database.BoltOpenError()
+	if err != nil {
+		return err
+	}
+
+	err = Database.BoltDb.use(func(db *bolt.DB) error {
+		return nil
+	})
+	if err != nil {
+		return ErrHealthBoltDbClosed
+	}
+
+	return nil
+}
+
+func checkSQLHealth(ctx context.Context) error {
+	if !Database.SQLHealthy() {
+		return ErrHealthSQLUnhealthy
+	}
+
+	return nil
+}
+
+// Up when at least one address of AddressList answers and is initialized and unsealed
+func checkVaultHealth(ctx context.Context) error {
+	if len(vaultClientList) < 1 {
+		return ErrHealthVaultUnreachable
+	}
+
+	failures := make([]string, 0)
+	for _, client := range vaultClientList {
+		err := vaultHealth(ctx, client)
+		if err == nil {
+			return nil
+		}
+
+		failures = append(failures, fmt.Sprintf("%s: %v", client.Address(), err))
+	}
+
+	return fmt.Errorf("%w: %s", ErrHealthVaultUnreachable, strings.Join(failures, "; "))
+}
+
+// Same request as Sys().Health(), which in this version of the Vault client does not take a context
+func vaultHealth(ctx context.Context, client *vaultapi.Client) error {
+	request := client.NewRequest(http.MethodGet, "/v1/sys/health")
+	// Sealed and uninitialized servers answer with a 5xx by default, ask for a body instead
+	request.Params.Add("uninitcode", "299")
+	request.Params.Add("sealedcode", "299")
+	request.Params.Add("standbycode", "299")
+	request.Params.Add("drsecondarycode", "299")
+	request.Params.Add("performancestandbycode", "299")
+
+	response, err := client.RawRequestWithContext(ctx, request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	health := vaultapi.HealthResponse{}
+	err = response.DecodeJSON(&health)
+	if err != nil {
+		return err
+	}
+
+	if !health.Initialized {
+		return ErrHealthVaultNotInitialized
+	}
+
+	if health.Sealed {
+		return ErrHealthVaultSealed
+	}
+
+	return nil
+}
+
+func checkIdpHealth(ctx context.Context) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, internalConfig.Auth.Server.OAuth.IdpWellKnownURL, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := oAuthHttpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrHealthIdpUnreachable, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: status code %d", ErrHealthIdpUnreachable, response.StatusCode)
+	}
+
+	return nil
+}
+
+func checkOAuthTokenHealth(id string) error {
+	oAuthTokensLock.RLock()
+	defer oAuthTokensLock.RUnlock()
+
+	if len(oAuthTokens[id]) < 1 || time.Now().After(oAuthTokenExpiry[id]) {
+		return ErrHealthOAuthTokenNotFresh
+	}
+
+	return nil
+}
+
+func healthHandler(readiness bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := CheckHealth(r.Context(), readiness)
+
+		statusCode := http.StatusOK
+		if !report.Up() {
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		JsonMarshaller.WriteJsonResponse(w, statusCode, report)
+	}
+}
+
+// Liveness and readiness routes. They never require authentication so that orchestrators can probe them
+func healthRoutes(config *Config) Routes {
+	if !config.Health.Enabled {
+		return Routes{}
+	}
+
+	livenessPath := config.Health.LivenessPath
+	if len(livenessPath) < 1 {
+		livenessPath = healthDefaultLivenessPath
+	}
+
+	readinessPath := config.Health.ReadinessPath
+	if len(readinessPath) < 1 {
+		readinessPath = healthDefaultReadinessPath
+	}
+
+	return Routes{
+		Route{
+			Path:        livenessPath,
+			Method:      http.MethodGet,
+			HandlerFunc: healthHandler(false),
+			RateLimit:   &RateLimit{},
+		},
+		Route{
+			Path:        readinessPath,
+			Method:      http.MethodGet,
+			HandlerFunc: healthHandler(true),
+			RateLimit:   &RateLimit{},
+		},
+	}
+}
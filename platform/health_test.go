@@ -0,0 +1,151 @@
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestHealthChecks(t *testing.T) {
+	useTempBoltDB(t)
+
+	RegisterLivenessCheck("custom_liveness", func(ctx context.Context) error {
+		return nil
+	})
+	RegisterHealthCheck("custom_failing", func(ctx context.Context) error {
+		return errors.New("downstream unavailable")
+	})
+	RegisterHealthCheck("custom_slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	t.Cleanup(func() {
+		UnregisterHealthCheck("custom_liveness")
+		UnregisterHealthCheck("custom_failing")
+		UnregisterHealthCheck("custom_slow")
+	})
+
+	previous := internalConfig.Health.TimeoutSeconds
+	internalConfig.Health.TimeoutSeconds = 1
+	t.Cleanup(func() {
+		internalConfig.Health.TimeoutSeconds = previous
+	})
+
+	response := httptest.NewRecorder()
+	healthHandler(false)(response, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected liveness to be up but got %d: %s", response.Code, response.Body.String())
+	}
+
+	report := HealthReport{}
+	json.Unmarshal(response.Body.Bytes(), &report)
+	if len(report.Checks) != 2 || report.Checks[0].Name != "boltdb" || report.Checks[1].Name != "custom_liveness" {
+		t.Errorf("Unexpected liveness checks %+v", report.Checks)
+	}
+
+	response = httptest.NewRecorder()
+	healthHandler(true)(response, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if response.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected readiness to be down but got %d", response.Code)
+	}
+
+	report = HealthReport{}
+	json.Unmarshal(response.Body.Bytes(), &report)
+	results := make(map[string]HealthCheckResult)
+	for _, v := range report.Checks {
+		results[v.Name] = v
+	}
+	if results["custom_failing"].Error != "downstream unavailable" {
+		t.Errorf("Unexpected result %+v", results["custom_failing"])
+	}
+	if results["custom_slow"].Error != ErrHealthCheckTimeout.Error() || results["custom_slow"].LatencyMs < 1000 {
+		t.Errorf("Expected the slow check to time out but got %+v", results["custom_slow"])
+	}
+	if _, ok := results["oauth_token_default"]; !ok {
+		t.Error("Expected a token freshness check per OAuth client")
+	}
+
+	server := &grpcHealthServer{}
+	grpcResponse, err := server.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "custom_liveness"})
+	if err != nil || grpcResponse.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Errorf("Expected SERVING but got %v, %v", grpcResponse, err)
+	}
+
+	grpcResponse, err = server.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	if err != nil || grpcResponse.Status != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected NOT_SERVING but got %v, %v", grpcResponse, err)
+	}
+
+	_, err = server.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "unknown"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound but got %v", err)
+	}
+}
+
+func TestOAuthTokenHealth(t *testing.T) {
+	oAuthTokensLock.Lock()
+	oAuthTokens["health"] = "token"
+	oAuthTokenExpiry["health"] = time.Now().Add(time.Minute)
+	oAuthTokensLock.Unlock()
+	t.Cleanup(func() {
+		oAuthTokensLock.Lock()
+		delete(oAuthTokens, "health")
+		delete(oAuthTokenExpiry, "health")
+		oAuthTokensLock.Unlock()
+	})
+
+	if err := checkOAuthTokenHealth("health"); err != nil {
+		t.Errorf("Expected a fresh token but got %v", err)
+	}
+
+	if err := checkOAuthTokenHealth("missing"); err != ErrHealthOAuthTokenNotFresh {
+		t.Errorf("Expected ErrHealthOAuthTokenNotFresh but got %v", err)
+	}
+}
+
+func TestVaultHealthSealed(t *testing.T) {
+	sealed := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/sys/health" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		// Status code as requested with sealedcode
+		w.WriteHeader(299)
+		json.NewEncoder(w).Encode(map[string]interface{}{"initialized": true, "sealed": sealed})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := vaultapi.NewClient(&vaultapi.Config{Address: server.URL})
+	if err != nil {
+		t.Fatalf("Error creating Vault client: %v", err)
+	}
+
+	previous := vaultClientList
+	vaultClientList = []*vaultapi.Client{client}
+	t.Cleanup(func() {
+		vaultClientList = previous
+	})
+
+	err = checkVaultHealth(context.Background())
+	if !errors.Is(err, ErrHealthVaultUnreachable) || !strings.Contains(err.Error(), ErrHealthVaultSealed.Error()) {
+		t.Errorf("Expected a sealed Vault to be down but got %v", err)
+	}
+
+	sealed = false
+	err = checkVaultHealth(context.Background())
+	if err != nil {
+		t.Errorf("Expected an unsealed Vault to be up but got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package platform
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	changeFeedKeepAliveInterval = time.Second * 15
+)
+
+// ChangeFeedRoute ... Route that streams the bucket changes of a database as server-sent events.
+// database is a name for Database.Bolt, "" for the default database. Authentication is always required.
+// Browsers resume from the Last-Event-ID header automatically and other clients can pass ?from=<sequence>
+func ChangeFeedRoute(path string, database string, bucket string, prefix string, rolesRequired []string) Route {
+	return Route{
+		Path:          path,
+		Method:        http.MethodGet,
+		HandlerFunc:   ChangeFeedHandler(database, bucket, prefix),
+		RolesRequired: rolesRequired,
+		AuthRequired:  true,
+	}
+}
+
+// ChangeFeedHandler ... Server-sent events handler for Watch on Database.Bolt(database).
+// When the changes after Last-Event-ID are no longer retained a reset event is sent first. The client should
+// then read the bucket again, the events after it are the live changes
+func ChangeFeedHandler(database string, bucket string, prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db := Database.Bolt(database)
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			Log.Error("Response writer does not support streaming")
+			JsonMarshaller.WriteError(w, NewError(http.StatusInternalServerError, ErrorCodeInternal, "Streaming not supported"))
+			return
+		}
+
+		from := r.Header.Get("Last-Event-ID")
+		if len(from) < 1 {
+			from = r.URL.Query().Get("from")
+		}
+
+		var watcher *BoltWatcher
+		var err error
+		reset := false
+		if len(from) > 0 {
+			sequence, parseErr := strconv.ParseUint(from, 10, 64)
+			if parseErr != nil {
+				JsonMarshaller.WriteError(w, NewError(http.StatusBadRequest, ErrorCodeBadRequest, "Last-Event-ID or from is not a sequence number").WithCause(parseErr))
+				return
+			}
+			watcher, err = db.WatchFrom(bucket, prefix, sequence)
+			if err == ErrBoltChangeFeedTruncated {
+				Log.Warn("Change feed resumed after the retained changes", zap.String("bucket", bucket), zap.Uint64("from", sequence))
+				reset = true
+				watcher, err = db.Watch(bucket, prefix)
+			}
+		} else {
+			watcher, err = db.Watch(bucket, prefix)
+		}
+		if err != nil {
+			Log.Error("Unable to watch bucket", zap.String("bucket", bucket), zap.Error(err))
+			JsonMarshaller.WriteError(w, NewError(http.StatusServiceUnavailable, ErrorCodeUnavailable, "Change feed not available").WithCause(err))
+			return
+		}
+		defer watcher.Close()
+
+		// The stream outlives the server write timeout
+		err = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		if err != nil {
+			Log.Debug("Unable to clear the write deadline of the change feed", zap.Error(err))
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		if reset {
+			// Watch was started first, so the changes after this sequence are sent as events
+			sequence, err := db.LastSequence()
+			if err != nil {
+				Log.Error("Unable to read the change feed sequence", zap.Error(err))
+				return
+			}
+			fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {\"sequence\":%d}\n\n", sequence, sequence)
+		}
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(changeFeedKeepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-httpServerStopping(r.Context()):
+				return
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+				flusher.Flush()
+			case event, ok := <-watcher.Events():
+				if !ok {
+					// The client reconnects and resumes from the last event id
+					Log.Warn("Change feed stopped", zap.String("bucket", bucket), zap.Error(watcher.Err()))
+					return
+				}
+
+				data, err := json.Marshal(event)
+				if err != nil {
+					Log.Error("Unable to marshal change event", zap.Error(err))
+					return
+				}
+
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Sequence, event.Type, data)
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -28,10 +28,13 @@ func CreateHttpClient(id string) (*http.Client, error) {
 	}
 
 	client := &http.Client{
-		Transport: &http.Transport{
-			MaxIdleConnsPerHost: clientConfig.MaxIdleConnections,
-			TLSClientConfig:     &tls.Config{InsecureSkipVerify: clientConfig.TLSVerify},
-		},
+		Transport: tracingRoundTripper(&metricsRoundTripper{
+			next: &http.Transport{
+				MaxIdleConnsPerHost: clientConfig.MaxIdleConnections,
+				TLSClientConfig:     &tls.Config{InsecureSkipVerify: clientConfig.TLSVerify},
+			},
+			client: clientConfig.ID,
+		}),
 		Timeout: time.Duration(clientConfig.RequestTimeout) * time.Second,
 	}
 
@@ -0,0 +1,116 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	queryParameterSort   = "sort"
+	queryParameterLimit  = "limit"
+	queryParameterOffset = "offset"
+)
+
+var (
+	ErrQueryInvalidParameter = errors.New("invalid query parameter")
+	ErrQueryFieldNotAllowed  = errors.New("query field is not allowed")
+)
+
+// ParseQuery ... Reads a Query from URL query parameters:
+//
+//	status=open                 equality
+//	total[gte]=10&total[lt]=50  gt, gte, lt and lte
+//	tag[in]=a,b                 any of the comma separated values
+//	name[contains]=ann          substring or array element
+//	sort=-created,name          - sorts descending
+//	limit=20&offset=40
+//
+// Only allowedFields can be filtered and sorted on. All fields are allowed if none are given
+func ParseQuery(values url.Values, allowedFields ...string) (*Query, error) {
+	query := NewQuery()
+
+	allowed := func(path string) bool {
+		if len(allowedFields) < 1 {
+			return true
+		}
+		for _, v := range allowedFields {
+			if v == path {
+				return true
+			}
+		}
+		return false
+	}
+
+	for name, parameterValues := range values {
+		switch name {
+		case queryParameterLimit, queryParameterOffset:
+			number, err := strconv.Atoi(values.Get(name))
+			if err != nil || number < 0 {
+				return nil, fmt.Errorf("%w: %s", ErrQueryInvalidParameter, name)
+			}
+			if name == queryParameterLimit {
+				query.Limit = number
+			} else {
+				query.Offset = number
+			}
+			continue
+		case queryParameterSort:
+			for _, v := range parameterValues {
+				for _, field := range strings.Split(v, ",") {
+					field = strings.TrimSpace(field)
+					descending := strings.HasPrefix(field, "-")
+					field = strings.TrimPrefix(field, "-")
+					if len(field) < 1 {
+						continue
+					}
+					if !allowed(field) {
+						return nil, fmt.Errorf("%w: %s", ErrQueryFieldNotAllowed, field)
+					}
+					query.SortBy(field, descending)
+				}
+			}
+			continue
+		}
+
+		path := name
+		operator := QueryEq
+		if open := strings.Index(name, "["); open > 0 && strings.HasSuffix(name, "]") {
+			path = name[:open]
+			operator = name[open+1 : len(name)-1]
+		}
+
+		switch operator {
+		case QueryEq, QueryGt, QueryGte, QueryLt, QueryLte, QueryIn, QueryContains:
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrQueryInvalidOperator, name)
+		}
+
+		if !allowed(path) {
+			return nil, fmt.Errorf("%w: %s", ErrQueryFieldNotAllowed, path)
+		}
+
+		for _, v := range parameterValues {
+			if operator != QueryIn {
+				query.Where(path, operator, v)
+				continue
+			}
+
+			list := make([]interface{}, 0)
+			for _, element := range strings.Split(v, ",") {
+				list = append(list, element)
+			}
+			query.Where(path, QueryIn, list)
+		}
+	}
+
+	// Map iteration order is random, keep the filters stable for logging and index selection
+	sort.SliceStable(query.Filters, func(i, j int) bool {
+		return query.Filters[i].Path+query.Filters[i].Operator < query.Filters[j].Path+query.Filters[j].Operator
+	})
+
+	return query, nil
+}
@@ -0,0 +1,119 @@
+package platform
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidIfMatchHeader = errors.New("If-Match header is not a revision ETag")
+)
+
+// RevisionETag ... Strong ETag for a record revision
+func RevisionETag(revision uint64) string {
+	return `"` + strconv.FormatUint(revision, 10) + `"`
+}
+
+// SetRevisionETag ... Sets the ETag header. Call before writing the response
+func SetRevisionETag(w http.ResponseWriter, revision uint64) {
+	w.Header().Set("ETag", RevisionETag(revision))
+}
+
+// IfMatch ... Parsed If-Match header. Pass it to CompareAndSaveIfMatch
+type IfMatch struct {
+	// Set for If-Match: *, which matches any existing entry
+	Any bool
+	// Revisions of the strong ETags in the header. Weak ETags never match and are left out
+	Revisions []uint64
+}
+
+// Matches ... True if an entry at the revision satisfies the header. Revision 0, an entry that does not exist, never matches
+func (m IfMatch) Matches(revision uint64) bool {
+	if revision == 0 {
+		return false
+	}
+
+	if m.Any {
+		return true
+	}
+
+	for _, v := range m.Revisions {
+		if v == revision {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RevisionFromIfMatch ... Reads the expected revisions from the If-Match header: *, or a list of ETags.
+// present is false if the header is not set
+func RevisionFromIfMatch(r *http.Request) (ifMatch IfMatch, present bool, err error) {
+	header := strings.TrimSpace(strings.Join(r.Header.Values("If-Match"), ","))
+	if len(header) < 1 {
+		return IfMatch{}, false, nil
+	}
+
+	if header == "*" {
+		return IfMatch{Any: true}, true, nil
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 1 {
+			continue
+		}
+
+		// If-Match compares strongly, so a weak ETag is a failed precondition and not an error
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+
+		revision, err := strconv.ParseUint(strings.Trim(tag, `"`), 10, 64)
+		if err != nil {
+			return IfMatch{}, true, ErrInvalidIfMatchHeader
+		}
+
+		ifMatch.Revisions = append(ifMatch.Revisions, revision)
+	}
+
+	return ifMatch, true, nil
+}
+
+// RequireIfMatch ... Returns the If-Match header. Writes 428 if the header is missing
+// or 400 if it is invalid, in which case ok is false and the handler should return
+func RequireIfMatch(w http.ResponseWriter, r *http.Request) (ifMatch IfMatch, ok bool) {
+	ifMatch, present, err := RevisionFromIfMatch(r)
+	if err != nil {
+		Log.Error("Invalid If-Match header", zap.String("if_match", r.Header.Get("If-Match")))
+		JsonMarshaller.WriteError(w, NewError(http.StatusBadRequest, ErrorCodeBadRequest, "If-Match header is not valid").WithCause(err))
+		return IfMatch{}, false
+	}
+
+	if !present {
+		JsonMarshaller.WriteError(w, NewError(http.StatusPreconditionRequired, ErrorCodePreconditionRequired, "If-Match header required"))
+		return IfMatch{}, false
+	}
+
+	return ifMatch, true
+}
+
+// WriteRevisionConflict ... Writes 412 Precondition Failed with the current ETag if err is a revision conflict.
+// Returns false for any other error so the handler can deal with it
+func WriteRevisionConflict(w http.ResponseWriter, err error) bool {
+	conflict := &RevisionConflictError{}
+	if !errors.As(err, &conflict) {
+		return false
+	}
+
+	if conflict.Actual > 0 {
+		SetRevisionETag(w, conflict.Actual)
+	}
+	JsonMarshaller.WriteError(w, err)
+
+	return true
+}
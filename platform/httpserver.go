@@ -1,15 +1,29 @@
 package platform
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"io/fs"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
+const (
+	httpServerDefaultReadTimeout         = 30 * time.Second
+	httpServerDefaultReadHeaderTimeout   = 10 * time.Second
+	httpServerDefaultWriteTimeout        = 60 * time.Second
+	httpServerDefaultIdleTimeout         = 120 * time.Second
+	httpServerDefaultShutdownGracePeriod = 30 * time.Second
+)
+
 func newRouter(serviceRoutes Routes) (*mux.Router, error) {
 	router := mux.NewRouter().StrictSlash(true)
 
@@ -18,16 +32,43 @@ func newRouter(serviceRoutes Routes) (*mux.Router, error) {
 		return nil, err
 	}
 
-	for index := range serviceRoutes {
-		route := serviceRoutes[index]
+	// Service routes are matched first so that a service can replace the built-in routes
+	routes := append(Routes{}, serviceRoutes...)
+	routes = append(routes, healthRoutes(conf)...)
+	routes = append(routes, metricsRoutes(conf)...)
+
+	corsPolicies := make(map[*mux.Route]*CorsPolicy)
+
+	for index := range routes {
+		route := routes[index]
 		var handler http.Handler
 		handler = route.HandlerFunc
 
-		// Add the middleware components. The are executed from the bottom up
-		// handler = middleware.AllowedContentType(handler, route.AllowedContentType)
+		// Add the middleware components. The are executed from the bottom up, so a request passes tracing, recovery,
+		// CORS, rate limit, auth, logging, Route.Middlewares, rate limit by user or API key, content type and then the handler
+		if len(route.AllowedContentType) > 0 {
+			handler = allowedContentTypeMiddleware(handler, route.AllowedContentType)
+		}
+
+		// Limits by user or API key need the middleware that validates the identity to run first,
+		// the others count requests that fail authentication too
+		rateLimit := routeRateLimit(route, conf)
+		if rateLimit != nil {
+			err = rateLimit.validate()
+			if err != nil {
+				Log.Error("Invalid rate limit", zap.String("path", route.Path), zap.Error(err))
+				return nil, err
+			}
+		}
+		if rateLimit != nil && rateLimit.keyedByIdentity() {
+			handler = rateLimitMiddleware(handler, route.Path, rateLimit, conf)
+		}
+
+		// After auth so that they can use the claims, and inside logging so that their responses are logged
+		handler = applyRouteMiddlewares(handler, route.Middlewares)
 
 		// TODO: Check if enabled before adding these
-		handler = loggingMiddleware(handler, route.SlaMs)
+		handler = loggingMiddleware(handler, route.Path, route.SlaMs)
 
 		if conf.Auth.Server.Basic.Enabled && route.AuthRequired {
 			handler = basicAuthMiddleware(handler, conf.Auth.Server.Basic.AllowedUsers)
@@ -41,32 +82,54 @@ func newRouter(serviceRoutes Routes) (*mux.Router, error) {
 			handler = localJwtAuthMiddleware(handler)
 		}
 
-		if conf.HTTP.Server.AllowCorsForLocalDevelopment {
-			handler = AllowCorsForLocalDevelopment(handler)
-
-			if route.Method != http.MethodOptions {
-				router.
-					Path(route.Path).
-					Methods(http.MethodOptions).
-					Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-						w.Header().Add("Access-Control-Allow-Origin", "*")
-						w.Header().Add("Access-Control-Allow-Methods", "*")
-						w.Header().Add("Access-Control-Allow-Headers", "*")
-					}))
-			}
+		if rateLimit != nil && !rateLimit.keyedByIdentity() {
+			handler = rateLimitMiddleware(handler, route.Path, rateLimit, conf)
+		}
 
+		corsPolicy := routeCorsPolicy(route, conf)
+		if corsPolicy != nil {
+			err = corsPolicy.validate()
+			if err != nil {
+				Log.Error("Invalid CORS policy", zap.String("path", route.Path), zap.Error(err))
+				return nil, err
+			}
+			handler = corsMiddleware(handler, corsPolicy)
 		}
 
-		router.
+		// Wraps all the other middleware so that a panic in any of them is recovered, inside tracing so that
+		// the span records the 500
+		handler = recoveryMiddleware(handler, route.Path)
+
+		handler = tracingMiddleware(handler, route.Path)
+
+		muxRoute := router.
 			Path(route.Path).
 			Methods(route.Method).
 			Handler(handler)
+
+		if corsPolicy != nil {
+			corsPolicies[muxRoute] = corsPolicy
+		}
 	}
 
+	// One route answers the preflights of every path. It comes after the service routes so that
+	// a service with OPTIONS routes of its own still gets them, and before any catch-all route
+	router.
+		MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+			return isCorsPreflight(r)
+		}).
+		Handler(corsPreflightHandler(router, corsPolicies))
+
 	return router, nil
 }
 
 func startHttpServerInternal(router *mux.Router) error {
+	return serveHttp(context.Background(), router)
+}
+
+// Serves until the listener fails, ctx is cancelled or SIGINT or SIGTERM is received.
+// Running requests get the grace period to complete before the platform is shut down
+func serveHttp(ctx context.Context, router http.Handler) error {
 	config, err := GetPlatformConfiguration()
 	if err != nil {
 		Log.Error("Error reading platform configuration", zap.Error(err))
@@ -74,25 +137,155 @@ func startHttpServerInternal(router *mux.Router) error {
 	}
 
 	// Do this for each database we add
-	if config.Database.BoltDB.Enabled {
-		defer Database.BoltDb.Close()
+	err = Database.BoltOpenError()
+	if err != nil {
+		Log.Error("BoltDB could not be opened", zap.Error(err))
+		return err
 	}
+	defer shutdownPlatform()
 
-	Log.Info("Starting new HTTP server", zap.String("ListeingAddress", config.HTTP.Server.ListeningAddress))
-	if config.HTTP.Server.TLSEnabled {
-		Log.Error("TLS Server stopped: ", zap.Error(
-			http.ListenAndServeTLS(config.HTTP.Server.ListeningAddress,
-				config.HTTP.Server.TLSCertFileName,
-				config.HTTP.Server.TLSKeyFileName,
-				router)))
-	} else {
-		Log.Error("HTTP Server stopped: ", zap.Error(
-			http.ListenAndServe(config.HTTP.Server.ListeningAddress, router)))
+	err = runPlatformBoltMigrations(config)
+	if err != nil {
+		Log.Error("Error applying BoltDB migrations", zap.Error(err))
+		return err
+	}
+
+	if config.Database.SQL.Enabled {
+		_, err = Database.RunSQLMigrations()
+		if err != nil {
+			Log.Error("Error applying SQL migrations", zap.Error(err))
+			return err
+		}
+	}
+
+	server, err := newHttpServer(config, router)
+	if err != nil {
+		Log.Error("Invalid HTTP server configuration", zap.Error(err))
+		return err
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		Log.Info("Starting new HTTP server", zap.String("ListeingAddress", config.HTTP.Server.ListeningAddress))
+		if config.HTTP.Server.TLSEnabled {
+			served <- server.ListenAndServeTLS(config.HTTP.Server.TLSCertFileName, config.HTTP.Server.TLSKeyFileName)
+		} else {
+			served <- server.ListenAndServe()
+		}
+	}()
+
+	if config.HTTP.Server.TLSEnabled && config.HTTP.Server.HTTPSRedirect.Enabled {
+		redirectServer := newHttpsRedirectServer(config)
+		defer redirectServer.Close()
+
+		go func() {
+			Log.Info("Starting HTTPS redirect server", zap.String("ListeningAddress", redirectServer.Addr))
+			err := redirectServer.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				Log.Error("HTTPS redirect server stopped", zap.Error(err))
+			}
+		}()
 	}
 
+	grace := durationOrDefault(config.HTTP.Server.ShutdownGracePeriodSeconds, httpServerDefaultShutdownGracePeriod)
+
+	return serveUntilStopped(ctx, "HTTP", served, grace, func(shutdownCtx context.Context) {
+		err := server.Shutdown(shutdownCtx)
+		if err != nil {
+			Log.Warn("Requests still running after the grace period were cancelled", zap.Error(err))
+			server.Close()
+		}
+	})
+}
+
+// Waits until the server fails, ctx is cancelled or SIGINT or SIGTERM is received. Then stop gets a context
+// that expires after the grace period to drain the server. Shared by the HTTP and gRPC servers
+func serveUntilStopped(ctx context.Context, name string, served <-chan error, grace time.Duration, stop func(ctx context.Context)) error {
+	ctx, cancelSignals := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancelSignals()
+
+	select {
+	case err := <-served:
+		Log.Error(name+" server stopped", zap.Error(err))
+		return err
+	case <-ctx.Done():
+	}
+
+	Log.Info("Shutting down "+name+" server", zap.Duration("grace_period", grace))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	stop(shutdownCtx)
+
+	Log.Info(name + " server stopped")
+
 	return nil
 }
 
+type httpServerStoppingKey struct{}
+
+func newHttpServer(config *Config, router http.Handler) (*http.Server, error) {
+	stopping := make(chan struct{})
+
+	// Wraps the whole router so that static web assets get the headers too
+	if config.HTTP.Server.SecurityHeaders.Enabled {
+		router = securityHeadersMiddleware(router, config)
+	}
+
+	server := &http.Server{
+		Addr:              config.HTTP.Server.ListeningAddress,
+		Handler:           router,
+		ReadTimeout:       durationOrDefault(config.HTTP.Server.ReadTimeoutSeconds, httpServerDefaultReadTimeout),
+		ReadHeaderTimeout: durationOrDefault(config.HTTP.Server.ReadHeaderTimeoutSeconds, httpServerDefaultReadHeaderTimeout),
+		WriteTimeout:      durationOrDefault(config.HTTP.Server.WriteTimeoutSeconds, httpServerDefaultWriteTimeout),
+		IdleTimeout:       durationOrDefault(config.HTTP.Server.IdleTimeoutSeconds, httpServerDefaultIdleTimeout),
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), httpServerStoppingKey{}, stopping)
+		},
+	}
+	server.RegisterOnShutdown(func() {
+		close(stopping)
+	})
+
+	if config.HTTP.Server.TLSEnabled {
+		tlsConfig, err := newTLSConfig(config.HTTP.Server.TLSMinVersion, config.HTTP.Server.TLSCipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = tlsConfig
+	}
+
+	return server, nil
+}
+
+// Closed when the server that received the request starts shutting down.
+// Streams that never complete on their own return on it so that draining can finish
+func httpServerStopping(ctx context.Context) <-chan struct{} {
+	stopping, _ := ctx.Value(httpServerStoppingKey{}).(chan struct{})
+	return stopping
+}
+
+func durationOrDefault(seconds int, defaultValue time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	return defaultValue
+}
+
+// StartHttpServerContext ... Same as StartHttpServer but also stops when ctx is cancelled.
+// For embedding the server in another program and for tests
+func StartHttpServerContext(ctx context.Context, routes Routes) error {
+	router, err := newRouter(routes)
+	if err != nil {
+		Log.Error("Error starting HTTP server", zap.Error(err))
+		return err
+	}
+
+	return serveHttp(ctx, router)
+}
+
 func StartHttpServer(routes Routes) error {
 	router, err := newRouter(routes)
 	if err != nil {
@@ -139,13 +332,22 @@ func StartHttpServerWithWeb(routes Routes, dist embed.FS) error {
 }
 
 type Route struct {
-	Path               string
-	Method             string
-	HandlerFunc        http.HandlerFunc
-	SlaMs              int64
-	RolesRequired      []string
+	Path          string
+	Method        string
+	HandlerFunc   http.HandlerFunc
+	SlaMs         int64
+	RolesRequired []string
+	// Requests with a body of another media type get 415. Parameters such as charset are ignored
 	AllowedContentType string
 	AuthRequired       bool
+	// Uses platform.http.server.ratelimit when nil
+	RateLimit *RateLimit
+	// Uses platform.http.server.cors when nil
+	Cors *CorsPolicy
+	// Service middleware around HandlerFunc. They run after tracing, panic recovery, CORS, rate limit, auth and logging
+	// and before the rate limit by user or API key and the content type check, in the order given, so the first one
+	// wraps all the others
+	Middlewares []func(http.Handler) http.Handler
 }
 
 type Routes []Route
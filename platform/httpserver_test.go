@@ -0,0 +1,69 @@
+package platform
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStartHttpServerContext(t *testing.T) {
+	useTempBoltDB(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to find a free port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	previous := internalConfig.HTTP.Server.ListeningAddress
+	internalConfig.HTTP.Server.ListeningAddress = address
+	t.Cleanup(func() {
+		internalConfig.HTTP.Server.ListeningAddress = previous
+	})
+
+	routes := Routes{
+		Route{
+			Path:   "/ping",
+			Method: http.MethodGet,
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- StartHttpServerContext(ctx, routes)
+	}()
+
+	var response *http.Response
+	for i := 0; i < 50; i++ {
+		response, err = http.Get("http://" + address + "/ping")
+		if err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204 but got %d", response.StatusCode)
+	}
+
+	cancel()
+
+	select {
+	case err = <-stopped:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown but got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Server did not stop after the context was cancelled")
+	}
+}
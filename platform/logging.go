@@ -1,6 +1,7 @@
 package platform
 
 import (
+	"context"
 	"errors"
 	"log"
 	"os"
@@ -39,6 +40,21 @@ func (l *Logger) Warn(msg string, fields ...zap.Field) {
 	l.internalLogger.Warn(msg, fields...)
 }
 
+// WithContext ... Logger that adds the trace and span id of ctx to every entry
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	fields := traceLogFields(ctx)
+	if len(fields) < 1 {
+		return l
+	}
+
+	return &Logger{internalLogger: l.internalLogger.With(fields...)}
+}
+
+// Sync ... Flushes buffered log entries
+func (l *Logger) Sync() error {
+	return l.internalLogger.Sync()
+}
+
 func init() {
 	InitializeLogger()
 }
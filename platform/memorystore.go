@@ -0,0 +1,232 @@
+package platform
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !e.expiresAt.After(now)
+}
+
+type memoryStore struct {
+	lock    sync.RWMutex
+	buckets map[string]map[string]memoryEntry
+}
+
+// NewMemoryStore ... KeyValueStore that only lives in memory. Intended for tests, e.g.
+//
+//	platform.Database.Store = platform.NewMemoryStore()
+func NewMemoryStore() KeyValueStore {
+	return &memoryStore{
+		buckets: make(map[string]map[string]memoryEntry),
+	}
+}
+
+func (m *memoryStore) SaveObject(bucket string, id string, object interface{}) error {
+	return m.Update(func(tx KeyValueTx) error {
+		return tx.SaveObject(bucket, id, object)
+	})
+}
+
+func (m *memoryStore) SaveObjectWithTTL(bucket string, id string, object interface{}, ttl time.Duration) error {
+	if ttl <= 0 {
+		Log.Error("Invalid TTL for object", zap.String("bucket", bucket), zap.String("id", id), zap.Duration("ttl", ttl))
+		return ErrBoltInvalidTTL
+	}
+
+	return m.Update(func(tx KeyValueTx) error {
+		return tx.(*memoryTx).saveObject(bucket, id, object, time.Now().Add(ttl))
+	})
+}
+
+func (m *memoryStore) ReadObject(bucket string, id string, object interface{}) error {
+	return m.View(func(tx KeyValueTx) error {
+		return tx.ReadObject(bucket, id, object)
+	})
+}
+
+func (m *memoryStore) ReadAllObjects(bucket string) (map[string]string, error) {
+	results := make(map[string]string)
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	entries, ok := m.buckets[bucket]
+	if !ok {
+		return results, ErrNoEntryFoundInDB
+	}
+
+	now := time.Now()
+	for id, entry := range entries {
+		if entry.expired(now) {
+			continue
+		}
+
+		results[id] = string(entry.value)
+	}
+
+	return results, nil
+}
+
+func (m *memoryStore) RemoveObject(bucket string, id string) error {
+	return m.Update(func(tx KeyValueTx) error {
+		return tx.RemoveObject(bucket, id)
+	})
+}
+
+func (m *memoryStore) RemoveBucket(bucket string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.buckets, bucket)
+
+	return nil
+}
+
+func (m *memoryStore) ForEach(bucket string, fn func(id string, value []byte) error) error {
+	return m.View(func(tx KeyValueTx) error {
+		return tx.ForEach(bucket, fn)
+	})
+}
+
+func (m *memoryStore) View(fn func(tx KeyValueTx) error) error {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return fn(&memoryTx{buckets: m.buckets})
+}
+
+// Update ... Changes are made to a copy of the buckets that replaces the original when fn succeeds
+func (m *memoryStore) Update(fn func(tx KeyValueTx) error) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	tx := &memoryTx{
+		buckets:  m.buckets,
+		writable: true,
+		copied:   make(map[string]bool),
+	}
+
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+
+	m.buckets = tx.buckets
+
+	return nil
+}
+
+func (m *memoryStore) Close() error {
+	return nil
+}
+
+type memoryTx struct {
+	buckets  map[string]map[string]memoryEntry
+	writable bool
+	// Buckets that have already been copied in this transaction
+	copied map[string]bool
+}
+
+// Copy on write so that the store is untouched if the transaction fails
+func (t *memoryTx) writableBucket(bucket string) map[string]memoryEntry {
+	if t.copied[bucket] {
+		return t.buckets[bucket]
+	}
+
+	if len(t.copied) == 0 {
+		buckets := make(map[string]map[string]memoryEntry, len(t.buckets))
+		for k, v := range t.buckets {
+			buckets[k] = v
+		}
+		t.buckets = buckets
+	}
+
+	entries := make(map[string]memoryEntry, len(t.buckets[bucket]))
+	for k, v := range t.buckets[bucket] {
+		entries[k] = v
+	}
+
+	t.buckets[bucket] = entries
+	t.copied[bucket] = true
+
+	return entries
+}
+
+func (t *memoryTx) SaveObject(bucket string, id string, object interface{}) error {
+	return t.saveObject(bucket, id, object, time.Time{})
+}
+
+func (t *memoryTx) saveObject(bucket string, id string, object interface{}, expiresAt time.Time) error {
+	if !t.writable {
+		return ErrDatabaseReadOnlyTx
+	}
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		Log.Error("Error marshalling object", zap.Error(err))
+		return err
+	}
+
+	t.writableBucket(bucket)[id] = memoryEntry{value: data, expiresAt: expiresAt}
+
+	return nil
+}
+
+func (t *memoryTx) ReadObject(bucket string, id string, object interface{}) error {
+	entry, ok := t.buckets[bucket][id]
+	if !ok || entry.expired(time.Now()) {
+		return ErrNoEntryFoundInDB
+	}
+
+	return json.Unmarshal(entry.value, object)
+}
+
+func (t *memoryTx) RemoveObject(bucket string, id string) error {
+	if !t.writable {
+		return ErrDatabaseReadOnlyTx
+	}
+
+	if _, ok := t.buckets[bucket]; !ok {
+		return nil
+	}
+
+	delete(t.writableBucket(bucket), id)
+
+	return nil
+}
+
+func (t *memoryTx) ForEach(bucket string, fn func(id string, value []byte) error) error {
+	entries := t.buckets[bucket]
+
+	ids := make([]string, 0, len(entries))
+	for id := range entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	now := time.Now()
+	for _, id := range ids {
+		entry := entries[id]
+		if entry.expired(now) {
+			continue
+		}
+
+		err := fn(id, entry.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
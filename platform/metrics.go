@@ -0,0 +1,218 @@
+package platform
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	metricsNamespace   = "goslow"
+	metricsDefaultPath = "/metrics"
+
+	metricsResultOk       = "ok"
+	metricsResultNotFound = "not_found"
+	metricsResultConflict = "conflict"
+	metricsResultError    = "error"
+)
+
+var (
+	// MetricsRegistry ... Registry served on the metrics route. Services register their own collectors here
+	MetricsRegistry = prometheus.NewRegistry()
+
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests served by route template, method and status code",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of HTTP requests by route template, method and status code",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	httpSlaBreachesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "http",
+		Name:      "sla_breaches_total",
+		Help:      "HTTP requests that took longer than the SlaMs of their route",
+	}, []string{"route", "method"})
+
+	boltOperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "boltdb",
+		Name:      "operations_total",
+		Help:      "BoltDB operations by database, operation and result",
+	}, []string{"database", "operation", "result"})
+
+	boltOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "boltdb",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of BoltDB operations by database and operation",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+	}, []string{"database", "operation"})
+
+	oAuthTokenRenewalsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "oauth",
+		Name:      "token_renewals_total",
+		Help:      "OAuth client token renewals by client config and result",
+	}, []string{"config_id", "result"})
+
+	vaultRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "vault",
+		Name:      "requests_total",
+		Help:      "Vault calls by operation and result",
+	}, []string{"operation", "result"})
+
+	vaultRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "vault",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of Vault calls by operation, including retries over AddressList",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	panicsRecoveredTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "panics_recovered_total",
+		Help:      "Panics in HTTP handlers and gRPC methods that were recovered, by server and route template or method",
+	}, []string{"server", "route"})
+
+	httpClientRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "http_client",
+		Name:      "requests_total",
+		Help:      "Outbound HTTP requests by client config, method and status code. Status is error when no response was received",
+	}, []string{"client", "method", "status"})
+
+	httpClientRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "http_client",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of outbound HTTP requests by client config and method",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"client", "method"})
+)
+
+func init() {
+	MetricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestsTotal,
+		httpRequestDuration,
+		httpSlaBreachesTotal,
+		boltOperationsTotal,
+		boltOperationDuration,
+		oAuthTokenRenewalsTotal,
+		vaultRequestsTotal,
+		vaultRequestDuration,
+		panicsRecoveredTotal,
+		httpClientRequestsTotal,
+		httpClientRequestDuration,
+	)
+}
+
+func metricsResult(err error) string {
+	if err == nil {
+		return metricsResultOk
+	}
+
+	if err == ErrNoEntryFoundInDB {
+		return metricsResultNotFound
+	}
+
+	conflict := &RevisionConflictError{}
+	if errors.As(err, &conflict) {
+		return metricsResultConflict
+	}
+
+	return metricsResultError
+}
+
+func observeHttpRequest(route string, method string, status int, duration time.Duration, slaMs int64) {
+	statusLabel := strconv.Itoa(status)
+
+	httpRequestsTotal.WithLabelValues(route, method, statusLabel).Inc()
+	httpRequestDuration.WithLabelValues(route, method, statusLabel).Observe(duration.Seconds())
+
+	if slaMs > 0 && duration.Milliseconds() > slaMs {
+		httpSlaBreachesTotal.WithLabelValues(route, method).Inc()
+	}
+}
+
+func boltDatabaseLabel(d *boltDbDatabase) string {
+	if len(d.name) < 1 {
+		return "default"
+	}
+
+	return d.name
+}
+
+// Deferred with the named error result of the operation
+func observeBoltOperation(d *boltDbDatabase, operation string, start time.Time, err *error) {
+	name := boltDatabaseLabel(d)
+
+	boltOperationsTotal.WithLabelValues(name, operation, metricsResult(*err)).Inc()
+	boltOperationDuration.WithLabelValues(name, operation).Observe(time.Since(start).Seconds())
+}
+
+// Deferred with the named error result of the call
+func observeVaultRequest(operation string, start time.Time, err *error) {
+	vaultRequestsTotal.WithLabelValues(operation, metricsResult(*err)).Inc()
+	vaultRequestDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// Records the requests of a client created with CreateHttpClient
+type metricsRoundTripper struct {
+	next   http.RoundTripper
+	client string
+}
+
+func (m *metricsRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	start := time.Now()
+
+	response, err := m.next.RoundTrip(request)
+
+	status := metricsResultError
+	if err == nil {
+		status = strconv.Itoa(response.StatusCode)
+	}
+
+	httpClientRequestsTotal.WithLabelValues(m.client, request.Method, status).Inc()
+	httpClientRequestDuration.WithLabelValues(m.client, request.Method).Observe(time.Since(start).Seconds())
+
+	return response, err
+}
+
+// Prometheus text format route. It does not require authentication, protect it on the network level
+func metricsRoutes(config *Config) Routes {
+	if !config.Metrics.Enabled {
+		return Routes{}
+	}
+
+	path := config.Metrics.Path
+	if len(path) < 1 {
+		path = metricsDefaultPath
+	}
+
+	return Routes{
+		Route{
+			Path:        path,
+			Method:      http.MethodGet,
+			HandlerFunc: promhttp.HandlerFor(MetricsRegistry, promhttp.HandlerOpts{}).ServeHTTP,
+			RateLimit:   &RateLimit{},
+		},
+	}
+}
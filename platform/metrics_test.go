@@ -0,0 +1,97 @@
+package platform
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHttpMetrics(t *testing.T) {
+	routes := Routes{
+		Route{
+			Path:   "/orders/{id}",
+			Method: http.MethodGet,
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(5 * time.Millisecond)
+				w.WriteHeader(http.StatusAccepted)
+			},
+			SlaMs: 1,
+		},
+	}
+
+	router, err := newRouter(routes)
+	if err != nil {
+		t.Fatalf("Error creating router: %v", err)
+	}
+
+	breaches := testutil.ToFloat64(httpSlaBreachesTotal.WithLabelValues("/orders/{id}", http.MethodGet))
+
+	for _, id := range []string{"1", "2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders/"+id, nil))
+	}
+
+	if value := testutil.ToFloat64(httpSlaBreachesTotal.WithLabelValues("/orders/{id}", http.MethodGet)); value != breaches+2 {
+		t.Errorf("Expected 2 more SLA breaches but got %v", value-breaches)
+	}
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(response.Body)
+
+	expected := `goslow_http_requests_total{method="GET",route="/orders/{id}",status="202"}`
+	if response.Code != http.StatusOK || !strings.Contains(string(body), expected) {
+		t.Errorf("Expected %s in the metrics but got %d: %s", expected, response.Code, body)
+	}
+}
+
+func TestBoltMetrics(t *testing.T) {
+	useTempBoltDB(t)
+
+	read := func(result string) float64 {
+		return testutil.ToFloat64(boltOperationsTotal.WithLabelValues("default", "read", result))
+	}
+	found, notFound := read(metricsResultOk), read(metricsResultNotFound)
+
+	Database.BoltDb.SaveObject("metrics", "1", testObject{Id: "1"})
+	Database.BoltDb.ReadObject("metrics", "1", &testObject{})
+	Database.BoltDb.ReadObject("metrics", "2", &testObject{})
+
+	if read(metricsResultOk) != found+1 || read(metricsResultNotFound) != notFound+1 {
+		t.Errorf("Unexpected read counters %v, %v", read(metricsResultOk)-found, read(metricsResultNotFound)-notFound)
+	}
+
+	conflicts := testutil.ToFloat64(boltOperationsTotal.WithLabelValues("default", "compare_and_save", metricsResultConflict))
+	Database.BoltDb.CompareAndSave("metrics", "1", testObject{Id: "1"}, 5)
+	if testutil.ToFloat64(boltOperationsTotal.WithLabelValues("default", "compare_and_save", metricsResultConflict)) != conflicts+1 {
+		t.Error("Expected a revision conflict to be counted")
+	}
+}
+
+func TestHttpClientMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	client, err := CreateHttpClient("default")
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+
+	before := testutil.ToFloat64(httpClientRequestsTotal.WithLabelValues("default", http.MethodGet, "418"))
+
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Error calling server: %v", err)
+	}
+	response.Body.Close()
+
+	if testutil.ToFloat64(httpClientRequestsTotal.WithLabelValues("default", http.MethodGet, "418")) != before+1 {
+		t.Error("Expected the client request to be counted")
+	}
+}
@@ -2,6 +2,7 @@ package platform
 
 import (
 	"context"
+	"mime"
 	"net/http"
 	"strings"
 	"time"
@@ -17,6 +18,19 @@ const (
 	ContextOAuthPreferredUsername string = "OAuthPreferredUsername"
 	ContextOAuthIssuer            string = "OAuthIssuer"
 	ContextLocalJwtClaims         string = "LocalJwtClaims"
+	ContextBasicAuthUsername      string = "BasicAuthUsername"
+	// Set by the service middleware that validated the API key of the request, e.g. with
+	// context.WithValue(r.Context(), platform.ContextAPIKey, key). Rate limits by apikey use it
+	ContextAPIKey string = "APIKey"
+)
+
+var (
+	errAuthorizationHeaderRequired = NewError(http.StatusUnauthorized, ErrorCodeUnauthorized, "Authorization header required")
+	errAuthorizationHeaderInvalid  = NewError(http.StatusBadRequest, ErrorCodeBadRequest, "Authorization header is not valid")
+	errInvalidToken                = NewError(http.StatusUnauthorized, ErrorCodeUnauthorized, "Token is not valid")
+	errInvalidCredentials          = NewError(http.StatusUnauthorized, ErrorCodeUnauthorized, "Username or password is not valid")
+	errRoleRequired                = NewError(http.StatusForbidden, ErrorCodeForbidden, "A required role is missing")
+	errContentTypeNotAllowed       = NewError(http.StatusUnsupportedMediaType, ErrorCodeUnsupportedMediaType, "Content type not allowed")
 )
 
 type responseWriter struct {
@@ -41,6 +55,26 @@ func (rw *responseWriter) WriteHeader(code int) {
 	return
 }
 
+// Handlers that only call Write send a 200 implicitly
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	return rw.ResponseWriter.Write(b)
+}
+
+// Needed for streaming responses such as the change feed
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func (rw *responseWriter) Status() int {
 	if rw.status == 0 {
 		return 200
@@ -49,16 +83,23 @@ func (rw *responseWriter) Status() int {
 	return rw.status
 }
 
-func loggingMiddleware(next http.Handler, slaMs int64) http.Handler {
+// Logs and records metrics for every request. routePath is the template the route was registered with
+// so that metrics are not labelled with every distinct URL
+func loggingMiddleware(next http.Handler, routePath string, slaMs int64) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
 		wrapped := wrapResponseWriter(w)
 		next.ServeHTTP(wrapped, r)
 
-		difference := time.Since(start).Milliseconds()
+		duration := time.Since(start)
+		difference := duration.Milliseconds()
+
+		observeHttpRequest(routePath, r.Method, wrapped.Status(), duration, slaMs)
+
+		log := Log.WithContext(r.Context())
 
-		Log.Info("Request completed",
+		log.Info("Request completed",
 			zap.Int("statuscode", wrapped.Status()),
 			zap.String("method", r.Method),
 			zap.String("path", r.URL.EscapedPath()),
@@ -66,7 +107,7 @@ func loggingMiddleware(next http.Handler, slaMs int64) http.Handler {
 
 		if slaMs > 0 {
 			if difference > slaMs {
-				Log.Warn("SLA contract exceeded",
+				log.Warn("SLA contract exceeded",
 					zap.Int64("SLA", slaMs),
 					zap.String("method", r.Method),
 					zap.String("path", r.URL.EscapedPath()),
@@ -115,28 +156,28 @@ func oAuth2Middleware(inner http.Handler, roles []string) http.Handler {
 			rawAccessToken := r.Header.Get("Authorization")
 
 			if rawAccessToken == "" {
-				w.WriteHeader(http.StatusUnauthorized)
+				JsonMarshaller.WriteError(w, errAuthorizationHeaderRequired)
 				return
 			}
 
 			parts := strings.Split(rawAccessToken, " ")
 			if len(parts) != 2 {
 				Log.Error("Auth header not build correctly")
-				w.WriteHeader(http.StatusBadRequest)
+				JsonMarshaller.WriteError(w, errAuthorizationHeaderInvalid)
 				return
 			}
 			accessToken = parts[1]
 			idToken, err := verifier.Verify(ctx, parts[1])
 			if err != nil {
 				Log.Error("Token verification failed", zap.Error(err))
-				w.WriteHeader(http.StatusUnauthorized)
+				JsonMarshaller.WriteError(w, errInvalidToken)
 				return
 			}
 			claims := customClaims{}
 			err = idToken.Claims(&claims)
 			if err != nil {
 				Log.Error("Unable to get claims", zap.Error(err))
-				w.WriteHeader(http.StatusUnauthorized)
+				JsonMarshaller.WriteError(w, errInvalidToken)
 				return
 			}
 
@@ -158,7 +199,7 @@ func oAuth2Middleware(inner http.Handler, roles []string) http.Handler {
 					zap.Strings("allowedRoles", claims.Roles),
 					zap.String("path", r.URL.EscapedPath()),
 					zap.String("method", r.Method))
-				w.WriteHeader(http.StatusUnauthorized)
+				JsonMarshaller.WriteError(w, errRoleRequired)
 				return
 			}
 
@@ -175,6 +216,7 @@ func oAuth2Middleware(inner http.Handler, roles []string) http.Handler {
 	})
 }
 
+// Requests without a body, e.g. GET, are not checked
 func allowedContentTypeMiddleware(inner http.Handler, contentTypeConfig string) http.Handler {
 
 	contentType := strings.ToLower(contentTypeConfig)
@@ -182,14 +224,15 @@ func allowedContentTypeMiddleware(inner http.Handler, contentTypeConfig string)
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		if enabled {
+		if enabled && r.ContentLength != 0 {
 			result := r.Header.Get("Content-Type")
+			mediaType, _, err := mime.ParseMediaType(result)
 
-			if strings.ToLower(result) == contentType {
+			if err == nil && mediaType == contentType {
 				inner.ServeHTTP(w, r)
 			} else {
 				Log.Error("Content type not allowed", zap.String("content-type", result))
-				w.WriteHeader(http.StatusUnsupportedMediaType)
+				JsonMarshaller.WriteError(w, errContentTypeNotAllowed.WithDetail("allowed", contentTypeConfig))
 			}
 		} else {
 			inner.ServeHTTP(w, r)
@@ -209,7 +252,7 @@ func basicAuthMiddleware(inner http.Handler, users map[string]string) http.Handl
 
 			if len(allowedPassword) < 1 {
 				Log.Error("User not allowed")
-				w.WriteHeader(http.StatusUnauthorized)
+				JsonMarshaller.WriteError(w, errInvalidCredentials)
 				return
 			}
 
@@ -217,18 +260,19 @@ func basicAuthMiddleware(inner http.Handler, users map[string]string) http.Handl
 
 			if compareError != nil {
 				Log.Error("Password incorrect", zap.Error(compareError))
-				w.WriteHeader(http.StatusUnauthorized)
+				JsonMarshaller.WriteError(w, errInvalidCredentials)
 				return
 			} else {
-				inner.ServeHTTP(w, r)
+				inner.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ContextBasicAuthUsername, username)))
 			}
 		} else {
 			Log.Error("Authorization header required")
-			w.WriteHeader(http.StatusUnauthorized)
+			JsonMarshaller.WriteError(w, errAuthorizationHeaderRequired)
 		}
 	})
 }
 
+// Deprecated: Set platform.http.server.cors or Route.Cors. The HTTP server no longer uses this
 func AllowCorsForLocalDevelopment(inner http.Handler) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -248,21 +292,27 @@ func localJwtAuthMiddleware(next http.Handler) http.Handler {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
 			Log.Error("Authorization header is missing")
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			JsonMarshaller.WriteError(w, errAuthorizationHeaderRequired)
+			return
+		}
+
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			Log.Error("Authorization header is not a bearer token")
+			JsonMarshaller.WriteError(w, errInvalidToken)
 			return
 		}
 
 		tokenString := authHeader[len("Bearer "):]
 		if tokenString == "" {
 			Log.Error("Bearer token is missing in Authorization header")
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			JsonMarshaller.WriteError(w, errInvalidToken)
 			return
 		}
 
 		claims, err := LocalJwt.ValidateLocalJwtToken(tokenString)
 		if err != nil {
 			Log.Error("Token validation failed", zap.Error(err))
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			JsonMarshaller.WriteError(w, errInvalidToken)
 			return
 		}
 
@@ -1,6 +1,7 @@
 package platform
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -9,10 +10,13 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/lestrrat/go-jwx/jwk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -24,10 +28,16 @@ var (
 	oAuthHttpClient                *http.Client
 	oAuthDefaultTLSConfig          = &tls.Config{InsecureSkipVerify: true}
 	oAuthTokens                    map[string]string
+	oAuthTokenExpiry               map[string]time.Time
+	oAuthTokensLock                sync.RWMutex
 
 	OAuth oAuthOrganiser
 
 	issuerJwkUrlMap map[string]idpDetailsCacheItem
+
+	oAuthRenewers      sync.WaitGroup
+	oAuthRenewStop     = make(chan struct{})
+	oAuthRenewStopOnce sync.Once
 )
 
 const (
@@ -55,6 +65,7 @@ func init() {
 func initializeOAuthTokenClients() {
 	if len(internalConfig.Auth.Client.OAuth) > 0 {
 		oAuthTokens = make(map[string]string, 0)
+		oAuthTokenExpiry = make(map[string]time.Time, 0)
 		oAuthClientTokenConfiguration = make([]clientTokenConfig, 0)
 		oAuthClientTokenEnabled = true
 
@@ -77,35 +88,59 @@ func initializeOAuthTokenClients() {
 
 			oAuthClientTokenConfiguration = append(oAuthClientTokenConfiguration, v)
 
+			oAuthRenewers.Add(1)
 			go autoRenewOAuth2Token(v)
 		}
 	}
 }
 
 func autoRenewOAuth2Token(config clientTokenConfig) {
+	defer oAuthRenewers.Done()
+
 	currentToken := ""
 	// Always renew
 	currentExpiryTime := time.Now().Add(-(time.Minute * 60))
 	for true {
+		wait := time.Duration(config.RenewCheckIntervalSeconds) * time.Second
+
 		if time.Since(currentExpiryTime).Minutes() > -(config.RenewCheckTimeMinutes) {
 			Log.Info("Renewing token", zap.String("config_id", config.ID))
 			auth2Token, err := internalGetOAuth2Token(config.ID)
 			if err != nil {
 				Log.Error("Error getting token. Waiting before retrying", zap.Error(err))
-				time.Sleep(time.Second * 5)
-				continue
+				oAuthTokenRenewalsTotal.WithLabelValues(config.ID, metricsResultError).Inc()
+				wait = time.Second * 5
+			} else {
+				currentToken = auth2Token
+				currentExpiryTime = getExpiryTimeFromToken(currentToken)
+				oAuthTokensLock.Lock()
+				oAuthTokens[config.ID] = currentToken
+				oAuthTokenExpiry[config.ID] = currentExpiryTime
+				oAuthTokensLock.Unlock()
+
+				oAuthTokenRenewalsTotal.WithLabelValues(config.ID, metricsResultOk).Inc()
+				Log.Info("Token renewed", zap.String("config_id", config.ID), zap.Time("next_expiry", currentExpiryTime))
 			}
-			currentToken = auth2Token
-			currentExpiryTime = getExpiryTimeFromToken(currentToken)
-			oAuthTokens[config.ID] = currentToken
-
-			Log.Info("Token renewed", zap.String("config_id", config.ID), zap.Time("next_expiry", currentExpiryTime))
 		}
 
-		time.Sleep(time.Duration(config.RenewCheckIntervalSeconds) * time.Second)
+		select {
+		case <-oAuthRenewStop:
+			Log.Debug("Token renewal stopped", zap.String("config_id", config.ID))
+			return
+		case <-time.After(wait):
+		}
 	}
 }
 
+// Stops the token renewal and waits for a running renewal to complete
+func stopOAuthRenewers() {
+	oAuthRenewStopOnce.Do(func() {
+		close(oAuthRenewStop)
+	})
+
+	oAuthRenewers.Wait()
+}
+
 func getExpiryTimeFromToken(data string) time.Time {
 	// This is probably bad...
 	token, _ := jwt.Parse(data, func(token *jwt.Token) (interface{}, error) {
@@ -122,10 +157,10 @@ func getExpiryTimeFromToken(data string) time.Time {
 
 func createOAuthHTTPClient() *http.Client {
 	client := &http.Client{
-		Transport: &http.Transport{
+		Transport: tracingRoundTripper(&http.Transport{
 			MaxIdleConnsPerHost: oAuthMaxIdleConnections,
 			TLSClientConfig:     oAuthDefaultTLSConfig,
-		},
+		}),
 		Timeout: time.Duration(oAuthRequestTimeout) * time.Second,
 	}
 
@@ -133,7 +168,9 @@ func createOAuthHTTPClient() *http.Client {
 }
 
 func (o oAuthOrganiser) GetToken(id string) (accessToken string, err error) {
+	oAuthTokensLock.RLock()
 	token := oAuthTokens[id]
+	oAuthTokensLock.RUnlock()
 
 	if len(token) < 1 {
 		Log.Error("No token in local cache", zap.String("config_id", id))
@@ -150,6 +187,10 @@ func (o oAuthOrganiser) GetToken(id string) (accessToken string, err error) {
 }
 
 func internalGetOAuth2Token(id string) (accessToken string, err error) {
+	// Renewals run in the background, so every token request is a trace of its own
+	ctx, span := Tracer().Start(context.Background(), "OAuth token request", trace.WithAttributes(attribute.String("config_id", id)))
+	defer endSpan(span, &err)
+
 	var clientConfig clientTokenConfig
 	for _, v := range oAuthClientTokenConfiguration {
 		if v.ID == id {
@@ -182,7 +223,7 @@ func internalGetOAuth2Token(id string) (accessToken string, err error) {
 			clientConfig.Password))
 	}
 
-	request, _ := http.NewRequest("POST", url, payload)
+	request, _ := http.NewRequestWithContext(ctx, "POST", url, payload)
 
 	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
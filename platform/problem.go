@@ -0,0 +1,300 @@
+package platform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	ErrorCodeBadRequest           = "bad_request"
+	ErrorCodeUnauthorized         = "unauthorized"
+	ErrorCodeForbidden            = "forbidden"
+	ErrorCodeNotFound             = "not_found"
+	ErrorCodeConflict             = "conflict"
+	ErrorCodePreconditionFailed   = "precondition_failed"
+	ErrorCodePreconditionRequired = "precondition_required"
+	ErrorCodeUnsupportedMediaType = "unsupported_media_type"
+	ErrorCodeRateLimited          = "rate_limited"
+	ErrorCodeInternal             = "internal"
+	ErrorCodeUnavailable          = "unavailable"
+	ErrorCodeTimeout              = "timeout"
+
+	problemContentType = "application/problem+json"
+	problemTypeBlank   = "about:blank"
+
+	// Domain of the ErrorInfo detail that carries Code and Details over gRPC
+	errorInfoDomain = "goslow"
+)
+
+// Error ... Error with the HTTP status and a stable code for clients to act on. Written as RFC 7807
+// application/problem+json by JsonMarshaller.WriteError and converted to a gRPC status when returned by a gRPC method
+type Error struct {
+	Status int
+	// Stable and machine readable, e.g. not_found. Use the ErrorCode constants or a code of the service
+	Code string
+	// Safe to show to the caller
+	Message string
+	// Extra members of the problem, e.g. the fields that failed validation
+	Details map[string]interface{}
+	// Logged but never returned to the caller
+	Cause error
+}
+
+// NewError ... Error with status, code and message
+func NewError(status int, code string, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
+	}
+
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// WithDetail ... Copy of the error with a detail added
+func (e *Error) WithDetail(key string, value interface{}) *Error {
+	copied := *e
+	copied.Details = make(map[string]interface{}, len(e.Details)+1)
+	for k, v := range e.Details {
+		copied.Details[k] = v
+	}
+	copied.Details[key] = value
+
+	return &copied
+}
+
+// WithCause ... Copy of the error with the underlying error that is logged
+func (e *Error) WithCause(err error) *Error {
+	copied := *e
+	copied.Cause = err
+
+	return &copied
+}
+
+// GRPCStatus ... Used by gRPC to send the error. Code and Details travel in an ErrorInfo detail
+func (e *Error) GRPCStatus() *status.Status {
+	s := status.New(grpcCodeFromHttpStatus(e.Status), e.Message)
+
+	metadata := make(map[string]string, len(e.Details))
+	for k, v := range e.Details {
+		if text, ok := v.(string); ok {
+			metadata[k] = text
+		} else {
+			encoded, _ := json.Marshal(v)
+			metadata[k] = string(encoded)
+		}
+	}
+
+	withDetails, err := s.WithDetails(&errdetails.ErrorInfo{Reason: e.Code, Domain: errorInfoDomain, Metadata: metadata})
+	if err != nil {
+		return s
+	}
+
+	return withDetails
+}
+
+// AsError ... The Error in the chain of err. Other errors become an internal error without their message,
+// gRPC status errors keep their code and message and database errors get the matching status
+func AsError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	platformError := &Error{}
+	if errors.As(err, &platformError) {
+		return platformError
+	}
+
+	if errors.Is(err, ErrNoEntryFoundInDB) {
+		return NewError(http.StatusNotFound, ErrorCodeNotFound, "Entry not found").WithCause(err)
+	}
+
+	conflict := &RevisionConflictError{}
+	if errors.As(err, &conflict) {
+		return NewError(http.StatusPreconditionFailed, ErrorCodePreconditionFailed, "Revision does not match").
+			WithDetail("revision", conflict.Actual).
+			WithCause(err)
+	}
+
+	if s, ok := status.FromError(err); ok {
+		return errorFromGrpcStatus(s)
+	}
+
+	return NewError(http.StatusInternalServerError, ErrorCodeInternal, "Internal server error").WithCause(err)
+}
+
+// ErrorFromGrpc ... Error of a gRPC call, with the Code and Details of the Error the server returned
+func ErrorFromGrpc(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	return errorFromGrpcStatus(status.Convert(err))
+}
+
+func errorFromGrpcStatus(s *status.Status) *Error {
+	converted := &Error{
+		Status:  httpStatusFromGrpcCode(s.Code()),
+		Code:    grpcErrorCode(s.Code()),
+		Message: s.Message(),
+	}
+
+	for _, detail := range s.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != errorInfoDomain {
+			continue
+		}
+
+		converted.Code = info.Reason
+		if len(info.Metadata) > 0 {
+			converted.Details = make(map[string]interface{}, len(info.Metadata))
+			for k, v := range info.Metadata {
+				converted.Details[k] = v
+			}
+		}
+	}
+
+	return converted
+}
+
+// Code of a status without an ErrorInfo, e.g. NotFound becomes not_found
+func grpcErrorCode(code codes.Code) string {
+	name := code.String()
+
+	var builder strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				builder.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		builder.WriteRune(r)
+	}
+
+	return builder.String()
+}
+
+// Mapping of https://github.com/googleapis/googleapis/blob/master/google/rpc/code.proto
+func httpStatusFromGrpcCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+func grpcCodeFromHttpStatus(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent:
+		return codes.OK
+	case 499:
+		return codes.Canceled
+	case http.StatusBadRequest, http.StatusUnsupportedMediaType:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed, http.StatusPreconditionRequired:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+
+	return codes.Internal
+}
+
+// RFC 7807 body. Code and Details are extension members
+type problem struct {
+	Type    string                 `json:"type"`
+	Title   string                 `json:"title"`
+	Status  int                    `json:"status"`
+	Detail  string                 `json:"detail,omitempty"`
+	Code    string                 `json:"code,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// WriteError ... Writes err as application/problem+json. Errors other than Error are written as a 500
+// without their message. Server errors are logged with their cause
+func (j *jsonMarshallerOrganizer) WriteError(w http.ResponseWriter, err error) {
+	platformError := AsError(err)
+	if platformError == nil {
+		platformError = NewError(http.StatusInternalServerError, ErrorCodeInternal, "Internal server error")
+	}
+
+	statusCode := platformError.Status
+	if statusCode < 400 || statusCode > 599 {
+		statusCode = http.StatusInternalServerError
+	}
+
+	if statusCode >= 500 {
+		Log.Error("Request failed", zap.String("code", platformError.Code), zap.Error(err))
+	}
+
+	responseData, marshalErr := json.Marshal(problem{
+		Type:    problemTypeBlank,
+		Title:   http.StatusText(statusCode),
+		Status:  statusCode,
+		Detail:  platformError.Message,
+		Code:    platformError.Code,
+		Details: platformError.Details,
+	})
+	if marshalErr != nil {
+		Log.Error("Unable to marshal error response", zap.Error(marshalErr))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	w.Write(responseData)
+}
@@ -0,0 +1,95 @@
+package platform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWriteError(t *testing.T) {
+	cases := []struct {
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{NewError(http.StatusBadRequest, "invalid_order", "Quantity must be positive").WithDetail("field", "quantity"), http.StatusBadRequest, "invalid_order", "Quantity must be positive"},
+		{fmt.Errorf("loading order: %w", ErrNoEntryFoundInDB), http.StatusNotFound, ErrorCodeNotFound, "Entry not found"},
+		{&RevisionConflictError{Bucket: "orders", Id: "1", Expected: 1, Actual: 2}, http.StatusPreconditionFailed, ErrorCodePreconditionFailed, "Revision does not match"},
+		{errRoleRequired, http.StatusForbidden, ErrorCodeForbidden, "A required role is missing"},
+		{status.Error(codes.PermissionDenied, "not yours"), http.StatusForbidden, "permission_denied", "not yours"},
+		{errors.New("connection refused to 10.0.0.1"), http.StatusInternalServerError, ErrorCodeInternal, "Internal server error"},
+	}
+
+	for _, c := range cases {
+		response := httptest.NewRecorder()
+		JsonMarshaller.WriteError(response, c.err)
+
+		if response.Code != c.status || response.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("Expected %d problem+json for %v but got %d %s", c.status, c.err, response.Code, response.Header().Get("Content-Type"))
+			continue
+		}
+
+		body := problem{}
+		err := json.Unmarshal(response.Body.Bytes(), &body)
+		if err != nil {
+			t.Fatalf("Invalid problem body %s: %v", response.Body.String(), err)
+		}
+
+		if body.Status != c.status || body.Code != c.code || body.Detail != c.message || body.Title != http.StatusText(c.status) {
+			t.Errorf("Unexpected problem %+v for %v", body, c.err)
+		}
+	}
+}
+
+func TestErrorGrpcStatus(t *testing.T) {
+	original := NewError(http.StatusConflict, "order_exists", "Order already exists").WithDetail("id", "42")
+
+	// What a client receives from a gRPC method that returned the error
+	received := status.Convert(original).Err()
+	if status.Code(received) != codes.AlreadyExists {
+		t.Fatalf("Expected AlreadyExists but got %v", status.Code(received))
+	}
+
+	converted := ErrorFromGrpc(received)
+	if converted.Status != http.StatusConflict || converted.Code != "order_exists" || converted.Message != "Order already exists" || converted.Details["id"] != "42" {
+		t.Errorf("Unexpected error %+v", converted)
+	}
+
+	converted = ErrorFromGrpc(status.Error(codes.Unauthenticated, "token expired"))
+	if converted.Status != http.StatusUnauthorized || converted.Code != "unauthenticated" {
+		t.Errorf("Unexpected error %+v", converted)
+	}
+}
+
+func TestMiddlewareProblemResponses(t *testing.T) {
+	handler := allowedContentTypeMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), "application/json")
+
+	request := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("hello"))
+	request.Header.Set("Content-Type", "text/plain")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	body := problem{}
+	json.Unmarshal(response.Body.Bytes(), &body)
+	if response.Code != http.StatusUnsupportedMediaType || body.Code != ErrorCodeUnsupportedMediaType || body.Details["allowed"] != "application/json" {
+		t.Errorf("Unexpected response %d %s", response.Code, response.Body.String())
+	}
+
+	handler = basicAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), map[string]string{})
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+	body = problem{}
+	json.Unmarshal(response.Body.Bytes(), &body)
+	if response.Code != http.StatusUnauthorized || body.Code != ErrorCodeUnauthorized {
+		t.Errorf("Unexpected response %d %s", response.Code, response.Body.String())
+	}
+}
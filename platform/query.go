@@ -0,0 +1,413 @@
+package platform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	QueryEq       = "eq"
+	QueryGt       = "gt"
+	QueryGte      = "gte"
+	QueryLt       = "lt"
+	QueryLte      = "lte"
+	QueryIn       = "in"
+	QueryContains = "contains"
+)
+
+var (
+	ErrQueryInvalidOperator = errors.New("unknown query operator")
+	ErrQueryInvalidValue    = errors.New("query value must be a JSON value")
+	ErrQueryInvalidDocument = errors.New("stored value is not a JSON document")
+)
+
+// Query ... Filters, sorting and paging over the JSON documents of a bucket.
+// Paths are dot separated, e.g. address.city or items.0.sku. Filters on an array field match if any element matches
+type Query struct {
+	Filters []QueryFilter
+	Sort    []QuerySort
+	// 0 returns all matches
+	Limit  int
+	Offset int
+}
+
+// QueryFilter ... Value of QueryIn is a slice. String values are also compared as numbers and booleans
+// so that filters parsed from query parameters match numeric and boolean fields
+type QueryFilter struct {
+	Path     string
+	Operator string
+	Value    interface{}
+}
+
+// QuerySort ... Missing values sort first, then booleans, numbers and strings
+type QuerySort struct {
+	Path       string
+	Descending bool
+}
+
+// QueryItem ... A matching document
+type QueryItem struct {
+	Id    string          `json:"id"`
+	Value json.RawMessage `json:"value"`
+}
+
+// QueryResult ... Total is the number of matches before Limit and Offset are applied
+type QueryResult struct {
+	Total int         `json:"total"`
+	Items []QueryItem `json:"items"`
+}
+
+type queryDocument struct {
+	id       string
+	value    json.RawMessage
+	document interface{}
+}
+
+func NewQuery() *Query {
+	return &Query{}
+}
+
+// Where ... Adds a filter with one of the Query operators
+func (q *Query) Where(path string, operator string, value interface{}) *Query {
+	q.Filters = append(q.Filters, QueryFilter{Path: path, Operator: operator, Value: value})
+	return q
+}
+
+func (q *Query) Eq(path string, value interface{}) *Query {
+	return q.Where(path, QueryEq, value)
+}
+
+// Range ... Inclusive range. A nil bound is open
+func (q *Query) Range(path string, min interface{}, max interface{}) *Query {
+	if min != nil {
+		q.Where(path, QueryGte, min)
+	}
+	if max != nil {
+		q.Where(path, QueryLte, max)
+	}
+	return q
+}
+
+func (q *Query) In(path string, values ...interface{}) *Query {
+	return q.Where(path, QueryIn, values)
+}
+
+// Contains ... Substring of a string field or an element of an array field
+func (q *Query) Contains(path string, value interface{}) *Query {
+	return q.Where(path, QueryContains, value)
+}
+
+func (q *Query) SortBy(path string, descending bool) *Query {
+	q.Sort = append(q.Sort, QuerySort{Path: path, Descending: descending})
+	return q
+}
+
+func (q *Query) Page(limit int, offset int) *Query {
+	q.Limit = limit
+	q.Offset = offset
+	return q
+}
+
+// RunQuery ... Runs the query over a bucket of the store. BoltDB uses the indexes created with CreateIndex,
+// other stores and buckets without a matching index are scanned
+func RunQuery(store KeyValueStore, bucket string, query *Query) (QueryResult, error) {
+	if d, ok := store.(*boltDbDatabase); ok {
+		return d.Query(bucket, query)
+	}
+
+	q, err := query.normalized()
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	documents := make([]queryDocument, 0)
+	err = store.ForEach(bucket, func(id string, value []byte) error {
+		document, matched, err := q.match(value)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", bucket, id, err)
+		}
+
+		if matched {
+			documents = append(documents, queryDocument{id: id, value: append(json.RawMessage{}, value...), document: document})
+		}
+		return nil
+	})
+	if err != nil {
+		Log.Error("Error running query", zap.String("bucket", bucket), zap.Error(err))
+		return QueryResult{}, err
+	}
+
+	return q.result(documents), nil
+}
+
+// Decode ... Unmarshals the items into out, a pointer to a slice
+func (r QueryResult) Decode(out interface{}) error {
+	buffer := bytes.Buffer{}
+	buffer.WriteByte('[')
+	for i, item := range r.Items {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		buffer.Write(item.Value)
+	}
+	buffer.WriteByte(']')
+
+	return json.Unmarshal(buffer.Bytes(), out)
+}
+
+// Copy of the query with the filter values converted to their JSON form
+func (q *Query) normalized() (*Query, error) {
+	result := &Query{Sort: q.Sort, Limit: q.Limit, Offset: q.Offset}
+
+	for _, filter := range q.Filters {
+		switch filter.Operator {
+		case QueryEq, QueryGt, QueryGte, QueryLt, QueryLte, QueryIn, QueryContains:
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrQueryInvalidOperator, filter.Operator)
+		}
+
+		data, err := json.Marshal(filter.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrQueryInvalidValue, filter.Path)
+		}
+
+		var value interface{}
+		err = json.Unmarshal(data, &value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrQueryInvalidValue, filter.Path)
+		}
+
+		if _, ok := value.([]interface{}); filter.Operator == QueryIn && !ok {
+			value = []interface{}{value}
+		}
+
+		result.Filters = append(result.Filters, QueryFilter{Path: filter.Path, Operator: filter.Operator, Value: value})
+	}
+
+	return result, nil
+}
+
+// Decodes the value and checks it against every filter
+func (q *Query) match(value []byte) (interface{}, bool, error) {
+	var document interface{}
+	err := json.Unmarshal(value, &document)
+	if err != nil {
+		return nil, false, ErrQueryInvalidDocument
+	}
+
+	for _, filter := range q.Filters {
+		if !filter.matches(document) {
+			return document, false, nil
+		}
+	}
+
+	return document, true, nil
+}
+
+// Sorts and pages the matching documents. Without sort fields they stay in key order
+func (q *Query) result(documents []queryDocument) QueryResult {
+	sort.SliceStable(documents, func(i, j int) bool {
+		for _, field := range q.Sort {
+			a, _ := queryLookup(documents[i].document, field.Path)
+			b, _ := queryLookup(documents[j].document, field.Path)
+
+			c := querySortCompare(a, b)
+			if c == 0 {
+				continue
+			}
+			if field.Descending {
+				return c > 0
+			}
+			return c < 0
+		}
+
+		return false
+	})
+
+	result := QueryResult{Total: len(documents), Items: make([]QueryItem, 0)}
+
+	start := q.Offset
+	if start < 0 {
+		start = 0
+	}
+	if start > len(documents) {
+		start = len(documents)
+	}
+
+	end := len(documents)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+
+	for _, v := range documents[start:end] {
+		result.Items = append(result.Items, QueryItem{Id: v.id, Value: v.value})
+	}
+
+	return result
+}
+
+func (f QueryFilter) matches(document interface{}) bool {
+	value, ok := queryLookup(document, f.Path)
+	if !ok {
+		return false
+	}
+
+	if f.Operator == QueryContains {
+		switch v := value.(type) {
+		case string:
+			expected, ok := f.Value.(string)
+			return ok && strings.Contains(v, expected)
+		case []interface{}:
+			for _, element := range v {
+				if c, ok := queryCompare(element, f.Value); ok && c == 0 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	if elements, ok := value.([]interface{}); ok {
+		for _, element := range elements {
+			if f.matchesValue(element) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return f.matchesValue(value)
+}
+
+func (f QueryFilter) matchesValue(value interface{}) bool {
+	if f.Operator == QueryIn {
+		for _, expected := range f.Value.([]interface{}) {
+			if c, ok := queryCompare(value, expected); ok && c == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	c, ok := queryCompare(value, f.Value)
+	if !ok {
+		return false
+	}
+
+	switch f.Operator {
+	case QueryEq:
+		return c == 0
+	case QueryGt:
+		return c > 0
+	case QueryGte:
+		return c >= 0
+	case QueryLt:
+		return c < 0
+	case QueryLte:
+		return c <= 0
+	}
+
+	return false
+}
+
+func queryLookup(document interface{}, path string) (interface{}, bool) {
+	current := document
+	for _, segment := range strings.Split(path, ".") {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			current = v[index]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// Compares a document value with a filter value. A string filter value is converted
+// when the document value is a number or boolean. ok is false if the types differ
+func queryCompare(value interface{}, expected interface{}) (int, bool) {
+	switch v := value.(type) {
+	case nil:
+		return 0, expected == nil
+	case string:
+		e, ok := expected.(string)
+		return strings.Compare(v, e), ok
+	case float64:
+		e, ok := expected.(float64)
+		if s, isString := expected.(string); isString {
+			parsed, err := strconv.ParseFloat(s, 64)
+			e, ok = parsed, err == nil
+		}
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case v < e:
+			return -1, true
+		case v > e:
+			return 1, true
+		}
+		return 0, true
+	case bool:
+		e, ok := expected.(bool)
+		if s, isString := expected.(string); isString {
+			parsed, err := strconv.ParseBool(s)
+			e, ok = parsed, err == nil
+		}
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case v == e:
+			return 0, true
+		case !v:
+			return -1, true
+		}
+		return 1, true
+	}
+
+	return 0, false
+}
+
+func querySortRank(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64:
+		return 2
+	case string:
+		return 3
+	}
+	return 4
+}
+
+func querySortCompare(a interface{}, b interface{}) int {
+	rankA := querySortRank(a)
+	rankB := querySortRank(b)
+	if rankA != rankB {
+		return rankA - rankB
+	}
+
+	c, _ := queryCompare(a, b)
+	return c
+}
@@ -0,0 +1,187 @@
+package platform
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+type queryOrder struct {
+	Id       string   `json:"id"`
+	Status   string   `json:"status"`
+	Total    float64  `json:"total"`
+	Tags     []string `json:"tags"`
+	Customer struct {
+		Name string `json:"name"`
+	} `json:"customer"`
+}
+
+func saveQueryOrders(t *testing.T, store KeyValueStore) {
+	orders := []queryOrder{
+		{Id: "1", Status: "open", Total: 10, Tags: []string{"a"}},
+		{Id: "2", Status: "closed", Total: 25, Tags: []string{"b"}},
+		{Id: "3", Status: "open", Total: 40, Tags: []string{"a", "b"}},
+		{Id: "4", Status: "open", Total: 5},
+	}
+	orders[0].Customer.Name = "Anna"
+	orders[1].Customer.Name = "Ben"
+	orders[2].Customer.Name = "Hannah"
+
+	for _, v := range orders {
+		err := store.SaveObject("orders", v.Id, v)
+		if err != nil {
+			t.Fatalf("Error saving order: %v", err)
+		}
+	}
+}
+
+func queryIds(t *testing.T, result QueryResult, err error) []string {
+	if err != nil {
+		t.Fatalf("Error running query: %v", err)
+	}
+
+	ids := make([]string, 0)
+	for _, v := range result.Items {
+		ids = append(ids, v.Id)
+	}
+	return ids
+}
+
+func expectIds(t *testing.T, name string, ids []string, expected ...string) {
+	if len(ids) != len(expected) {
+		t.Errorf("%s: expected %v but got %v", name, expected, ids)
+		return
+	}
+	for i := range ids {
+		if ids[i] != expected[i] {
+			t.Errorf("%s: expected %v but got %v", name, expected, ids)
+			return
+		}
+	}
+}
+
+func runQueryTests(t *testing.T, store KeyValueStore) {
+	result, err := RunQuery(store, "orders", NewQuery().Eq("status", "open").SortBy("total", true))
+	expectIds(t, "eq sorted", queryIds(t, result, err), "3", "1", "4")
+
+	result, err = RunQuery(store, "orders", NewQuery().Range("total", 10, 30))
+	expectIds(t, "range", queryIds(t, result, err), "1", "2")
+
+	result, err = RunQuery(store, "orders", NewQuery().In("tags", "b"))
+	expectIds(t, "in array", queryIds(t, result, err), "2", "3")
+
+	result, err = RunQuery(store, "orders", NewQuery().Contains("customer.name", "nna"))
+	expectIds(t, "contains", queryIds(t, result, err), "1", "3")
+
+	result, err = RunQuery(store, "orders", NewQuery().Where("total", QueryGt, "10").Page(1, 1))
+	expectIds(t, "string number with paging", queryIds(t, result, err), "3")
+	if result.Total != 2 {
+		t.Errorf("Expected a total of 2 but got %d", result.Total)
+	}
+
+	orders := make([]queryOrder, 0)
+	err = result.Decode(&orders)
+	if err != nil || len(orders) != 1 || orders[0].Total != 40 {
+		t.Errorf("Unexpected decoded result %+v, %v", orders, err)
+	}
+}
+
+func TestQueryMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	saveQueryOrders(t, store)
+
+	runQueryTests(t, store)
+
+	_, err := RunQuery(store, "orders", NewQuery().Where("total", "between", 1))
+	if !errors.Is(err, ErrQueryInvalidOperator) {
+		t.Errorf("Expected ErrQueryInvalidOperator but got %v", err)
+	}
+}
+
+func TestQueryBoltDBWithIndexes(t *testing.T) {
+	db := useTempBoltDB(t)
+	saveQueryOrders(t, &Database.BoltDb)
+
+	runQueryTests(t, &Database.BoltDb)
+
+	for _, path := range []string{"status", "total", "tags"} {
+		err := Database.BoltDb.CreateIndex("orders", path)
+		if err != nil {
+			t.Fatalf("Error creating index: %v", err)
+		}
+	}
+
+	runQueryTests(t, &Database.BoltDb)
+
+	candidates := func(q *Query) []string {
+		q, _ = q.normalized()
+		ids := make([]string, 0)
+		db.View(func(tx *bolt.Tx) error {
+			found, indexed := boltIndexCandidates(tx, "orders", q)
+			if !indexed {
+				t.Error("Expected the query to use an index")
+			}
+			for _, id := range found {
+				ids = append(ids, string(id))
+			}
+			return nil
+		})
+		return ids
+	}
+
+	expectIds(t, "index eq", candidates(NewQuery().Eq("status", "open")), "1", "3", "4")
+	expectIds(t, "index range", candidates(NewQuery().Where("total", QueryLt, 25)), "1", "2", "4")
+
+	// Writes keep the index up to date
+	Database.BoltDb.SaveObject("orders", "1", queryOrder{Id: "1", Status: "closed", Total: 10})
+	Database.BoltDb.RemoveObject("orders", "3")
+	expectIds(t, "index after writes", candidates(NewQuery().Eq("status", "open")), "4")
+
+	result, err := Database.BoltDb.Query("orders", NewQuery().Eq("status", "closed"))
+	expectIds(t, "query after writes", queryIds(t, result, err), "1", "2")
+
+	useBoltEncryption(t, "key1", "key1")
+	err = Database.BoltDb.CreateIndex("secrets", "name")
+	if err != ErrIndexEncryptedBucket {
+		t.Errorf("Expected ErrIndexEncryptedBucket but got %v", err)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	values, _ := url.ParseQuery("status=open&total[gte]=10&tags[in]=a,b&sort=-total,id&limit=5&offset=10")
+
+	query, err := ParseQuery(values)
+	if err != nil {
+		t.Fatalf("Error parsing query: %v", err)
+	}
+
+	if len(query.Filters) != 3 || query.Limit != 5 || query.Offset != 10 {
+		t.Fatalf("Unexpected query %+v", query)
+	}
+	if len(query.Sort) != 2 || query.Sort[0].Path != "total" || !query.Sort[0].Descending {
+		t.Errorf("Unexpected sort %+v", query.Sort)
+	}
+	if query.Filters[1].Path != "tags" || len(query.Filters[1].Value.([]interface{})) != 2 {
+		t.Errorf("Unexpected in filter %+v", query.Filters[1])
+	}
+
+	store := NewMemoryStore()
+	saveQueryOrders(t, store)
+	values, _ = url.ParseQuery("status=open&total[gte]=10&sort=-total")
+	query, _ = ParseQuery(values)
+	result, err := RunQuery(store, "orders", query)
+	expectIds(t, "parsed", queryIds(t, result, err), "3", "1")
+
+	_, err = ParseQuery(values, "status")
+	if !errors.Is(err, ErrQueryFieldNotAllowed) {
+		t.Errorf("Expected ErrQueryFieldNotAllowed but got %v", err)
+	}
+
+	values, _ = url.ParseQuery("total[between]=1")
+	_, err = ParseQuery(values)
+	if !errors.Is(err, ErrQueryInvalidOperator) {
+		t.Errorf("Expected ErrQueryInvalidOperator but got %v", err)
+	}
+}
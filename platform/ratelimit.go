@@ -0,0 +1,301 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	RateLimitKeyIP     = "ip"
+	RateLimitKeyUser   = "user"
+	RateLimitKeyAPIKey = "apikey"
+
+	rateLimitDefaultMaxKeys = 10000
+)
+
+var (
+	ErrRateLimitInvalidKey = errors.New("rate limit key must be ip, user or apikey")
+
+	errRateLimited = NewError(http.StatusTooManyRequests, ErrorCodeRateLimited, "Too many requests, retry after the time in the Retry-After header")
+)
+
+// RateLimit ... Token bucket limit. Rate tokens are added per second up to Burst and every request takes one.
+// A Rate of 0 turns limiting off, e.g. to exempt a route from the default limit
+type RateLimit struct {
+	Rate  float64
+	Burst int
+	// ip (default), user or apikey. Only identities that a middleware validated are used, requests without one
+	// are limited by ip. user takes the user from the basic, OAuth or local JWT middleware. apikey takes the key
+	// that a validating middleware in Route.Middlewares stored under ContextAPIKey, it is only safe behind one
+	Key string
+	// Overrides Key. Runs before authentication, so it must not trust identities sent by the client.
+	// Requests for which it returns "" are limited by ip
+	KeyFunc func(r *http.Request) string
+}
+
+// RateLimitDecision ... Outcome of taking a token
+type RateLimitDecision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Time until the bucket is full again
+	Reset time.Duration
+	// Time until the next token when the request was not allowed
+	RetryAfter time.Duration
+}
+
+type rateLimitBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// RateLimiter ... Token buckets by key. Buckets of keys that were not used for a while are evicted
+type RateLimiter struct {
+	rate    float64
+	burst   int
+	lock    sync.Mutex
+	buckets *Cache
+	now     func() time.Time
+}
+
+// NewRateLimiter ... Limiter that keeps the buckets of at most maxKeys keys. Uses 10000 when maxKeys is 0.
+// A rate of 0 or less allows every request
+func NewRateLimiter(rate float64, burst int, maxKeys int) *RateLimiter {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	if maxKeys < 1 {
+		maxKeys = rateLimitDefaultMaxKeys
+	}
+
+	// A bucket that was not used for this long is full again, so forgetting it changes nothing
+	idle := time.Duration(0)
+	if rate > 0 {
+		idle = time.Duration(float64(burst)/rate*float64(time.Second)) + time.Second
+	}
+
+	return &RateLimiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: NewCache(maxKeys, idle),
+		now:     time.Now,
+	}
+}
+
+// Allow ... Takes a token from the bucket of key
+func (l *RateLimiter) Allow(key string) RateLimitDecision {
+	if l.rate <= 0 {
+		return RateLimitDecision{Allowed: true, Limit: l.burst, Remaining: l.burst}
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+
+	bucket := &rateLimitBucket{tokens: float64(l.burst), updated: now}
+	if cached, ok := l.buckets.Get(key); ok {
+		bucket = cached.(*rateLimitBucket)
+		bucket.tokens = math.Min(float64(l.burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate)
+		bucket.updated = now
+	}
+
+	decision := RateLimitDecision{Limit: l.burst}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = l.duration(1 - bucket.tokens)
+	}
+
+	decision.Remaining = int(bucket.tokens)
+	decision.Reset = l.duration(float64(l.burst) - bucket.tokens)
+
+	l.buckets.Set(key, bucket)
+
+	return decision
+}
+
+func (l *RateLimiter) duration(tokens float64) time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// Seconds rounded up so that a client waiting that long is allowed
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+func writeRateLimitHeaders(header http.Header, decision RateLimitDecision) {
+	header.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	header.Set("RateLimit-Reset", ceilSeconds(decision.Reset))
+
+	if !decision.Allowed {
+		header.Set("Retry-After", ceilSeconds(decision.RetryAfter))
+	}
+}
+
+// Client address, taking the first X-Forwarded-For entry when the proxy in front is trusted
+func rateLimitClientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		forwarded := r.Header.Get("X-Forwarded-For")
+		if len(forwarded) > 0 {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// Name of the user that an auth middleware validated. Credentials the client sent are never used as they are,
+// otherwise a client could get a new bucket by sending another name with every request
+func rateLimitUser(r *http.Request) string {
+	if username, ok := r.Context().Value(ContextOAuthPreferredUsername).(string); ok && len(username) > 0 {
+		return username
+	}
+
+	if claims, ok := r.Context().Value(ContextLocalJwtClaims).(map[string]interface{}); ok {
+		for _, claim := range []string{"sub", "username"} {
+			if value, ok := claims[claim].(string); ok && len(value) > 0 {
+				return value
+			}
+		}
+	}
+
+	if username, ok := r.Context().Value(ContextBasicAuthUsername).(string); ok && len(username) > 0 {
+		return username
+	}
+
+	return ""
+}
+
+// Limits keyed by an identity run after the middleware that validates it
+func (limit RateLimit) keyedByIdentity() bool {
+	return limit.KeyFunc == nil && (limit.Key == RateLimitKeyUser || limit.Key == RateLimitKeyAPIKey)
+}
+
+func (limit RateLimit) validate() error {
+	switch limit.Key {
+	case "", RateLimitKeyIP, RateLimitKeyUser, RateLimitKeyAPIKey:
+		return nil
+	}
+
+	return ErrRateLimitInvalidKey
+}
+
+func (limit RateLimit) keyOf(r *http.Request, trustForwardedFor bool) string {
+	kind := limit.Key
+	key := ""
+
+	switch {
+	case limit.KeyFunc != nil:
+		kind = "custom"
+		key = limit.KeyFunc(r)
+	case limit.Key == RateLimitKeyUser:
+		key = rateLimitUser(r)
+	case limit.Key == RateLimitKeyAPIKey:
+		key, _ = r.Context().Value(ContextAPIKey).(string)
+	}
+
+	if len(key) > 0 {
+		return kind + ":" + key
+	}
+
+	return RateLimitKeyIP + ":" + rateLimitClientIP(r, trustForwardedFor)
+}
+
+// Limit of the route, or the configured default when the route has none. nil when the route is not limited
+func routeRateLimit(route Route, config *Config) *RateLimit {
+	if route.RateLimit != nil {
+		if route.RateLimit.Rate <= 0 {
+			return nil
+		}
+		return route.RateLimit
+	}
+
+	defaults := config.HTTP.Server.RateLimit
+	if !defaults.Enabled || defaults.Rate <= 0 {
+		return nil
+	}
+
+	return &RateLimit{
+		Rate:  defaults.Rate,
+		Burst: defaults.Burst,
+		Key:   defaults.Key,
+	}
+}
+
+// Each route has buckets of its own so that a busy route does not use up the tokens of another
+func rateLimitMiddleware(next http.Handler, routePath string, limit *RateLimit, config *Config) http.Handler {
+	limiter := NewRateLimiter(limit.Rate, limit.Burst, config.HTTP.Server.RateLimit.MaxKeys)
+	trustForwardedFor := config.HTTP.Server.RateLimit.TrustForwardedFor
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := limit.keyOf(r, trustForwardedFor)
+		decision := limiter.Allow(key)
+
+		writeRateLimitHeaders(w.Header(), decision)
+
+		if !decision.Allowed {
+			Log.Warn("Rate limit exceeded", zap.String("route", routePath), zap.String("key", key))
+			JsonMarshaller.WriteError(w, errRateLimited)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RateLimitUnaryInterceptor ... gRPC interceptor that limits each method with a bucket of its own.
+// Calls over the limit fail with ResourceExhausted and a retry-after header
+func RateLimitUnaryInterceptor(rate float64, burst int) grpc.UnaryServerInterceptor {
+	limiter := NewRateLimiter(rate, burst, 0)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		decision := limiter.Allow(info.FullMethod)
+		if !decision.Allowed {
+			Log.Warn("Rate limit exceeded", zap.String("method", info.FullMethod))
+			grpc.SetHeader(ctx, metadata.Pairs("retry-after", ceilSeconds(decision.RetryAfter)))
+			return nil, errRateLimited.WithDetail("method", info.FullMethod)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// RateLimitStreamInterceptor ... Same as RateLimitUnaryInterceptor for streaming methods.
+// Opening a stream takes a token, the messages on it do not
+func RateLimitStreamInterceptor(rate float64, burst int) grpc.StreamServerInterceptor {
+	limiter := NewRateLimiter(rate, burst, 0)
+
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		decision := limiter.Allow(info.FullMethod)
+		if !decision.Allowed {
+			Log.Warn("Rate limit exceeded", zap.String("method", info.FullMethod))
+			stream.SetHeader(metadata.Pairs("retry-after", ceilSeconds(decision.RetryAfter)))
+			return errRateLimited.WithDetail("method", info.FullMethod)
+		}
+
+		return handler(srv, stream)
+	}
+}
@@ -0,0 +1,227 @@
+package platform
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(2, 3, 0)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("a").Allowed {
+			t.Fatalf("Expected request %d to be allowed", i)
+		}
+	}
+
+	decision := limiter.Allow("a")
+	if decision.Allowed || decision.Remaining != 0 || decision.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Unexpected decision %+v", decision)
+	}
+
+	if !limiter.Allow("b").Allowed {
+		t.Error("Expected another key to have a bucket of its own")
+	}
+
+	now = now.Add(time.Second)
+	decision = limiter.Allow("a")
+	if !decision.Allowed || decision.Remaining != 1 {
+		t.Errorf("Expected two tokens to be refilled but got %+v", decision)
+	}
+}
+
+func TestRateLimitRoutes(t *testing.T) {
+	routes := Routes{
+		Route{
+			Path:        "/login",
+			Method:      http.MethodPost,
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {},
+			RateLimit:   &RateLimit{Rate: 0.1, Burst: 2},
+		},
+		Route{
+			Path:        "/keys",
+			Method:      http.MethodGet,
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {},
+			RateLimit:   &RateLimit{Rate: 0.1, Burst: 1, Key: RateLimitKeyAPIKey},
+			Middlewares: []func(http.Handler) http.Handler{validateTestAPIKey},
+		},
+		Route{
+			Path:        "/unvalidated",
+			Method:      http.MethodGet,
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {},
+			RateLimit:   &RateLimit{Rate: 0.1, Burst: 2, Key: RateLimitKeyAPIKey},
+		},
+		Route{
+			Path:        "/users",
+			Method:      http.MethodGet,
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {},
+			RateLimit:   &RateLimit{Rate: 0.1, Burst: 2, Key: RateLimitKeyUser},
+		},
+		Route{
+			Path:        "/open",
+			Method:      http.MethodGet,
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {},
+		},
+	}
+
+	router, err := newRouter(routes)
+	if err != nil {
+		t.Fatalf("Error creating router: %v", err)
+	}
+
+	call := func(method string, path string, apiKey string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, nil)
+		request.Header.Set("X-API-Key", apiKey)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	call(http.MethodPost, "/login", "")
+	call(http.MethodPost, "/login", "")
+	response := call(http.MethodPost, "/login", "")
+	if response.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 but got %d", response.Code)
+	}
+	if response.Header().Get("Retry-After") != "10" || response.Header().Get("RateLimit-Limit") != "2" || response.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Unexpected headers %v", response.Header())
+	}
+
+	if call(http.MethodGet, "/keys", "a").Code != http.StatusOK || call(http.MethodGet, "/keys", "b").Code != http.StatusOK {
+		t.Error("Expected each API key to have a bucket of its own")
+	}
+	if call(http.MethodGet, "/keys", "a").Code != http.StatusTooManyRequests {
+		t.Error("Expected the second request with key a to be limited")
+	}
+
+	if call(http.MethodGet, "/keys", "c").Code != http.StatusUnauthorized {
+		t.Error("Expected an unknown API key to be rejected by the validating middleware")
+	}
+
+	// Without a validating middleware the header is ignored, so a new key per request does not give a new bucket
+	for i, key := range []string{"x1", "x2", "x3"} {
+		code := call(http.MethodGet, "/unvalidated", key).Code
+		if (i < 2 && code != http.StatusOK) || (i == 2 && code != http.StatusTooManyRequests) {
+			t.Errorf("Unexpected status %d for request %d with a rotated API key", code, i)
+		}
+	}
+
+	// Basic auth credentials are not checked on this route, so the username is not used either
+	for i, username := range []string{"u1", "u2", "u3"} {
+		request := httptest.NewRequest(http.MethodGet, "/users", nil)
+		request.SetBasicAuth(username, "secret")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		if (i < 2 && response.Code != http.StatusOK) || (i == 2 && response.Code != http.StatusTooManyRequests) {
+			t.Errorf("Unexpected status %d for request %d with a rotated username", response.Code, i)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		if call(http.MethodGet, "/open", "").Code != http.StatusOK {
+			t.Fatal("Expected routes without a limit not to be limited")
+		}
+	}
+
+	_, err = newRouter(Routes{Route{Path: "/x", Method: http.MethodGet, HandlerFunc: func(w http.ResponseWriter, r *http.Request) {},
+		RateLimit: &RateLimit{Rate: 1, Key: "session"}}})
+	if err != ErrRateLimitInvalidKey {
+		t.Errorf("Expected ErrRateLimitInvalidKey but got %v", err)
+	}
+}
+
+// Accepts the keys a and b
+func validateTestAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if key != "a" && key != "b" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ContextAPIKey, key)))
+	})
+}
+
+func TestRateLimitUnaryInterceptor(t *testing.T) {
+	interceptor := RateLimitUnaryInterceptor(0.1, 1)
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/A"}, handler)
+	if err != nil {
+		t.Fatalf("Expected the first call to be allowed but got %v", err)
+	}
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/B"}, handler)
+	if err != nil {
+		t.Errorf("Expected another method to have a bucket of its own but got %v", err)
+	}
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/A"}, handler)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Expected ResourceExhausted but got %v", err)
+	}
+}
+
+type headerServerStream struct {
+	testServerStream
+	header metadata.MD
+}
+
+func (s *headerServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestRateLimitStreamInterceptor(t *testing.T) {
+	interceptor := RateLimitStreamInterceptor(0.1, 1)
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}
+	handler := func(srv any, stream grpc.ServerStream) error {
+		return nil
+	}
+
+	stream := &headerServerStream{testServerStream: testServerStream{ctx: context.Background()}}
+	err := interceptor(nil, stream, info, handler)
+	if err != nil {
+		t.Fatalf("Expected the first stream to be allowed but got %v", err)
+	}
+
+	err = interceptor(nil, stream, info, handler)
+	if status.Code(err) != codes.ResourceExhausted || len(stream.header.Get("retry-after")) != 1 {
+		t.Errorf("Expected ResourceExhausted with retry-after but got %v %v", err, stream.header)
+	}
+}
+
+func TestRateLimiterZeroRate(t *testing.T) {
+	limiter := NewRateLimiter(0, 1, 0)
+
+	for i := 0; i < 5; i++ {
+		decision := limiter.Allow("a")
+		if !decision.Allowed || decision.Reset < 0 || decision.RetryAfter < 0 {
+			t.Fatalf("Expected a rate of 0 to allow every request but got %+v", decision)
+		}
+	}
+
+	interceptor := RateLimitUnaryInterceptor(0, 0)
+	for i := 0; i < 5; i++ {
+		_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/A"},
+			func(ctx context.Context, req any) (any, error) {
+				return "ok", nil
+			})
+		if err != nil {
+			t.Fatalf("Expected a rate of 0 to allow every call but got %v", err)
+		}
+	}
+}
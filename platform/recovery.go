@@ -0,0 +1,126 @@
+package platform
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+const (
+	recoveryServerHttp = "http"
+	recoveryServerGrpc = "grpc"
+
+	recoveryErrorMessage = "Internal server error"
+)
+
+var (
+	panicHookLock sync.RWMutex
+	panicHook     PanicHook
+
+	errPanicRecovered = NewError(http.StatusInternalServerError, ErrorCodeInternal, recoveryErrorMessage)
+)
+
+// PanicHook ... Called after a panic was recovered and logged, e.g. to report it to an error tracker.
+// route is the route template for HTTP and the full method for gRPC
+type PanicHook func(ctx context.Context, route string, recovered any, stack []byte)
+
+// SetPanicHook ... Replaces the hook. nil removes it
+func SetPanicHook(hook PanicHook) {
+	panicHookLock.Lock()
+	defer panicHookLock.Unlock()
+
+	panicHook = hook
+}
+
+// Logs, counts and reports a panic. The value and stack are only logged, never returned to the caller
+func handlePanic(ctx context.Context, server string, route string, recovered any) {
+	stack := debug.Stack()
+
+	fields := append([]zap.Field{
+		zap.String("server", server),
+		zap.String("route", route),
+		zap.Any("panic", recovered),
+		zap.ByteString("stack", stack),
+	}, traceLogFields(ctx)...)
+	Log.Error("Recovered from panic", fields...)
+
+	panicsRecoveredTotal.WithLabelValues(server, route).Inc()
+
+	panicHookLock.RLock()
+	hook := panicHook
+	panicHookLock.RUnlock()
+
+	if hook != nil {
+		// A failing hook must not take the server down either
+		defer func() {
+			if hookPanic := recover(); hookPanic != nil {
+				Log.Error("Panic hook panicked", zap.Any("panic", hookPanic))
+			}
+		}()
+		hook(ctx, route, recovered, stack)
+	}
+}
+
+// Turns a panic in the handler or in the platform middleware into a 500. Nothing is written when the response was already started
+func recoveryMiddleware(next http.Handler, routePath string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		wrapped := wrapResponseWriter(w)
+
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			// Used by handlers to abort a response on purpose
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			handlePanic(r.Context(), recoveryServerHttp, routePath, recovered)
+
+			if !wrapped.wroteHeader {
+				JsonMarshaller.WriteError(wrapped, errPanicRecovered)
+			}
+
+			// The panic skipped the logging middleware, so the request is counted here
+			observeHttpRequest(routePath, r.Method, wrapped.Status(), time.Since(start), 0)
+		}()
+
+		next.ServeHTTP(wrapped, r)
+	})
+}
+
+// RecoveryUnaryInterceptor ... Turns a panic in a gRPC method into codes.Internal
+func RecoveryUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				handlePanic(ctx, recoveryServerGrpc, info.FullMethod, recovered)
+				err = errPanicRecovered
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor ... Turns a panic in a streaming gRPC method into codes.Internal
+func RecoveryStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				handlePanic(stream.Context(), recoveryServerGrpc, info.FullMethod, recovered)
+				err = errPanicRecovered
+			}
+		}()
+
+		return handler(srv, stream)
+	}
+}
@@ -0,0 +1,139 @@
+package platform
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	reported := ""
+	SetPanicHook(func(ctx context.Context, route string, recovered any, stack []byte) {
+		reported = route
+	})
+	t.Cleanup(func() {
+		SetPanicHook(nil)
+	})
+
+	routes := Routes{
+		Route{
+			Path:   "/panic",
+			Method: http.MethodGet,
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				panic("database password is hunter2")
+			},
+		},
+		Route{
+			Path:   "/partial",
+			Method: http.MethodGet,
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("after the header")
+			},
+		},
+	}
+
+	router, err := newRouter(routes)
+	if err != nil {
+		t.Fatalf("Error creating router: %v", err)
+	}
+
+	before := testutil.ToFloat64(panicsRecoveredTotal.WithLabelValues(recoveryServerHttp, "/panic"))
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if response.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 but got %d", response.Code)
+	}
+	if strings.Contains(response.Body.String(), "hunter2") || !strings.Contains(response.Body.String(), recoveryErrorMessage) {
+		t.Errorf("Unexpected body %s", response.Body.String())
+	}
+	if reported != "/panic" {
+		t.Errorf("Expected the hook to be called for /panic but got %q", reported)
+	}
+	if testutil.ToFloat64(panicsRecoveredTotal.WithLabelValues(recoveryServerHttp, "/panic")) != before+1 {
+		t.Error("Expected the panic to be counted")
+	}
+
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/partial", nil))
+
+	if response.Code != http.StatusAccepted || response.Body.Len() > 0 {
+		t.Errorf("Expected the started response to be left alone but got %d %s", response.Code, response.Body.String())
+	}
+}
+
+func TestRecoveryInterceptors(t *testing.T) {
+	SetPanicHook(func(ctx context.Context, route string, recovered any, stack []byte) {
+		panic("the hook fails too")
+	})
+	t.Cleanup(func() {
+		SetPanicHook(nil)
+	})
+
+	_, err := RecoveryUnaryInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Unary"},
+		func(ctx context.Context, req any) (any, error) {
+			panic("unary")
+		})
+	if status.Code(err) != codes.Internal || status.Convert(err).Message() != recoveryErrorMessage {
+		t.Errorf("Expected Internal but got %v", err)
+	}
+
+	err = RecoveryStreamInterceptor()(nil, &testServerStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"},
+		func(srv any, stream grpc.ServerStream) error {
+			panic("stream")
+		})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("Expected Internal but got %v", err)
+	}
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestShortAuthorizationHeader(t *testing.T) {
+	previous := internalConfig.Auth.Server.LocalJwt
+	configurePlatformForLocalJwtTests()
+	t.Cleanup(func() {
+		internalConfig.Auth.Server.LocalJwt = previous
+	})
+
+	routes := Routes{
+		Route{
+			Path:         "/private",
+			Method:       http.MethodGet,
+			HandlerFunc:  func(w http.ResponseWriter, r *http.Request) {},
+			AuthRequired: true,
+		},
+	}
+
+	router, err := newRouter(routes)
+	if err != nil {
+		t.Fatalf("Error creating router: %v", err)
+	}
+
+	for _, header := range []string{"x", "Bearer", "Basic dXNlcjpwYXNz"} {
+		request := httptest.NewRequest(http.MethodGet, "/private", nil)
+		request.Header.Set("Authorization", header)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+
+		if response.Code != http.StatusUnauthorized || response.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("Expected a 401 problem response for %q but got %d %s", header, response.Code, response.Body.String())
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package platform
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RouteGroup ... Routes under a shared path prefix with shared settings. A route keeps its own
+// SlaMs, RolesRequired, AllowedContentType, RateLimit and Cors when it sets them and gets the ones of the group otherwise.
+// AuthRequired is true when either the group or the route requires it.
+//
+// Flatten the group and pass the routes to StartHttpServer. The routes are registered with their full path,
+// so logs and metrics are labelled with e.g. /api/v1/orders/{id}
+type RouteGroup struct {
+	// Added in front of the path of every route and nested group, e.g. /api/v1
+	PathPrefix         string
+	AuthRequired       bool
+	RolesRequired      []string
+	SlaMs              int64
+	AllowedContentType string
+	RateLimit          *RateLimit
+	Cors               *CorsPolicy
+	// Run around the middlewares of the routes, see Route.Middlewares for the order
+	Middlewares []func(http.Handler) http.Handler
+
+	Routes Routes
+	Groups []RouteGroup
+}
+
+// Flatten ... Routes of the group and its nested groups with the group settings applied
+func (g RouteGroup) Flatten() Routes {
+	routes := make(Routes, 0, len(g.Routes))
+
+	for _, route := range g.Routes {
+		routes = append(routes, g.apply(route))
+	}
+
+	for _, group := range g.Groups {
+		for _, route := range group.Flatten() {
+			routes = append(routes, g.apply(route))
+		}
+	}
+
+	return routes
+}
+
+func (g RouteGroup) apply(route Route) Route {
+	route.Path = joinRoutePath(g.PathPrefix, route.Path)
+	route.AuthRequired = route.AuthRequired || g.AuthRequired
+
+	if len(route.RolesRequired) < 1 {
+		route.RolesRequired = g.RolesRequired
+	}
+	if route.SlaMs == 0 {
+		route.SlaMs = g.SlaMs
+	}
+	if len(route.AllowedContentType) < 1 {
+		route.AllowedContentType = g.AllowedContentType
+	}
+	if route.RateLimit == nil {
+		route.RateLimit = g.RateLimit
+	}
+	if route.Cors == nil {
+		route.Cors = g.Cors
+	}
+
+	// Group middlewares come first so that they wrap the ones of the route
+	if len(g.Middlewares) > 0 {
+		route.Middlewares = append(append([]func(http.Handler) http.Handler{}, g.Middlewares...), route.Middlewares...)
+	}
+
+	return route
+}
+
+func joinRoutePath(prefix string, path string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if len(prefix) < 1 {
+		return path
+	}
+
+	if len(path) < 1 {
+		return prefix
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return prefix + path
+}
+
+// Wraps the handler with the middlewares so that the first one runs first
+func applyRouteMiddlewares(handler http.Handler, middlewares []func(http.Handler) http.Handler) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
@@ -0,0 +1,148 @@
+package platform
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouteGroupFlatten(t *testing.T) {
+	group := RouteGroup{
+		PathPrefix:    "/api/",
+		AuthRequired:  true,
+		RolesRequired: []string{"reader"},
+		SlaMs:         100,
+		Routes: Routes{
+			Route{Path: "/orders", Method: http.MethodGet},
+			Route{Path: "orders/{id}", Method: http.MethodDelete, RolesRequired: []string{"admin"}, SlaMs: 50},
+		},
+		Groups: []RouteGroup{
+			{
+				PathPrefix: "/v2",
+				Routes: Routes{
+					Route{Path: "/orders", Method: http.MethodGet},
+				},
+			},
+		},
+	}
+
+	routes := group.Flatten()
+	if len(routes) != 3 {
+		t.Fatalf("Expected 3 routes but got %d", len(routes))
+	}
+
+	expected := []struct {
+		path  string
+		roles []string
+		sla   int64
+	}{
+		{"/api/orders", []string{"reader"}, 100},
+		{"/api/orders/{id}", []string{"admin"}, 50},
+		{"/api/v2/orders", []string{"reader"}, 100},
+	}
+
+	for i, e := range expected {
+		route := routes[i]
+		if route.Path != e.path || !reflect.DeepEqual(route.RolesRequired, e.roles) || route.SlaMs != e.sla || !route.AuthRequired {
+			t.Errorf("Unexpected route %d: %+v", i, route)
+		}
+	}
+}
+
+func TestRouteMiddlewares(t *testing.T) {
+	order := make([]string, 0)
+	record := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	group := RouteGroup{
+		PathPrefix:  "/admin",
+		Middlewares: []func(http.Handler) http.Handler{record("group")},
+		Routes: Routes{
+			Route{
+				Path:        "/reports",
+				Method:      http.MethodGet,
+				Middlewares: []func(http.Handler) http.Handler{record("route1"), record("route2")},
+				HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+					order = append(order, "handler")
+				},
+			},
+			Route{
+				Path:   "/fail",
+				Method: http.MethodGet,
+				Middlewares: []func(http.Handler) http.Handler{func(next http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						panic("middleware failed")
+					})
+				}},
+				HandlerFunc: func(w http.ResponseWriter, r *http.Request) {},
+			},
+		},
+	}
+
+	router, err := newRouter(group.Flatten())
+	if err != nil {
+		t.Fatalf("Error creating router: %v", err)
+	}
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/admin/reports", nil))
+
+	if strings.Join(order, ",") != "group,route1,route2,handler" {
+		t.Errorf("Unexpected order %v", order)
+	}
+
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/admin/fail", nil))
+
+	if response.Code != http.StatusInternalServerError {
+		t.Errorf("Expected a panic in a route middleware to be recovered but got %d", response.Code)
+	}
+}
+
+func TestRouteGroupAllowedContentType(t *testing.T) {
+	group := RouteGroup{
+		PathPrefix:         "/api",
+		AllowedContentType: "application/json",
+		Routes: Routes{
+			Route{Path: "/orders", Method: http.MethodPost, HandlerFunc: func(w http.ResponseWriter, r *http.Request) {}},
+			Route{Path: "/orders", Method: http.MethodGet, HandlerFunc: func(w http.ResponseWriter, r *http.Request) {}},
+		},
+	}
+
+	router, err := newRouter(group.Flatten())
+	if err != nil {
+		t.Fatalf("Error creating router: %v", err)
+	}
+
+	tests := []struct {
+		method      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{http.MethodPost, "text/plain", "hello", http.StatusUnsupportedMediaType},
+		{http.MethodPost, "application/json; charset=utf-8", "{}", http.StatusOK},
+		{http.MethodGet, "", "", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest(test.method, "/api/orders", strings.NewReader(test.body))
+		if len(test.contentType) > 0 {
+			request.Header.Set("Content-Type", test.contentType)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+
+		if response.Code != test.status {
+			t.Errorf("Expected %d for %s %q but got %d", test.status, test.method, test.contentType, response.Code)
+		}
+	}
+}
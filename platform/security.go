@@ -0,0 +1,258 @@
+package platform
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Placeholder in ContentSecurityPolicy that is replaced with the nonce of the request
+	CspNoncePlaceholder = "{nonce}"
+
+	securityDefaultHSTSMaxAge      = 31536000
+	securityDefaultReferrerPolicy  = "strict-origin-when-cross-origin"
+	securityDefaultFrameAncestors  = "'self'"
+	httpsRedirectDefaultListenAddr = ":80"
+)
+
+var (
+	ErrTLSVersionInvalid     = errors.New("TLS minimum version must be 1.0, 1.1, 1.2 or 1.3")
+	ErrTLSCipherSuiteUnknown = errors.New("unknown TLS cipher suite")
+)
+
+type cspNonceKey struct{}
+
+type securityHeadersConfig struct {
+	Enabled bool
+	// Seconds. Defaults to one year. Only sent over TLS
+	HSTSMaxAgeSeconds     int
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// Not sent when empty. {nonce} is replaced with the nonce of the request, e.g. script-src 'self' 'nonce-{nonce}'
+	ContentSecurityPolicy string
+	// Defaults to strict-origin-when-cross-origin
+	ReferrerPolicy string
+	// Sources allowed to frame the pages, added to the Content-Security-Policy. Defaults to 'self'
+	FrameAncestors string
+	// Not sent when empty, e.g. camera=(), microphone=(), geolocation=()
+	PermissionsPolicy string
+}
+
+type httpsRedirectConfig struct {
+	Enabled bool
+	// Plain HTTP address that redirects to the TLS listener. Defaults to :80
+	ListeningAddress string
+}
+
+// CspNonce ... Nonce of the request for inline scripts and styles in templates, e.g. <script nonce="{{.Nonce}}">.
+// Empty when the Content-Security-Policy has no {nonce} placeholder
+func CspNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	return nonce
+}
+
+func newCspNonce() (string, error) {
+	nonce := make([]byte, 16)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(nonce), nil
+}
+
+func hstsHeader(config securityHeadersConfig) string {
+	maxAge := config.HSTSMaxAgeSeconds
+	if maxAge <= 0 {
+		maxAge = securityDefaultHSTSMaxAge
+	}
+
+	value := "max-age=" + strconv.Itoa(maxAge)
+	if config.HSTSIncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if config.HSTSPreload {
+		value += "; preload"
+	}
+
+	return value
+}
+
+// Content-Security-Policy with frame-ancestors added unless the policy sets it already
+func contentSecurityPolicy(config securityHeadersConfig) string {
+	frameAncestors := config.FrameAncestors
+	if len(frameAncestors) < 1 {
+		frameAncestors = securityDefaultFrameAncestors
+	}
+
+	policy := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(config.ContentSecurityPolicy), ";"))
+	if strings.Contains(policy, "frame-ancestors") {
+		return policy
+	}
+
+	if len(policy) < 1 {
+		return "frame-ancestors " + frameAncestors
+	}
+
+	return policy + "; frame-ancestors " + frameAncestors
+}
+
+// X-Frame-Options for browsers without frame-ancestors support. Only the values it can express are sent
+func frameOptionsHeader(config securityHeadersConfig) string {
+	switch config.FrameAncestors {
+	case "", "'self'":
+		return "SAMEORIGIN"
+	case "'none'":
+		return "DENY"
+	}
+
+	return ""
+}
+
+// Adds the security headers to every response, including the static web assets
+func securityHeadersMiddleware(next http.Handler, config *Config) http.Handler {
+	headers := config.HTTP.Server.SecurityHeaders
+
+	hsts := hstsHeader(headers)
+	csp := contentSecurityPolicy(headers)
+	useNonce := strings.Contains(csp, CspNoncePlaceholder)
+	frameOptions := frameOptionsHeader(headers)
+
+	referrerPolicy := headers.ReferrerPolicy
+	if len(referrerPolicy) < 1 {
+		referrerPolicy = securityDefaultReferrerPolicy
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+
+		if r.TLS != nil {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+
+		if useNonce {
+			nonce, err := newCspNonce()
+			if err != nil {
+				Log.Error("Error creating CSP nonce", zap.Error(err))
+				JsonMarshaller.WriteError(w, err)
+				return
+			}
+
+			header.Set("Content-Security-Policy", strings.ReplaceAll(csp, CspNoncePlaceholder, nonce))
+			r = r.WithContext(context.WithValue(r.Context(), cspNonceKey{}, nonce))
+		} else {
+			header.Set("Content-Security-Policy", csp)
+		}
+
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", referrerPolicy)
+		if len(frameOptions) > 0 {
+			header.Set("X-Frame-Options", frameOptions)
+		}
+		if len(headers.PermissionsPolicy) > 0 {
+			header.Set("Permissions-Policy", headers.PermissionsPolicy)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func tlsVersion(version string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(version), "tls") {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	}
+
+	return 0, fmt.Errorf("%w: %s", ErrTLSVersionInvalid, version)
+}
+
+// Cipher suites by their Go names, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. TLS 1.3 suites are not configurable
+func tlsCipherSuites(names []string) ([]uint16, error) {
+	if len(names) < 1 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	suites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrTLSCipherSuiteUnknown, name)
+		}
+		suites = append(suites, id)
+	}
+
+	return suites, nil
+}
+
+// TLS settings of platform.http.server or platform.grpc.server. Defaults to TLS 1.2 and the Go cipher suites
+func newTLSConfig(version string, cipherSuites []string) (*tls.Config, error) {
+	minVersion, err := tlsVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	suites, err := tlsCipherSuites(cipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:   minVersion,
+		CipherSuites: suites,
+	}, nil
+}
+
+// Redirects plain HTTP requests to the TLS listener with the same host, path and query
+func httpsRedirectHandler(tlsListeningAddress string) http.Handler {
+	_, port, _ := net.SplitHostPort(tlsListeningAddress)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if len(port) > 0 && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+func newHttpsRedirectServer(config *Config) *http.Server {
+	address := config.HTTP.Server.HTTPSRedirect.ListeningAddress
+	if len(address) < 1 {
+		address = httpsRedirectDefaultListenAddr
+	}
+
+	return &http.Server{
+		Addr:              address,
+		Handler:           httpsRedirectHandler(config.HTTP.Server.ListeningAddress),
+		ReadHeaderTimeout: httpServerDefaultReadHeaderTimeout,
+		IdleTimeout:       httpServerDefaultIdleTimeout,
+	}
+}
@@ -0,0 +1,97 @@
+package platform
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	config := &Config{}
+	config.HTTP.Server.SecurityHeaders = securityHeadersConfig{
+		Enabled:               true,
+		HSTSMaxAgeSeconds:     600,
+		HSTSIncludeSubdomains: true,
+		ContentSecurityPolicy: "script-src 'self' 'nonce-{nonce}';",
+		PermissionsPolicy:     "camera=()",
+	}
+
+	nonce := ""
+	handler := securityHeadersMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = CspNonce(r.Context())
+	}), config)
+
+	request := httptest.NewRequest(http.MethodGet, "https://localhost/index.html", nil)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if len(nonce) < 1 {
+		t.Fatal("Expected a nonce in the request context")
+	}
+
+	expected := map[string]string{
+		"Strict-Transport-Security": "max-age=600; includeSubDomains",
+		"Content-Security-Policy":   "script-src 'self' 'nonce-" + nonce + "'; frame-ancestors 'self'",
+		"X-Content-Type-Options":    "nosniff",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"X-Frame-Options":           "SAMEORIGIN",
+		"Permissions-Policy":        "camera=()",
+	}
+	for name, value := range expected {
+		if response.Header().Get(name) != value {
+			t.Errorf("Expected %s to be %q but got %q", name, value, response.Header().Get(name))
+		}
+	}
+
+	previous := nonce
+	request = httptest.NewRequest(http.MethodGet, "http://localhost/index.html", nil)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if nonce == previous {
+		t.Error("Expected a new nonce for every request")
+	}
+	if len(response.Header().Get("Strict-Transport-Security")) > 0 {
+		t.Error("Expected no HSTS header without TLS")
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	tlsConfig, err := newTLSConfig("1.3", []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"})
+	if err != nil {
+		t.Fatalf("Error creating TLS config: %v", err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS13 || len(tlsConfig.CipherSuites) != 1 || tlsConfig.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("Unexpected TLS config %+v", tlsConfig)
+	}
+
+	_, err = newTLSConfig("1.4", nil)
+	if !errors.Is(err, ErrTLSVersionInvalid) {
+		t.Errorf("Expected ErrTLSVersionInvalid but got %v", err)
+	}
+
+	_, err = newTLSConfig("", []string{"TLS_NOT_A_SUITE"})
+	if !errors.Is(err, ErrTLSCipherSuiteUnknown) {
+		t.Errorf("Expected ErrTLSCipherSuiteUnknown but got %v", err)
+	}
+}
+
+func TestHttpsRedirectHandler(t *testing.T) {
+	redirects := map[string]string{
+		"0.0.0.0:443":  "https://example.com/items?page=2",
+		"0.0.0.0:9443": "https://example.com:9443/items?page=2",
+	}
+
+	for listeningAddress, location := range redirects {
+		request := httptest.NewRequest(http.MethodGet, "http://example.com:8080/items?page=2", nil)
+		response := httptest.NewRecorder()
+		httpsRedirectHandler(listeningAddress).ServeHTTP(response, request)
+
+		if response.Code != http.StatusPermanentRedirect || !strings.EqualFold(response.Header().Get("Location"), location) {
+			t.Errorf("Expected a redirect to %s but got %d %s", location, response.Code, response.Header().Get("Location"))
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package platform
+
+import (
+	"go.uber.org/zap"
+)
+
+// Releases the platform resources once the servers have stopped. Databases are closed first
+// so that no writes are lost, spans are flushed and the log is synced last so that the shutdown itself is recorded
+func shutdownPlatform() {
+	Log.Info("Shutting down platform")
+
+	err := Database.CloseBolt()
+	if err != nil {
+		Log.Error("Error closing BoltDB", zap.Error(err))
+	}
+
+	// The built-in BoltDB store was closed above. Other backends hold their own file handle and lock
+	if Database.Store != nil && Database.Store != KeyValueStore(&Database.BoltDb) {
+		err = Database.Store.Close()
+		if err != nil {
+			Log.Error("Error closing database store", zap.Error(err))
+		}
+	}
+
+	if internalConfig != nil && internalConfig.Database.SQL.Enabled {
+		err = Database.CloseSQL()
+		if err != nil {
+			Log.Error("Error closing SQL database", zap.Error(err))
+		}
+	}
+
+	stopOAuthRenewers()
+
+	shutdownTracing()
+
+	Log.Info("Platform shut down")
+
+	// Fails for stdout and stderr on some platforms, which is not a problem
+	Log.Sync()
+}
@@ -0,0 +1,422 @@
+package platform
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	sqlDefaultMigrationsTable     = "schema_migrations"
+	sqlDefaultHealthCheckInterval = 30
+	sqlPingTimeout                = time.Second * 5
+)
+
+var (
+	ErrSQLNotEnabled           = errors.New("SQL database is not enabled")
+	ErrSQLNoDriver             = errors.New("no SQL driver configured")
+	ErrSQLNoDSN                = errors.New("no SQL DSN configured")
+	ErrSQLInvalidMigrationName = errors.New("SQL migration file name must start with a version number, e.g. 0001_create_users.sql")
+	ErrSQLDuplicateMigration   = errors.New("SQL migration version registered more than once")
+
+	sqlDatabase     *sql.DB
+	sqlDatabaseLock sync.Mutex
+	sqlHealthStop   chan struct{}
+	sqlHealthDone   chan struct{}
+	sqlHealthy      atomic.Bool
+
+	sqlMigrationSources     = make([]sqlMigrationSource, 0)
+	sqlMigrationSourcesLock sync.Mutex
+)
+
+type sqlMigrationSource struct {
+	fsys fs.FS
+	dir  string
+}
+
+type sqlMigration struct {
+	Version int64
+	Name    string
+	Script  string
+}
+
+// SQL ... Returns the pooled SQL database configured in platform.database.sql.
+// The connection is opened on first use so that drivers registered by the service are available.
+// The service must import the driver, e.g. _ "github.com/lib/pq"
+func (p *PlatformDatabases) SQL() (*sql.DB, error) {
+	sqlDatabaseLock.Lock()
+	defer sqlDatabaseLock.Unlock()
+
+	if sqlDatabase != nil {
+		return sqlDatabase, nil
+	}
+
+	config, err := GetPlatformConfiguration()
+	if err != nil {
+		Log.Error("Unable to read platform configuration", zap.Error(err))
+		return nil, err
+	}
+
+	if !config.Database.SQL.Enabled {
+		return nil, ErrSQLNotEnabled
+	}
+
+	db, err := openSQLDatabase(config)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDatabase = db
+	startSQLHealthCheck(db, config)
+
+	return sqlDatabase, nil
+}
+
+// CloseSQL ... Stops the health check and closes the SQL connection pool
+func (p *PlatformDatabases) CloseSQL() error {
+	sqlDatabaseLock.Lock()
+	defer sqlDatabaseLock.Unlock()
+
+	if sqlDatabase == nil {
+		return nil
+	}
+
+	if sqlHealthStop != nil {
+		close(sqlHealthStop)
+		<-sqlHealthDone
+		sqlHealthStop = nil
+	}
+
+	err := sqlDatabase.Close()
+	sqlDatabase = nil
+	if err != nil {
+		Log.Error("Error closing SQL database", zap.Error(err))
+		return err
+	}
+
+	Log.Info("SQL database closed")
+
+	return nil
+}
+
+// SQLHealthy ... Result of the last background health check
+func (p *PlatformDatabases) SQLHealthy() bool {
+	sqlDatabaseLock.Lock()
+	defer sqlDatabaseLock.Unlock()
+
+	return sqlDatabase != nil && sqlHealthy.Load()
+}
+
+func resolveSQLDSN(config *Config) (string, error) {
+	conf := config.Database.SQL
+
+	if len(conf.VaultPath) > 0 {
+		secrets, err := Vault.GetSecrets(conf.VaultPath)
+		if err != nil {
+			Log.Error("Unable to read SQL DSN from Vault", zap.String("vault_path", conf.VaultPath), zap.Error(err))
+			return "", err
+		}
+
+		dsn := secrets[conf.VaultDSNKey]
+		if len(dsn) < 1 {
+			Log.Error("SQL DSN key not found in Vault secret", zap.String("vault_path", conf.VaultPath),
+				zap.String("key", conf.VaultDSNKey))
+			return "", ErrSQLNoDSN
+		}
+
+		return dsn, nil
+	}
+
+	if len(conf.DSN) < 1 {
+		return "", ErrSQLNoDSN
+	}
+
+	return conf.DSN, nil
+}
+
+func openSQLDatabase(config *Config) (*sql.DB, error) {
+	conf := config.Database.SQL
+
+	if len(conf.Driver) < 1 {
+		return nil, ErrSQLNoDriver
+	}
+
+	// Vault is initialized in its own init, which may not have run yet
+	initializeVault()
+
+	dsn, err := resolveSQLDSN(config)
+	if err != nil {
+		return nil, err
+	}
+
+	Log.Info("Opening SQL database", zap.String("driver", conf.Driver))
+
+	// Only used to find the registered driver. No connections are opened by sql.Open
+	probe, err := sql.Open(conf.Driver, dsn)
+	if err != nil {
+		Log.Error("Unable to find SQL driver", zap.String("driver", conf.Driver), zap.Error(err))
+		return nil, err
+	}
+	sqlDriver := probe.Driver()
+	probe.Close()
+
+	var connector driver.Connector
+	if driverContext, ok := sqlDriver.(driver.DriverContext); ok {
+		connector, err = driverContext.OpenConnector(dsn)
+		if err != nil {
+			Log.Error("Error creating SQL connector", zap.String("driver", conf.Driver), zap.Error(err))
+			return nil, err
+		}
+	} else {
+		connector = &dsnConnector{dsn: dsn, driver: sqlDriver}
+	}
+
+	db := sql.OpenDB(&instrumentedConnector{
+		Connector: connector,
+		slow:      time.Duration(conf.SlowQueryMs) * time.Millisecond,
+	})
+
+	if conf.MaxOpenConnections > 0 {
+		db.SetMaxOpenConns(conf.MaxOpenConnections)
+	}
+	if conf.MaxIdleConnections > 0 {
+		db.SetMaxIdleConns(conf.MaxIdleConnections)
+	}
+	if conf.ConnectionMaxLifetimeSeconds > 0 {
+		db.SetConnMaxLifetime(time.Duration(conf.ConnectionMaxLifetimeSeconds) * time.Second)
+	}
+	if conf.ConnectionMaxIdleTimeSeconds > 0 {
+		db.SetConnMaxIdleTime(time.Duration(conf.ConnectionMaxIdleTimeSeconds) * time.Second)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sqlPingTimeout)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		Log.Error("Unable to connect to SQL database", zap.String("driver", conf.Driver), zap.Error(err))
+		db.Close()
+		return nil, err
+	}
+
+	Log.Info("SQL database opened", zap.String("driver", conf.Driver))
+
+	return db, nil
+}
+
+func startSQLHealthCheck(db *sql.DB, config *Config) {
+	interval := time.Duration(config.Database.SQL.HealthCheckIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Second * sqlDefaultHealthCheckInterval
+	}
+
+	sqlHealthy.Store(true)
+	sqlHealthStop = make(chan struct{})
+	sqlHealthDone = make(chan struct{})
+
+	go func(stop chan struct{}, done chan struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), sqlPingTimeout)
+			err := db.PingContext(ctx)
+			cancel()
+
+			previous := sqlHealthy.Swap(err == nil)
+
+			if err != nil && previous {
+				Log.Error("SQL database health check failed", zap.Error(err))
+			} else if err == nil && !previous {
+				Log.Info("SQL database health check recovered")
+			}
+
+			stats := db.Stats()
+			Log.Debug("SQL connection pool",
+				zap.Int("open", stats.OpenConnections),
+				zap.Int("in_use", stats.InUse),
+				zap.Int("idle", stats.Idle),
+				zap.Int64("wait_count", stats.WaitCount),
+				zap.Duration("wait_duration", stats.WaitDuration))
+		}
+	}(sqlHealthStop, sqlHealthDone)
+}
+
+// RegisterSQLMigrations ... Register a directory of migration scripts, usually from an embed.FS.
+// Files are named <version>_<description>.sql and applied in version order, one transaction per file
+func RegisterSQLMigrations(fsys fs.FS, dir string) {
+	sqlMigrationSourcesLock.Lock()
+	defer sqlMigrationSourcesLock.Unlock()
+
+	sqlMigrationSources = append(sqlMigrationSources, sqlMigrationSource{fsys: fsys, dir: dir})
+}
+
+func loadSQLMigrations() ([]sqlMigration, error) {
+	sqlMigrationSourcesLock.Lock()
+	defer sqlMigrationSourcesLock.Unlock()
+
+	migrations := make([]sqlMigration, 0)
+	versions := make(map[int64]string)
+
+	for _, source := range sqlMigrationSources {
+		entries, err := fs.ReadDir(source.fsys, source.dir)
+		if err != nil {
+			Log.Error("Unable to read SQL migrations directory", zap.String("dir", source.dir), zap.Error(err))
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+				continue
+			}
+
+			versionText := strings.SplitN(entry.Name(), "_", 2)[0]
+			version, err := strconv.ParseInt(strings.TrimSuffix(versionText, ".sql"), 10, 64)
+			if err != nil {
+				Log.Error("Invalid SQL migration file name", zap.String("file", entry.Name()))
+				return nil, ErrSQLInvalidMigrationName
+			}
+
+			if existing, ok := versions[version]; ok {
+				Log.Error("Duplicate SQL migration version", zap.Int64("version", version),
+					zap.String("file", entry.Name()), zap.String("existing", existing))
+				return nil, ErrSQLDuplicateMigration
+			}
+			versions[version] = entry.Name()
+
+			script, err := fs.ReadFile(source.fsys, path.Join(source.dir, entry.Name()))
+			if err != nil {
+				Log.Error("Unable to read SQL migration", zap.String("file", entry.Name()), zap.Error(err))
+				return nil, err
+			}
+
+			migrations = append(migrations, sqlMigration{Version: version, Name: entry.Name(), Script: string(script)})
+		}
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Postgres drivers use numbered placeholders. Everything else we support uses ?
+func sqlPlaceholder(driverName string, index int) string {
+	switch driverName {
+	case "postgres", "pgx":
+		return fmt.Sprintf("$%d", index)
+	default:
+		return "?"
+	}
+}
+
+// RunSQLMigrations ... Applies registered migration scripts that have not been recorded yet.
+// Scripts with multiple statements need driver support, e.g. multiStatements=true for MySQL
+func (p *PlatformDatabases) RunSQLMigrations() (int, error) {
+	db, err := p.SQL()
+	if err != nil {
+		return 0, err
+	}
+
+	migrations, err := loadSQLMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(migrations) < 1 {
+		return 0, nil
+	}
+
+	conf := internalConfig.Database.SQL
+	table := conf.MigrationsTable
+	if len(table) < 1 {
+		table = sqlDefaultMigrationsTable
+	}
+
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version BIGINT PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at VARCHAR(64) NOT NULL)", table))
+	if err != nil {
+		Log.Error("Unable to create SQL migrations table", zap.String("table", table), zap.Error(err))
+		return 0, err
+	}
+
+	applied := make(map[int64]bool)
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT version FROM %s", table))
+	if err != nil {
+		Log.Error("Unable to read SQL migrations table", zap.String("table", table), zap.Error(err))
+		return 0, err
+	}
+	for rows.Next() {
+		var version int64
+		err = rows.Scan(&version)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+
+	insert := fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES (%s, %s, %s)", table,
+		sqlPlaceholder(conf.Driver, 1), sqlPlaceholder(conf.Driver, 2), sqlPlaceholder(conf.Driver, 3))
+
+	count := 0
+	for _, migration := range migrations {
+		if applied[migration.Version] {
+			continue
+		}
+
+		Log.Info("Applying SQL migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return count, err
+		}
+
+		_, err = tx.ExecContext(ctx, migration.Script)
+		if err != nil {
+			tx.Rollback()
+			Log.Error("SQL migration failed", zap.String("name", migration.Name), zap.Error(err))
+			return count, err
+		}
+
+		_, err = tx.ExecContext(ctx, insert, migration.Version, migration.Name, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			tx.Rollback()
+			Log.Error("Unable to record SQL migration", zap.String("name", migration.Name), zap.Error(err))
+			return count, err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			Log.Error("Unable to commit SQL migration", zap.String("name", migration.Name), zap.Error(err))
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
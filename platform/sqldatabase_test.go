@@ -0,0 +1,82 @@
+package platform
+
+import (
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+func useTempSQLDatabase(t *testing.T) {
+	previous := internalConfig.Database.SQL
+
+	internalConfig.Database.SQL.Enabled = true
+	internalConfig.Database.SQL.Driver = "sqlite"
+	internalConfig.Database.SQL.DSN = "file:" + t.TempDir() + "/test.sqlite"
+	internalConfig.Database.SQL.MaxOpenConnections = 2
+
+	t.Cleanup(func() {
+		Database.CloseSQL()
+		internalConfig.Database.SQL = previous
+	})
+}
+
+func TestSQLDatabaseMigrations(t *testing.T) {
+	useTempSQLDatabase(t)
+
+	sqlMigrationSourcesLock.Lock()
+	previous := sqlMigrationSources
+	sqlMigrationSources = make([]sqlMigrationSource, 0)
+	sqlMigrationSourcesLock.Unlock()
+	defer func() {
+		sqlMigrationSources = previous
+	}()
+
+	RegisterSQLMigrations(fstest.MapFS{
+		"migrations/0001_create_people.sql": &fstest.MapFile{Data: []byte("CREATE TABLE people (id TEXT PRIMARY KEY, name TEXT NOT NULL);")},
+		"migrations/0002_add_surname.sql":   &fstest.MapFile{Data: []byte("ALTER TABLE people ADD COLUMN surname TEXT;")},
+		"migrations/readme.txt":             &fstest.MapFile{Data: []byte("not a migration")},
+	}, "migrations")
+
+	count, err := Database.RunSQLMigrations()
+	if err != nil || count != 2 {
+		t.Fatalf("Expected 2 migrations: %d %v", count, err)
+	}
+
+	count, err = Database.RunSQLMigrations()
+	if err != nil || count != 0 {
+		t.Fatalf("Migrations should only be applied once: %d %v", count, err)
+	}
+
+	db, err := Database.SQL()
+	if err != nil {
+		t.Fatalf("Error getting SQL database: %v", err)
+	}
+
+	_, err = db.Exec("INSERT INTO people (id, name, surname) VALUES (?, ?, ?)", "1", "John", "Smith")
+	if err != nil {
+		t.Fatalf("Error inserting row: %v", err)
+	}
+
+	surname := ""
+	err = db.QueryRow("SELECT surname FROM people WHERE id = ?", "1").Scan(&surname)
+	if err != nil || surname != "Smith" {
+		t.Fatalf("Error reading row: %s %v", surname, err)
+	}
+
+	stats, err := Database.SQLStats()
+	if err != nil || stats.Queries < 1 {
+		t.Errorf("Expected instrumented queries to be counted: %v %v", stats, err)
+	}
+
+	if !Database.SQLHealthy() {
+		t.Errorf("Expected SQL database to be healthy")
+	}
+}
+
+func TestSQLDatabaseNotEnabled(t *testing.T) {
+	_, err := Database.SQL()
+	if err != ErrSQLNotEnabled {
+		t.Errorf("Expected ErrSQLNotEnabled, got %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package platform
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	sqlLoggedQueryLength = 200
+)
+
+var (
+	sqlQueryCount     int64
+	sqlErrorCount     int64
+	sqlSlowQueryCount int64
+)
+
+// SQLStats ... Connection pool statistics plus the query counters of the instrumented driver
+type SQLStats struct {
+	sql.DBStats
+	Queries     int64
+	Errors      int64
+	SlowQueries int64
+}
+
+// SQLStats ... Statistics of the platform SQL database
+func (p *PlatformDatabases) SQLStats() (SQLStats, error) {
+	db, err := p.SQL()
+	if err != nil {
+		return SQLStats{}, err
+	}
+
+	return SQLStats{
+		DBStats:     db.Stats(),
+		Queries:     atomic.LoadInt64(&sqlQueryCount),
+		Errors:      atomic.LoadInt64(&sqlErrorCount),
+		SlowQueries: atomic.LoadInt64(&sqlSlowQueryCount),
+	}, nil
+}
+
+func observeSQLQuery(query string, start time.Time, slow time.Duration, err error) {
+	duration := time.Since(start)
+	atomic.AddInt64(&sqlQueryCount, 1)
+
+	if len(query) > sqlLoggedQueryLength {
+		query = query[:sqlLoggedQueryLength]
+	}
+
+	if err != nil && err != driver.ErrSkip {
+		atomic.AddInt64(&sqlErrorCount, 1)
+		Log.Debug("SQL query failed", zap.String("query", query), zap.Duration("duration", duration), zap.Error(err))
+	}
+
+	if slow > 0 && duration > slow {
+		atomic.AddInt64(&sqlSlowQueryCount, 1)
+		Log.Warn("Slow SQL query",
+			zap.String("query", query),
+			zap.Duration("duration", duration),
+			zap.Duration("threshold", slow))
+	}
+}
+
+// For drivers that do not implement driver.DriverContext
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c *dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type instrumentedConnector struct {
+	driver.Connector
+	slow time.Duration
+}
+
+func (c *instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		Log.Error("Error opening SQL connection", zap.Error(err))
+		return nil, err
+	}
+
+	return &instrumentedConn{Conn: conn, slow: c.slow}, nil
+}
+
+// Passes everything through to the driver connection and times the queries
+type instrumentedConn struct {
+	driver.Conn
+	slow time.Duration
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if prepare, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = prepare.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &instrumentedStmt{Stmt: stmt, conn: c.Conn, query: query, slow: c.slow}, nil
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if begin, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return begin.BeginTx(ctx, opts)
+	}
+
+	return c.Conn.Begin()
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	observeSQLQuery(query, start, c.slow, err)
+
+	return result, err
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	observeSQLQuery(query, start, c.slow, err)
+
+	return rows, err
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *instrumentedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+
+	return true
+}
+
+func (c *instrumentedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	return driver.ErrSkip
+}
+
+type instrumentedStmt struct {
+	driver.Stmt
+	conn  driver.Conn
+	query string
+	slow  time.Duration
+}
+
+func namedValuesToValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, v := range args {
+		values[i] = v.Value
+	}
+
+	return values
+}
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+
+	var result driver.Result
+	var err error
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		result, err = s.Stmt.Exec(namedValuesToValues(args))
+	}
+
+	observeSQLQuery(s.query, start, s.slow, err)
+
+	return result, err
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValuesToValues(args))
+	}
+
+	observeSQLQuery(s.query, start, s.slow, err)
+
+	return rows, err
+}
+
+func (s *instrumentedStmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	if checker, ok := s.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	return driver.ErrSkip
+}
@@ -0,0 +1,195 @@
+package platform
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	tracerName = "github.com/Mallekoppie/goslow/platform"
+
+	tracingShutdownTimeout = 5 * time.Second
+)
+
+var (
+	tracerProviderLock sync.Mutex
+	tracerProvider     *sdktrace.TracerProvider
+)
+
+func init() {
+	// traceparent is passed on even when tracing is disabled so that services in between do not break traces
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	initializeTracing()
+}
+
+func initializeTracing() {
+	config, err := GetPlatformConfiguration()
+	if err != nil || !config.Tracing.Enabled {
+		return
+	}
+
+	options := []otlptracehttp.Option{}
+	if len(config.Tracing.Endpoint) > 0 {
+		options = append(options, otlptracehttp.WithEndpoint(config.Tracing.Endpoint))
+	}
+	if config.Tracing.Insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+
+	// Does not connect until spans are exported
+	exporter, err := otlptracehttp.New(context.Background(), options...)
+	if err != nil {
+		Log.Error("Error creating OTLP trace exporter", zap.Error(err))
+		return
+	}
+
+	setTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(tracingSampler(config)),
+		sdktrace.WithResource(tracingResource(config)),
+	))
+
+	Log.Info("Tracing enabled", zap.String("endpoint", config.Tracing.Endpoint))
+}
+
+// UseTraceExporter ... Exports every span to exporter as soon as it ends, replacing the configured exporter.
+// For tests with tracetest.NewInMemoryExporter()
+func UseTraceExporter(exporter sdktrace.SpanExporter) {
+	config, _ := GetPlatformConfiguration()
+
+	setTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithResource(tracingResource(config)),
+	))
+}
+
+func setTracerProvider(provider *sdktrace.TracerProvider) {
+	tracerProviderLock.Lock()
+	previous := tracerProvider
+	tracerProvider = provider
+	tracerProviderLock.Unlock()
+
+	otel.SetTracerProvider(provider)
+
+	if previous != nil {
+		shutdownTracerProvider(previous)
+	}
+}
+
+// Flushes the spans that have not been exported yet
+func shutdownTracing() {
+	tracerProviderLock.Lock()
+	provider := tracerProvider
+	tracerProviderLock.Unlock()
+
+	if provider != nil {
+		shutdownTracerProvider(provider)
+	}
+}
+
+func shutdownTracerProvider(provider *sdktrace.TracerProvider) {
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+
+	err := provider.Shutdown(ctx)
+	if err != nil {
+		Log.Warn("Error flushing spans", zap.Error(err))
+	}
+}
+
+func tracingSampler(config *Config) sdktrace.Sampler {
+	ratio := config.Tracing.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	// Follow the decision of the caller so that traces are not cut in the middle
+	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+}
+
+func tracingResource(config *Config) *resource.Resource {
+	name := "goslow"
+	if config != nil {
+		name = config.Tracing.ServiceName
+		if len(name) < 1 {
+			name = config.Component.ComponentName
+		}
+	}
+
+	return resource.NewSchemaless(attribute.String("service.name", name))
+}
+
+// Tracer ... Tracer for spans of the service
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Starts a span only if ctx is part of a trace. Calls without a trace, such as background jobs
+// and the methods without a context, do not create root spans of their own
+func startChildSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	return Tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// Deferred with the named error result of the traced call
+func endSpan(span trace.Span, err *error) {
+	if *err != nil && *err != ErrNoEntryFoundInDB {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+
+	span.End()
+}
+
+func startBoltSpan(ctx context.Context, d *boltDbDatabase, operation string, bucket string) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{attribute.String("db.system", "boltdb"), attribute.String("db.name", boltDatabaseLabel(d))}
+	if len(bucket) > 0 {
+		attributes = append(attributes, attribute.String("db.boltdb.bucket", bucket))
+	}
+
+	return startChildSpan(ctx, "BoltDB "+operation, attributes...)
+}
+
+// Server span named after the route template
+func tracingMiddleware(next http.Handler, routePath string) http.Handler {
+	return otelhttp.NewHandler(next, routePath,
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			return r.Method + " " + operation
+		}))
+}
+
+// Client spans for outbound requests. Requests need the context of the incoming request,
+// e.g. http.NewRequestWithContext(r.Context(), ...), to be part of its trace
+func tracingRoundTripper(next http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(next)
+}
+
+func traceLogFields(ctx context.Context) []zap.Field {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return nil
+	}
+
+	return []zap.Field{
+		zap.String("trace_id", spanContext.TraceID().String()),
+		zap.String("span_id", spanContext.SpanID().String()),
+	}
+}
@@ -0,0 +1,123 @@
+package platform
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func useInMemoryTracing(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	UseTraceExporter(exporter)
+
+	t.Cleanup(func() {
+		shutdownTracing()
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+
+	return exporter
+}
+
+func TestTracing(t *testing.T) {
+	useTempBoltDB(t)
+	exporter := useInMemoryTracing(t)
+
+	Database.BoltDb.SaveObject("traced", "1", testObject{Id: "1"})
+
+	routes := Routes{
+		Route{
+			Path:   "/traced/{id}",
+			Method: http.MethodGet,
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				err := Database.BoltDb.ReadObjectContext(r.Context(), "traced", "1", &testObject{})
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			},
+		},
+	}
+
+	router, err := newRouter(routes)
+	if err != nil {
+		t.Fatalf("Error creating router: %v", err)
+	}
+
+	for _, v := range exporter.GetSpans() {
+		if v.Name == "BoltDB save" {
+			t.Error("Expected no BoltDB span without a trace")
+		}
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/traced/1", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := tracetest.SpanStubs{}
+	for _, v := range exporter.GetSpans() {
+		if v.SpanContext.TraceID().String() == "4bf92f3577b34da6a3ce929d0e0e4736" {
+			spans = append(spans, v)
+		}
+	}
+	if len(spans) != 2 {
+		t.Fatalf("Expected a server and a BoltDB span but got %d", len(spans))
+	}
+
+	bolt, server := spans[0], spans[1]
+	if server.Name != "GET /traced/{id}" || server.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the server span to continue the trace of the caller but got %s", server.Name)
+	}
+	if bolt.Name != "BoltDB read" || bolt.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("Expected BoltDB read as child of the server span but got %s", bolt.Name)
+	}
+}
+
+func TestTracingClientPropagation(t *testing.T) {
+	exporter := useInMemoryTracing(t)
+
+	received := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	client, err := CreateHttpClient("default")
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+
+	ctx, span := Tracer().Start(context.Background(), "parent")
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatalf("Error calling server: %v", err)
+	}
+	response.Body.Close()
+	span.End()
+
+	// The OAuth token renewals run in traces of their own
+	spans := tracetest.SpanStubs{}
+	for _, v := range exporter.GetSpans() {
+		if v.SpanContext.TraceID() == span.SpanContext().TraceID() {
+			spans = append(spans, v)
+		}
+	}
+	if len(spans) != 2 {
+		t.Fatalf("Expected a client and a parent span but got %d", len(spans))
+	}
+
+	clientSpan := spans[0]
+	expected := "00-" + span.SpanContext().TraceID().String() + "-" + clientSpan.SpanContext.SpanID().String() + "-01"
+	if received != expected {
+		t.Errorf("Expected traceparent %s but got %s", expected, received)
+	}
+
+	if fields := traceLogFields(trace.ContextWithSpanContext(context.Background(), clientSpan.SpanContext)); len(fields) != 2 {
+		t.Errorf("Expected trace and span id log fields but got %v", fields)
+	}
+}
@@ -1,6 +1,7 @@
 package platform
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	vaultapi "github.com/hashicorp/vault/api"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
@@ -19,6 +21,8 @@ var (
 	ErrVaultNotEnabled              = errors.New("Vault not enabled")
 	ErrVaultUnableToReadSecrets     = errors.New("Unable to read secrets from Vault")
 	ErrVaultNoAuthMethodsConfigured = errors.New("No auth methods configured for Vault")
+	ErrVaultUnableToWrite           = errors.New("Unable to write to Vault")
+	ErrVaultUnableToLogin           = errors.New("Unable to login to Vault")
 )
 
 type platformVault struct {
@@ -131,9 +135,18 @@ func processVaultSecretResponse(input map[string]interface{}) (secrets map[strin
 }
 
 func (v *platformVault) GetSecrets(path string) (secrets map[string]string, err error) {
+	return v.GetSecretsContext(context.Background(), path)
+}
+
+// GetSecretsContext ... Same as GetSecrets but traced as part of the request in ctx
+func (v *platformVault) GetSecretsContext(ctx context.Context, path string) (secrets map[string]string, err error) {
 	if !vaultEnabled {
 		return secrets, ErrVaultNotEnabled
 	}
+	defer observeVaultRequest("read", time.Now(), &err)
+
+	_, span := startChildSpan(ctx, "Vault read", attribute.String("vault.path", path))
+	defer endSpan(span, &err)
 
 	for _, c := range vaultClientList {
 		if internalConfig.Vault.Token.Enabled {
@@ -151,34 +164,11 @@ func (v *platformVault) GetSecrets(path string) (secrets map[string]string, err
 
 			break
 		} else if internalConfig.Vault.Cert.Enabled {
-			request := c.NewRequest("POST", "/v1/auth/cert/login")
-			response, err := c.RawRequest(request)
+			err := loginVaultWithCert(c)
 			if err != nil {
-				Log.Error("Error loging in to Vault with cert to get token", zap.Error(err))
 				continue
 			}
 
-			if response.StatusCode != http.StatusOK {
-				Log.Error("Incorrect responsecode from Vault when logging in with Cert", zap.Int("response_code", response.StatusCode))
-				continue
-			}
-			defer response.Body.Close()
-			responseData, err := ioutil.ReadAll(response.Body)
-			if err != nil {
-				Log.Error("Error reading login response using cert to Vault", zap.Error(err))
-				continue
-			}
-
-			responseModel := vaultLoginResponse{}
-
-			err = json.Unmarshal(responseData, &responseModel)
-			if err != nil {
-				Log.Error("Unable too unmarshal response from vault login", zap.Error(err))
-				continue
-			}
-
-			c.SetToken(responseModel.Auth.ClientToken)
-
 			secretResult, err := c.Logical().Read(path)
 			if err != nil {
 				Log.Error("Error reading secrets from Vault using cert auth method", zap.Error(err))
@@ -206,6 +196,74 @@ func (v *platformVault) GetSecrets(path string) (secrets map[string]string, err
 	return secrets, nil
 }
 
+// Write ... Writes data to the path, e.g. to call a secrets engine such as Transit. Returns the response data
+func (v *platformVault) Write(path string, data map[string]interface{}) (_ map[string]interface{}, err error) {
+	if !vaultEnabled {
+		return nil, ErrVaultNotEnabled
+	}
+	defer observeVaultRequest("write", time.Now(), &err)
+
+	for _, c := range vaultClientList {
+		if internalConfig.Vault.Cert.Enabled && !internalConfig.Vault.Token.Enabled {
+			err := loginVaultWithCert(c)
+			if err != nil {
+				continue
+			}
+		} else if !internalConfig.Vault.Token.Enabled {
+			return nil, ErrVaultNoAuthMethodsConfigured
+		}
+
+		result, err := c.Logical().Write(path, data)
+		if err != nil {
+			Log.Error("Error writing to Vault", zap.Error(err), zap.String("address", c.Address()))
+			continue
+		}
+
+		if result == nil || result.Data == nil {
+			Log.Error("Result from writing to vault is nil")
+			continue
+		}
+
+		return result.Data, nil
+	}
+
+	return nil, ErrVaultUnableToWrite
+}
+
+// Logs in with the client certificate and sets the token on the client
+func loginVaultWithCert(c *vaultapi.Client) error {
+	request := c.NewRequest("POST", "/v1/auth/cert/login")
+	response, err := c.RawRequest(request)
+	if err != nil {
+		Log.Error("Error loging in to Vault with cert to get token", zap.Error(err))
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		Log.Error("Incorrect responsecode from Vault when logging in with Cert", zap.Int("response_code", response.StatusCode))
+		return ErrVaultUnableToLogin
+	}
+
+	responseData, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		Log.Error("Error reading login response using cert to Vault", zap.Error(err))
+		return err
+	}
+
+	responseModel := vaultLoginResponse{}
+
+	err = json.Unmarshal(responseData, &responseModel)
+	if err != nil {
+		Log.Error("Unable too unmarshal response from vault login", zap.Error(err))
+		return err
+	}
+
+	c.SetToken(responseModel.Auth.ClientToken)
+
+	return nil
+}
+
 type vaultLoginResponse struct {
 	RequestID     string      `json:"request_id"`
 	LeaseID       string      `json:"lease_id"`